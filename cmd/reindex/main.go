@@ -0,0 +1,70 @@
+// Команда reindex полностью перестраивает поисковый индекс сообщений.
+// Встроенный индекс нельзя открыть одновременно с сервером, поэтому
+// перед запуском сервер нужно остановить; иначе команда завершится с ошибкой.
+package main
+
+import (
+	"log"
+	"messenger/internal/config"
+	"messenger/internal/db"
+	"messenger/internal/repository"
+	"messenger/internal/search"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const batchSize = 500
+
+func main() {
+	database, err := db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	repo := repository.NewMessageRepository(database)
+	cfg := config.LoadSearch()
+	index, err := search.Open(cfg, repo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	total, err := repo.CountMessages()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("rebuilding %s search index, %d messages", cfg.Backend, total)
+
+	if err := index.Reset(); err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		afterTime *time.Time
+		afterID   *uuid.UUID
+		indexed   int64
+	)
+	for {
+		docs, err := repo.ListForIndexing(afterTime, afterID, batchSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+		if err := index.Index(docs...); err != nil {
+			log.Fatal(err)
+		}
+
+		last := docs[len(docs)-1]
+		afterTime, afterID = &last.CreatedAt, &last.ID
+		indexed += int64(len(docs))
+		log.Printf("indexed %d/%d", indexed, total)
+	}
+
+	if err := index.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("done")
+}
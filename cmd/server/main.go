@@ -2,15 +2,23 @@ package main
 
 import (
 	"log"
+	"messenger/internal/config"
 	"messenger/internal/db"
 	"messenger/internal/handler"
+	"messenger/internal/linkpreview"
 	"messenger/internal/middleware"
+	"messenger/internal/model"
 	"messenger/internal/repository"
+	"messenger/internal/search"
 	"messenger/internal/service"
 	"messenger/internal/service/websocket"
+	"messenger/internal/storage"
+	"messenger/internal/utils"
 
 	"database/sql"
 	"os"
+	"path/filepath"
+	"sort"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,22 +39,124 @@ func main() {
 
 	applyMigrations(database)
 
+	keys, err := utils.NewKeySet(config.LoadJWT())
+	if err != nil {
+		panic(err)
+	}
+	go keys.RunRotation()
+
 	hub := websocket.NewHub()
-	go hub.Run()
 
-	userRepository := repository.NewUserRepository(database)
-	userService := service.NewUserService(userRepository)
-	userHandler := handler.NewUserHandler(userService)
+	blobs, err := storage.NewLocalBlobStore(config.LoadStorage().BlobDir)
+	if err != nil {
+		panic(err)
+	}
 
 	chatRepository := repository.NewChatRepository(database)
-	chatService := service.NewChatService(chatRepository, userRepository, hub)
+
+	userRepository := repository.NewUserRepository(database)
+	contactRepository := repository.NewContactRepository(database)
+	contactService := service.NewContactService(contactRepository, userRepository)
+	contactHandler := handler.NewContactHandler(contactService)
+
+	presenceService := service.NewPresenceService(userRepository, chatRepository, contactRepository, hub)
+	hub.SetPresenceTracker(presenceService)
+	go hub.Run()
+
+	userService := service.NewUserService(userRepository, chatRepository, blobs, presenceService, hub)
+	userHandler := handler.NewUserHandler(userService, keys)
+
+	webhookRepository := repository.NewWebhookRepository(database)
+	webhookService := service.NewWebhookService(webhookRepository, chatRepository)
+	webhookConfig := config.LoadWebhooks()
+	if err := webhookService.SyncGlobal(webhookConfig.GlobalURLs, webhookConfig.GlobalSecret); err != nil {
+		panic(err)
+	}
+	go webhookService.Run()
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+
+	chatService := service.NewChatService(chatRepository, userRepository, webhookService, presenceService, contactService, hub)
 	chatHandler := handler.NewChatHandler(chatService)
 
+	chatFolderRepository := repository.NewChatFolderRepository(database)
+	chatFolderService := service.NewChatFolderService(chatFolderRepository)
+	chatFolderHandler := handler.NewChatFolderHandler(chatFolderService)
+
+	botRepository := repository.NewBotRepository(database)
+	botService := service.NewBotService(botRepository, userRepository, chatRepository)
+
 	messageRepository := repository.NewMessageRepository(database)
-	messageService := service.NewMessageService(messageRepository, chatRepository, hub)
-	messageHandler := handler.NewMessageHandler(messageService)
+	searchIndex, err := search.Open(config.LoadSearch(), messageRepository)
+	if err != nil {
+		panic(err)
+	}
+	defer searchIndex.Close()
+	searchIndexer := service.NewSearchIndexer(searchIndex)
+	go searchIndexer.Run()
+	linkPreviewConfig := config.LoadLinkPreview()
+	linkFetcher := linkpreview.NewFetcher(linkpreview.Config{
+		Timeout:      linkPreviewConfig.Timeout,
+		MaxBodyBytes: linkPreviewConfig.MaxBodyBytes,
+		MaxRedirects: linkPreviewConfig.MaxRedirects,
+	})
+	linkPreviewService := service.NewLinkPreviewService(linkFetcher, repository.NewLinkPreviewRepository(database), messageRepository, chatRepository, webhookService, hub)
+	go linkPreviewService.Run()
+	messageService := service.NewMessageService(messageRepository, chatRepository, botService, webhookService, contactService, searchIndex, searchIndexer, linkPreviewService, hub)
+	scheduledMessageRepository := repository.NewScheduledMessageRepository(database)
+	scheduledMessageService := service.NewScheduledMessageService(scheduledMessageRepository, chatRepository, messageService, hub)
+	go scheduledMessageService.Run()
+	draftRepository := repository.NewDraftRepository(database)
+	draftService := service.NewDraftService(draftRepository, messageRepository, chatRepository, hub)
+	draftHandler := handler.NewDraftHandler(draftService)
+	messageHandler := handler.NewMessageHandler(messageService, scheduledMessageService, draftService)
+	botHandler := handler.NewBotHandler(botService, messageService)
+
+	messageTTLService := service.NewMessageTTLService(messageRepository, chatRepository, messageService, searchIndexer, webhookService, hub)
+	go messageTTLService.Run()
+	messageTTLHandler := handler.NewMessageTTLHandler(messageTTLService)
+
+	pinnedMessageRepository := repository.NewPinnedMessageRepository(database)
+	pinService := service.NewPinService(pinnedMessageRepository, messageRepository, chatRepository, messageService, webhookService, hub)
+	pinHandler := handler.NewPinHandler(pinService)
+
+	chatExportRepository := repository.NewChatExportRepository(database)
+	chatExportService := service.NewChatExportService(chatExportRepository, messageRepository, chatRepository, blobs, hub)
+	go chatExportService.Run()
+	chatExportHandler := handler.NewChatExportHandler(chatExportService)
+
+	chatImportRepository := repository.NewChatImportRepository(database)
+	chatImportService := service.NewChatImportService(chatImportRepository, userRepository, contactService, blobs, searchIndexer, hub)
+	go chatImportService.Run()
+	chatImportHandler := handler.NewChatImportHandler(chatImportService)
+
+	pollRepository := repository.NewPollRepository(database)
+	pollService := service.NewPollService(pollRepository, messageRepository, chatRepository, messageService, hub)
+	pollHandler := handler.NewPollHandler(pollService)
 
-	wsHandler := handler.NewWebSocketHandler(hub, "your_secret_key")
+	incomingWebhookRepository := repository.NewIncomingWebhookRepository(database)
+	incomingWebhookService := service.NewIncomingWebhookService(incomingWebhookRepository, chatRepository, messageService)
+	incomingWebhookHandler := handler.NewIncomingWebhookHandler(incomingWebhookService)
+
+	apiTokenRepository := repository.NewAPITokenRepository(database)
+	apiTokenService := service.NewAPITokenService(apiTokenRepository)
+	apiTokenHandler := handler.NewAPITokenHandler(apiTokenService)
+
+	accountConfig := config.LoadAccount()
+	accountService := service.NewAccountService(repository.NewAccountRepository(database), userRepository, userService,
+		chatRepository, contactRepository, apiTokenRepository, botRepository, chatFolderRepository, messageRepository,
+		searchIndexer, webhookService, blobs, hub, service.AccountPolicy{
+			DeletionGrace:  accountConfig.DeletionGrace,
+			DeleteMessages: accountConfig.DeletedMessages == model.DeletedMessagesDelete,
+		})
+	go accountService.Run()
+	accountHandler := handler.NewAccountHandler(accountService)
+
+	adminService := service.NewAdminService(repository.NewAdminRepository(database), userRepository, userService,
+		chatRepository, messageRepository, hub)
+	adminHandler := handler.NewAdminHandler(adminService)
+
+	wsHandler := handler.NewWebSocketHandler(hub, keys)
+	jwksHandler := handler.NewJWKSHandler(keys)
 
 	r := gin.Default()
 	r.LoadHTMLGlob("web/*.html")
@@ -61,20 +171,141 @@ func main() {
 		c.String(200, "Welcome to the Chat!")
 	})
 
+	r.GET("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
+	r.POST("/hooks/:token", incomingWebhookHandler.Post)
+
 	r.POST("/api/register", userHandler.Register)
 	r.POST("/api/login", userHandler.Login)
 
 	api := r.Group("/api")
-	api.Use(middleware.AuthMiddleware("your_secret_key"))
+	api.Use(middleware.AuthMiddleware(keys, apiTokenService, userService))
 	{
-		api.POST("/chats/private", chatHandler.CreatePrivateChat)
-		api.POST("/chats/group", chatHandler.CreateGroupChat)
-		api.POST("/messages", messageHandler.SendMessage)
-		api.GET("/chats/:chat_id/messages", messageHandler.GetMessages)
 		api.GET("/ws", wsHandler.HandleWebSocket)
-		api.GET("/chats", chatHandler.GetUserChats)
-		api.GET("/users/search", userHandler.SearchUsers)
-		api.POST("/chats/:chat_id/read", messageHandler.MarkAsRead)
+
+		chatsRead := api.Group("", middleware.RequireScope(model.ScopeChatsRead))
+		chatsRead.GET("/chats", chatHandler.GetUserChats)
+		chatsRead.GET("/folders", chatFolderHandler.ListFolders)
+
+		chatsWrite := api.Group("", middleware.RequireScope(model.ScopeChatsWrite))
+		chatsWrite.POST("/chats/private", chatHandler.CreatePrivateChat)
+		chatsWrite.POST("/chats/group", chatHandler.CreateGroupChat)
+		chatsWrite.POST("/chats/:chat_id/bots", chatHandler.AddBot)
+		chatsWrite.PATCH("/chats/:chat_id/settings", chatHandler.UpdateSettings)
+		chatsWrite.PUT("/chats/:chat_id/restrictions", chatHandler.UpdateRestrictions)
+		chatsWrite.PUT("/chats/:chat_id/ttl", messageTTLHandler.SetTTL)
+		chatsWrite.PUT("/chats/pinned", chatHandler.ReorderPins)
+		chatsWrite.POST("/imports", chatImportHandler.StartImport)
+		chatsWrite.GET("/imports/:import_id", chatImportHandler.GetImport)
+		chatsWrite.POST("/folders", chatFolderHandler.CreateFolder)
+		chatsWrite.PUT("/folders/:folder_id", chatFolderHandler.UpdateFolder)
+		chatsWrite.DELETE("/folders/:folder_id", chatFolderHandler.DeleteFolder)
+		chatsWrite.POST("/chats/:chat_id/webhooks", webhookHandler.CreateWebhook)
+		chatsWrite.GET("/chats/:chat_id/webhooks", webhookHandler.ListWebhooks)
+		chatsWrite.DELETE("/chats/:chat_id/webhooks/:webhook_id", webhookHandler.DeleteWebhook)
+		chatsWrite.POST("/chats/:chat_id/webhooks/:webhook_id/enable", webhookHandler.EnableWebhook)
+		chatsWrite.GET("/chats/:chat_id/webhooks/:webhook_id/deliveries", webhookHandler.ListDeliveries)
+		chatsWrite.POST("/chats/:chat_id/incoming-webhooks", incomingWebhookHandler.CreateWebhook)
+		chatsWrite.GET("/chats/:chat_id/incoming-webhooks", incomingWebhookHandler.ListWebhooks)
+		chatsWrite.DELETE("/chats/:chat_id/incoming-webhooks/:webhook_id", incomingWebhookHandler.RevokeWebhook)
+
+		messagesRead := api.Group("", middleware.RequireScope(model.ScopeMessagesRead))
+		messagesRead.GET("/chats/:chat_id/messages", messageHandler.GetMessages)
+		messagesRead.GET("/search/messages", messageHandler.SearchMessages)
+		messagesRead.GET("/mentions", messageHandler.GetMentions)
+		messagesRead.GET("/messages/scheduled", messageHandler.ListScheduled)
+		messagesRead.GET("/chats/:chat_id/pins", pinHandler.ListPins)
+		messagesRead.GET("/chats/:chat_id/draft", draftHandler.GetDraft)
+		messagesRead.POST("/chats/:chat_id/export", chatExportHandler.RequestExport)
+		messagesRead.GET("/exports/:export_id", chatExportHandler.GetExport)
+		messagesRead.GET("/exports/:export_id/download", chatExportHandler.DownloadExport)
+
+		messagesWrite := api.Group("", middleware.RequireScope(model.ScopeMessagesWrite))
+		messagesWrite.POST("/messages", messageHandler.SendMessage)
+		messagesWrite.POST("/messages/forward", messageHandler.ForwardMessages)
+		messagesWrite.PATCH("/messages/scheduled/:scheduled_id", messageHandler.UpdateScheduled)
+		messagesWrite.DELETE("/messages/scheduled/:scheduled_id", messageHandler.CancelScheduled)
+		messagesWrite.POST("/chats/:chat_id/read", messageHandler.MarkAsRead)
+		messagesWrite.PUT("/chats/:chat_id/draft", draftHandler.SaveDraft)
+		messagesWrite.POST("/chats/:chat_id/pins", pinHandler.PinMessage)
+		messagesWrite.DELETE("/chats/:chat_id/pins/:message_id", pinHandler.UnpinMessage)
+		messagesWrite.POST("/chats/:chat_id/polls", pollHandler.CreatePoll)
+		messagesWrite.POST("/polls/:poll_id/votes", pollHandler.Vote)
+		messagesWrite.DELETE("/polls/:poll_id/votes", pollHandler.RetractVote)
+		messagesWrite.POST("/polls/:poll_id/close", pollHandler.ClosePoll)
+
+		usersRead := api.Group("", middleware.RequireScope(model.ScopeUsersRead))
+		usersRead.GET("/users/search", userHandler.SearchUsers)
+		usersRead.GET("/users/me", userHandler.GetMe)
+		usersRead.GET("/users/:user_id", userHandler.GetUser)
+		usersRead.GET("/users/:user_id/avatar", userHandler.GetAvatar)
+
+		// Профиль меняется только из сессии пользователя
+		profile := api.Group("/users/me", middleware.RequireSession())
+		profile.PATCH("", userHandler.UpdateMe)
+		profile.PUT("/avatar", userHandler.UploadAvatar)
+		profile.DELETE("/avatar", userHandler.DeleteAvatar)
+		profile.GET("/privacy", userHandler.GetPrivacy)
+		profile.PUT("/privacy", userHandler.UpdatePrivacy)
+		profile.DELETE("", accountHandler.DeleteAccount)
+		profile.POST("/deletion/cancel", accountHandler.CancelDeletion)
+		profile.POST("/export", accountHandler.RequestExport)
+		profile.GET("/exports/:export_id", accountHandler.GetExport)
+		profile.GET("/exports/:export_id/download", accountHandler.DownloadExport)
+
+		// Подтвердить авторство импортированных сообщений можно только из сессии пользователя
+		claims := api.Group("/imports/claims", middleware.RequireSession())
+		claims.GET("", chatImportHandler.ListClaims)
+		claims.POST("/:claim_id/accept", chatImportHandler.AcceptClaim)
+		claims.DELETE("/:claim_id", chatImportHandler.DeclineClaim)
+
+		// Управлять токенами можно только из сессии пользователя
+		tokens := api.Group("/tokens", middleware.RequireSession())
+		tokens.POST("", apiTokenHandler.CreateToken)
+		tokens.GET("", apiTokenHandler.ListTokens)
+		tokens.DELETE("/:token_id", apiTokenHandler.RevokeToken)
+
+		contacts := api.Group("/contacts", middleware.RequireSession())
+		contacts.GET("", contactHandler.ListContacts)
+		contacts.POST("", contactHandler.AddContact)
+		contacts.DELETE("/:user_id", contactHandler.RemoveContact)
+
+		blocks := api.Group("/blocks", middleware.RequireSession())
+		blocks.GET("", contactHandler.ListBlocked)
+		blocks.POST("", contactHandler.BlockUser)
+		blocks.DELETE("/:user_id", contactHandler.UnblockUser)
+
+		bots := api.Group("/bots", middleware.RequireSession())
+		bots.POST("", botHandler.CreateBot)
+		bots.GET("", botHandler.ListBots)
+		bots.POST("/:bot_id/token", botHandler.RegenerateToken)
+		bots.DELETE("/:bot_id", botHandler.DeleteBot)
+
+		// Административный API: модераторы и администраторы, только по сессии
+		admin := api.Group("/admin", middleware.RequireSession(), middleware.RequireRole(model.UserRoleModerator))
+		admin.GET("/users", adminHandler.ListUsers)
+		admin.GET("/users/:user_id", adminHandler.GetUser)
+		admin.GET("/users/:user_id/chats", adminHandler.GetUserChats)
+		admin.POST("/users/:user_id/suspend", adminHandler.SuspendUser)
+		admin.POST("/users/:user_id/unsuspend", adminHandler.UnsuspendUser)
+		admin.GET("/chats/:chat_id", adminHandler.GetChat)
+		admin.GET("/stats", adminHandler.GetStats)
+
+		superuser := admin.Group("", middleware.RequireRole(model.UserRoleAdmin))
+		superuser.POST("/users/:user_id/password-reset", adminHandler.ResetPassword)
+		superuser.PUT("/users/:user_id/role", adminHandler.SetRole)
+	}
+
+	botAPI := r.Group("/bot/api")
+	botAPI.Use(middleware.BotAuthMiddleware(botService))
+	{
+		botAPI.GET("/me", botHandler.GetMe)
+		botAPI.POST("/messages", botHandler.SendMessage)
+		botAPI.GET("/updates", botHandler.GetUpdates)
+		botAPI.PUT("/webhook", botHandler.SetWebhook)
+		botAPI.DELETE("/webhook", botHandler.DeleteWebhook)
+		botAPI.PUT("/commands", botHandler.SetCommands)
+		botAPI.GET("/commands", botHandler.GetCommands)
 	}
 
 	log.Printf("Server started at port 8080")
@@ -84,14 +315,22 @@ func main() {
 }
 
 func applyMigrations(db *sql.DB) {
-	query, err := os.ReadFile("internal/db/migration/001_init.sql")
+	files, err := filepath.Glob("internal/db/migration/*.sql")
 	if err != nil {
-		log.Fatalf("Ошибка чтения файла миграции: %v", err)
+		log.Fatalf("Ошибка поиска файлов миграций: %v", err)
 	}
+	sort.Strings(files)
 
-	_, err = db.Exec(string(query))
-	if err != nil {
-		log.Fatalf("Ошибка применения миграции: %v", err)
+	for _, file := range files {
+		query, err := os.ReadFile(file)
+		if err != nil {
+			log.Fatalf("Ошибка чтения файла миграции %s: %v", file, err)
+		}
+
+		_, err = db.Exec(string(query))
+		if err != nil {
+			log.Fatalf("Ошибка применения миграции %s: %v", file, err)
+		}
 	}
 
 	log.Println("Миграции успешно применены!")
@@ -0,0 +1,41 @@
+// Команда setrole назначает глобальную роль пользователю по адресу почты.
+// Через нее заводится первый администратор; дальше роли раздаются через /api/admin.
+//
+//	go run ./cmd/setrole -email admin@example.com -role admin
+package main
+
+import (
+	"flag"
+	"log"
+	"messenger/internal/db"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+)
+
+func main() {
+	email := flag.String("email", "", "адрес почты пользователя")
+	role := flag.String("role", string(model.UserRoleAdmin), "роль: user, moderator или admin")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("-email is required")
+	}
+	if !model.UserRole(*role).IsValid() {
+		log.Fatalf("unknown role %q", *role)
+	}
+
+	database, err := db.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
+
+	ok, err := repository.NewUserRepository(database).SetRoleByEmail(*email, model.UserRole(*role))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !ok {
+		log.Fatalf("user %s not found", *email)
+	}
+	log.Printf("%s is now %s", *email, *role)
+}
@@ -0,0 +1,127 @@
+// Package accountexport собирает архив со всеми данными пользователя: profile.json с профилем,
+// настройками, токенами, ботами и контактами, chats.json со списком его чатов и messages.json
+// с его сообщениями во всех чатах. Архив пишется потоком, сообщения добавляются страницами.
+package accountexport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"messenger/internal/model"
+	"time"
+)
+
+const readme = `Данные аккаунта
+
+profile.json  — профиль, настройки приватности, персональные токены, боты, контакты,
+                заблокированные пользователи и папки чатов
+chats.json    — чаты, в которых вы состоите
+messages.json — все ваши сообщения во всех чатах в порядке отправки
+
+Сессии входа на сервере не хранятся: токен сессии живет только у клиента, поэтому в архив
+попадают лишь персональные токены (без самих секретов). Историю отдельного чата целиком,
+включая чужие сообщения, можно выгрузить из самого чата.
+`
+
+// Profile — все, что хранится об аккаунте, кроме чатов и сообщений
+type Profile struct {
+	User       model.User             `json:"user"`
+	Privacy    *model.PrivacySettings `json:"privacy"`
+	Tokens     []model.APIToken       `json:"api_tokens"`
+	Bots       []model.Bot            `json:"bots"`
+	Contacts   []model.Contact        `json:"contacts"`
+	Blocked    []model.BlockedUser    `json:"blocked"`
+	Folders    []model.ChatFolder     `json:"folders"`
+	ExportedAt time.Time              `json:"exported_at"`
+}
+
+// Archive пишет ZIP-архив в w. Файл сообщений идет последним и остается открытым до Close.
+type Archive struct {
+	zw       *zip.Writer
+	messages io.Writer
+	modified time.Time
+	count    int
+}
+
+func New(w io.Writer, profile *Profile, chats []model.Membership) (*Archive, error) {
+	a := &Archive{zw: zip.NewWriter(w), modified: profile.ExportedAt}
+	if err := a.writeFile("README.txt", []byte(readme)); err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", profile},
+		{"chats.json", chats},
+	} {
+		data, err := json.MarshalIndent(f.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := a.writeFile(f.name, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if a.messages, err = a.create("messages.json"); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(a.messages, "["); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Count возвращает число записанных сообщений
+func (a *Archive) Count() int {
+	return a.count
+}
+
+// Add дописывает сообщения, идущие в порядке отправки
+func (a *Archive) Add(messages []model.Message) error {
+	for i := range messages {
+		data, err := json.Marshal(&messages[i])
+		if err != nil {
+			return err
+		}
+		sep := ",\n"
+		if a.count == 0 {
+			sep = "\n"
+		}
+		if _, err := io.WriteString(a.messages, sep); err != nil {
+			return err
+		}
+		if _, err := a.messages.Write(data); err != nil {
+			return err
+		}
+		a.count++
+	}
+	return nil
+}
+
+// Close завершает файл сообщений и архив; w при этом не закрывается
+func (a *Archive) Close() error {
+	if _, err := io.WriteString(a.messages, "\n]\n"); err != nil {
+		return err
+	}
+	return a.zw.Close()
+}
+
+func (a *Archive) create(name string) (io.Writer, error) {
+	return a.zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: a.modified,
+	})
+}
+
+func (a *Archive) writeFile(name string, data []byte) error {
+	w, err := a.create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
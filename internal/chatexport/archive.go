@@ -0,0 +1,159 @@
+// Package chatexport собирает архив истории чата: result.json для программ, messages.html
+// для чтения в браузере без сети и messages.txt. Сообщения добавляются страницами, поэтому
+// в памяти не держится вся история.
+package chatexport
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"io"
+	"messenger/internal/model"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	jsonFile = "result.json"
+	htmlFile = "messages.html"
+	textFile = "messages.txt"
+)
+
+// Header — сведения о чате в начале архива
+type Header struct {
+	Chat       model.Chat            `json:"chat"`
+	Members    []model.MemberProfile `json:"members"`
+	ExportedAt time.Time             `json:"exported_at"`
+}
+
+// Archive пишет файлы архива во временный каталог; Close удаляет его
+type Archive struct {
+	header Header
+	dir    string
+	files  []*output
+	count  int
+}
+
+type output struct {
+	name string
+	file *os.File
+	buf  *bufio.Writer
+}
+
+func New(header Header) (*Archive, error) {
+	dir, err := os.MkdirTemp("", "chat-export-*")
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{header: header, dir: dir}
+	for _, name := range []string{jsonFile, htmlFile, textFile} {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.files = append(a.files, &output{name: name, file: f, buf: bufio.NewWriter(f)})
+	}
+
+	if err := a.writeJSONHeader(a.files[0].buf); err != nil {
+		a.Close()
+		return nil, err
+	}
+	if err := writeHTMLHeader(a.files[1].buf, &header); err != nil {
+		a.Close()
+		return nil, err
+	}
+	if err := writeTextHeader(a.files[2].buf, &header); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Count возвращает число записанных сообщений
+func (a *Archive) Count() int {
+	return a.count
+}
+
+// Add дописывает сообщения, идущие в порядке отправки
+func (a *Archive) Add(messages []model.Message) error {
+	for i := range messages {
+		m := &messages[i]
+		if err := a.writeJSONMessage(a.files[0].buf, m); err != nil {
+			return err
+		}
+		if err := writeHTMLMessage(a.files[1].buf, m); err != nil {
+			return err
+		}
+		if err := writeTextMessage(a.files[2].buf, m); err != nil {
+			return err
+		}
+		a.count++
+	}
+	return nil
+}
+
+// WriteZip завершает файлы и пишет из них ZIP-архив в w
+func (a *Archive) WriteZip(w io.Writer) error {
+	if _, err := a.files[0].buf.WriteString("\n]}\n"); err != nil {
+		return err
+	}
+	if err := writeHTMLFooter(a.files[1].buf, a.count); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, o := range a.files {
+		if err := o.buf.Flush(); err != nil {
+			return err
+		}
+		if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     o.name,
+			Method:   zip.Deflate,
+			Modified: a.header.ExportedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(entry, o.file); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func (a *Archive) Close() error {
+	for _, o := range a.files {
+		o.file.Close()
+	}
+	return os.RemoveAll(a.dir)
+}
+
+// result.json: {"chat": ..., "members": [...], "exported_at": ..., "messages": [...]}
+func (a *Archive) writeJSONHeader(w *bufio.Writer) error {
+	data, err := json.Marshal(&a.header)
+	if err != nil {
+		return err
+	}
+	// Массив сообщений дописывается к объекту заголовка вместо закрывающей скобки
+	w.Write(data[:len(data)-1])
+	_, err = w.WriteString(`,"messages":[`)
+	return err
+}
+
+func (a *Archive) writeJSONMessage(w *bufio.Writer, m *model.Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if a.count > 0 {
+		w.WriteByte(',')
+	}
+	w.WriteString("\n")
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,97 @@
+package chatexport
+
+import (
+	"html/template"
+	"io"
+	"messenger/internal/markup"
+	"messenger/internal/model"
+)
+
+// Страница не ссылается на внешние ресурсы: стили встроены, картинки из превью не загружаются
+var pageTemplates = template.Must(template.New("page").Funcs(template.FuncMap{
+	"time": func(t interface{ Format(string) string }) string { return t.Format("02.01.2006 15:04") },
+	"name": memberName,
+	"body": func(m *model.Message) template.HTML {
+		return template.HTML(markup.RenderHTML(m.Content, m.Entities))
+	},
+	"correct": func(p *model.Poll, i int) bool { return p.CorrectOption != nil && *p.CorrectOption == i },
+}).Parse(`{{define "header"}}<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Chat.Name}} — экспорт чата</title>
+<style>
+body { margin: 0; font: 15px/1.45 -apple-system, "Segoe UI", Roboto, sans-serif; background: #eef1f5; color: #1c1e21; }
+main { max-width: 760px; margin: 0 auto; padding: 24px 16px; }
+header { background: #fff; border-radius: 10px; padding: 16px 20px; margin-bottom: 16px; }
+header h1 { margin: 0 0 4px; font-size: 22px; }
+.meta, .time, .note { color: #65676b; font-size: 13px; }
+details { margin-top: 8px; }
+.message { background: #fff; border-radius: 10px; padding: 10px 14px; margin: 8px 0; }
+.message .sender { font-weight: 600; margin-right: 8px; }
+.message .text { white-space: pre-wrap; word-wrap: break-word; margin-top: 4px; }
+.system { text-align: center; color: #65676b; font-size: 13px; margin: 12px 0; }
+.card { border-left: 3px solid #3390ec; padding: 4px 10px; margin-top: 6px; }
+.card .title { font-weight: 600; }
+blockquote { border-left: 3px solid #c4c9d0; margin: 4px 0; padding-left: 10px; }
+pre { background: #f3f4f6; padding: 8px; border-radius: 6px; overflow-x: auto; }
+code { font-family: ui-monospace, Menlo, Consolas, monospace; }
+.spoiler { background: #c4c9d0; color: transparent; border-radius: 3px; }
+.spoiler:hover { color: inherit; background: none; }
+.poll ol { margin: 6px 0; padding-left: 22px; }
+.poll .correct { font-weight: 600; }
+</style>
+</head>
+<body>
+<main>
+<header>
+<h1>{{.Chat.Name}}</h1>
+<div class="meta">Выгружено {{time .ExportedAt}} UTC</div>
+<details>
+<summary>Участники: {{len .Members}}</summary>
+<ul>{{range .Members}}<li>{{name .}} (@{{.Username}}){{if eq .Role "admin"}} — администратор{{end}}</li>{{end}}</ul>
+</details>
+</header>
+{{end}}
+
+{{define "message"}}{{if eq .Type "system"}}<div class="system" id="message-{{.ID}}">{{.SenderName}} {{.Content}} · {{time .CreatedAt}}</div>
+{{else}}<div class="message" id="message-{{.ID}}">
+<span class="sender">{{.SenderName}}</span><span class="time">{{time .CreatedAt}}</span>
+{{with .Forwarded}}<div class="note">Переслано от {{.SenderName}}</div>{{end}}
+{{if .Poll}}{{template "poll" .Poll}}{{else}}<div class="text">{{body .}}</div>{{end}}
+{{with .LinkPreview}}<div class="card"><a class="title" href="{{.URL}}" rel="noopener noreferrer">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a>{{if .Description}}<div>{{.Description}}</div>{{end}}</div>{{end}}
+{{range .Attachments}}<div class="card">{{if .URL}}<a class="title" href="{{.URL}}" rel="noopener noreferrer">{{if .Title}}{{.Title}}{{else}}{{.URL}}{{end}}</a>{{else if .Title}}<div class="title">{{.Title}}</div>{{end}}{{if .Text}}<div>{{.Text}}</div>{{end}}</div>{{end}}
+</div>
+{{end}}{{end}}
+
+{{define "poll"}}<div class="poll">
+<div class="title">{{if .Quiz}}Викторина{{else}}Опрос{{end}}: {{.Question}}</div>
+<ol>{{range $i, $o := .Options}}<li{{if correct $ $i}} class="correct"{{end}}>{{$o.Text}} — {{$o.Votes}}</li>{{end}}</ol>
+<div class="note">Проголосовало: {{.TotalVoters}}{{if .Closed}} · опрос закрыт{{end}}</div>
+</div>{{end}}
+
+{{define "footer"}}<p class="meta">Сообщений: {{.}}</p>
+</main>
+</body>
+</html>
+{{end}}`))
+
+func memberName(m model.MemberProfile) string {
+	if m.DisplayName != nil && *m.DisplayName != "" {
+		return *m.DisplayName
+	}
+	return m.Username
+}
+
+func writeHTMLHeader(w io.Writer, h *Header) error {
+	return pageTemplates.ExecuteTemplate(w, "header", h)
+}
+
+func writeHTMLMessage(w io.Writer, m *model.Message) error {
+	return pageTemplates.ExecuteTemplate(w, "message", m)
+}
+
+func writeHTMLFooter(w io.Writer, count int) error {
+	return pageTemplates.ExecuteTemplate(w, "footer", count)
+}
@@ -0,0 +1,54 @@
+package chatexport
+
+import (
+	"fmt"
+	"io"
+	"messenger/internal/model"
+	"strings"
+)
+
+const textTimeLayout = "2006-01-02 15:04:05"
+
+func writeTextHeader(w io.Writer, h *Header) error {
+	names := make([]string, 0, len(h.Members))
+	for _, m := range h.Members {
+		names = append(names, memberName(m))
+	}
+	_, err := fmt.Fprintf(w, "%s\nВыгружено: %s UTC\nУчастники: %s\n\n",
+		h.Chat.Name, h.ExportedAt.Format(textTimeLayout), strings.Join(names, ", "))
+	return err
+}
+
+func writeTextMessage(w io.Writer, m *model.Message) error {
+	at := m.CreatedAt.Format(textTimeLayout)
+	if m.Type == model.MessageSystem {
+		_, err := fmt.Fprintf(w, "[%s] * %s %s\n", at, m.SenderName, m.Content)
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s:", at, m.SenderName)
+	if m.Forwarded != nil {
+		fmt.Fprintf(&b, " (переслано от %s)", m.Forwarded.SenderName)
+	}
+	if p := m.Poll; p != nil {
+		fmt.Fprintf(&b, " [опрос] %s\n", p.Question)
+		for i, o := range p.Options {
+			mark := " "
+			if p.CorrectOption != nil && *p.CorrectOption == i {
+				mark = "+"
+			}
+			fmt.Fprintf(&b, "  %s %d. %s — %d\n", mark, i+1, o.Text, o.Votes)
+		}
+	} else {
+		fmt.Fprintf(&b, " %s\n", m.Content)
+	}
+	if m.LinkPreview != nil {
+		fmt.Fprintf(&b, "  > %s\n", m.LinkPreview.URL)
+	}
+	for _, a := range m.Attachments {
+		fmt.Fprintf(&b, "  > %s\n", strings.TrimSpace(a.Title+" "+a.URL))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
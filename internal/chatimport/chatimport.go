@@ -0,0 +1,178 @@
+// Package chatimport разбирает выгрузки других мессенджеров в общий вид: авторы и сообщения
+// в порядке отправки. Разбор детерминирован, поэтому прерванный импорт можно продолжить
+// с номера сообщения.
+package chatimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"messenger/internal/markup"
+	"messenger/internal/model"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Source string
+
+const (
+	SourceTelegram Source = "telegram" // result.json из Telegram Desktop или ZIP с ним
+	SourceSlack    Source = "slack"    // ZIP-выгрузка рабочего пространства Slack
+)
+
+func (s Source) IsValid() bool {
+	return s == SourceTelegram || s == SourceSlack
+}
+
+// User — автор сообщений в исходном мессенджере
+type User struct {
+	ID       string
+	Name     string
+	Username string // логин, если он есть в выгрузке
+	Email    string
+}
+
+// Message — сообщение выгрузки. Skip — причина, по которой сообщение не переносится;
+// Lost — что из сообщения потеряно при переносе (например, вложения).
+type Message struct {
+	ID       string
+	AuthorID string
+	Text     string
+	Entities []model.MessageEntity
+	SentAt   time.Time
+	ReplyTo  string // ID сообщения, на которое это отвечает
+	Skip     string
+	Lost     string
+}
+
+// Export — разобранная выгрузка одного чата
+type Export struct {
+	Name     string
+	Users    []User // только авторы сообщений, в порядке первого появления
+	Messages []Message
+}
+
+// Parse разбирает выгрузку. channel выбирает канал в выгрузке Slack; если канал один, его можно не указывать.
+func Parse(source Source, data []byte, channel string) (*Export, error) {
+	var export *Export
+	var err error
+	switch source {
+	case SourceTelegram:
+		export, err = parseTelegram(data)
+	case SourceSlack:
+		export, err = parseSlack(data, channel)
+	default:
+		return nil, errors.New("неизвестный источник импорта")
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(export.Messages, func(i, j int) bool {
+		return export.Messages[i].SentAt.Before(export.Messages[j].SentAt)
+	})
+	return export, nil
+}
+
+func isZip(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("PK\x03\x04"))
+}
+
+// Ограничения распаковки: небольшой архив может развернуться в гигабайты
+const (
+	maxZipEntrySize = 100 << 20
+	maxUnzippedSize = 200 << 20 // на все прочитанные файлы архива
+)
+
+var ErrArchiveTooLarge = errors.New("архив распаковывается в слишком большой объем")
+
+// zipArchive читает файлы архива, считая общий объем распакованных данных
+type zipArchive struct {
+	*zip.Reader
+	left int64
+}
+
+func openZip(data []byte) (*zipArchive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	return &zipArchive{Reader: zr, left: maxUnzippedSize}, nil
+}
+
+// readFile читает файл целиком. Размер из заголовка архива не проверяется на честность:
+// ограничение применяется к реально распакованным байтам.
+func (z *zipArchive) readFile(f *zip.File) ([]byte, error) {
+	limit := min(int64(maxZipEntrySize), z.left)
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, ErrArchiveTooLarge
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrArchiveTooLarge
+	}
+	z.left -= int64(len(data))
+	return data, nil
+}
+
+// users собирает авторов в порядке первого появления
+type users struct {
+	list []User
+	seen map[string]bool
+}
+
+func (u *users) add(user User) {
+	if u.seen == nil {
+		u.seen = make(map[string]bool)
+	}
+	if !u.seen[user.ID] {
+		u.seen[user.ID] = true
+		u.list = append(u.list, user)
+	}
+}
+
+// textBuilder собирает текст и сущности со смещениями в UTF-16
+type textBuilder struct {
+	text     strings.Builder
+	offset   int
+	entities []model.MessageEntity
+}
+
+func (b *textBuilder) write(s string) {
+	b.text.WriteString(s)
+	b.offset += markup.UTF16Len(s)
+}
+
+// writeEntity пишет фрагмент, размеченный сущностью e
+func (b *textBuilder) writeEntity(s string, e model.MessageEntity) {
+	start := b.offset
+	b.write(s)
+	if len(e.Language) > 32 {
+		e.Language = ""
+	}
+	if e.Type == model.EntityTextLink {
+		link, ok := markup.SafeURL(e.URL)
+		if !ok {
+			return
+		}
+		e.URL = link
+	}
+	if e.Length = b.offset - start; e.Length > 0 {
+		e.Offset = start
+		b.entities = append(b.entities, e)
+	}
+}
+
+func (b *textBuilder) result() (string, []model.MessageEntity) {
+	markup.SortEntities(b.entities)
+	return b.text.String(), b.entities
+}
@@ -0,0 +1,80 @@
+package chatimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func makeZip(t *testing.T, files map[string]io.Reader) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.Copy(w, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// emptyArray — корректный JSON-массив размером n байт из пробелов; сжимается примерно в тысячу раз
+func emptyArray(n int64) io.Reader {
+	return io.MultiReader(bytes.NewBufferString("["), io.LimitReader(spaces{}, n-2), bytes.NewBufferString("]"))
+}
+
+type spaces struct{}
+
+func (spaces) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
+
+func TestParseSlack(t *testing.T) {
+	data := makeZip(t, map[string]io.Reader{
+		"users.json":              bytes.NewBufferString(`[{"id":"U1","name":"ivan","real_name":"Иван"}]`),
+		"general/2024-01-01.json": bytes.NewBufferString(`[{"type":"message","user":"U1","text":"привет","ts":"1704067200.000100"}]`),
+	})
+	export, err := Parse(SourceSlack, data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Messages) != 1 || export.Messages[0].Text != "привет" || len(export.Users) != 1 {
+		t.Fatalf("export = %+v", export)
+	}
+}
+
+func TestParseRejectsZipBombs(t *testing.T) {
+	tests := map[string]map[string]io.Reader{
+		"large entry": {
+			"users.json":              emptyArray(maxZipEntrySize + 1),
+			"general/2024-01-01.json": bytes.NewBufferString(`[]`),
+		},
+		"large total": {
+			"general/2024-01-01.json": emptyArray(maxZipEntrySize - 1),
+			"general/2024-01-02.json": emptyArray(maxZipEntrySize - 1),
+			"general/2024-01-03.json": emptyArray(maxZipEntrySize - 1),
+		},
+	}
+	for name, files := range tests {
+		t.Run(name, func(t *testing.T) {
+			data := makeZip(t, files)
+			if len(data) > 1<<20 {
+				t.Fatalf("archive is %d bytes, expected a small one", len(data))
+			}
+			if _, err := Parse(SourceSlack, data, "general"); !errors.Is(err, ErrArchiveTooLarge) {
+				t.Fatalf("err = %v, want ErrArchiveTooLarge", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package chatimport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"messenger/internal/model"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type slackUser struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Deleted bool   `json:"deleted"`
+	Profile struct {
+		Email       string `json:"email"`
+		RealName    string `json:"real_name"`
+		DisplayName string `json:"display_name"`
+	} `json:"profile"`
+}
+
+type slackChannel struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type slackMessage struct {
+	Type     string            `json:"type"`
+	Subtype  string            `json:"subtype"`
+	User     string            `json:"user"`
+	BotID    string            `json:"bot_id"`
+	Username string            `json:"username"` // подпись бота или интеграции
+	Text     string            `json:"text"`
+	TS       string            `json:"ts"`
+	ThreadTS string            `json:"thread_ts"`
+	Files    []json.RawMessage `json:"files"`
+}
+
+// Подтипы, при которых сообщение написано человеком или ботом; остальные — служебные записи
+var slackContentSubtypes = map[string]bool{
+	"":                 true,
+	"bot_message":      true,
+	"file_share":       true,
+	"me_message":       true,
+	"thread_broadcast": true,
+}
+
+// <@U123>, <#C123|general>, <!here>, <https://example.com|текст>
+var slackLink = regexp.MustCompile(`<([^<>]+)>`)
+
+func parseSlack(data []byte, channel string) (*Export, error) {
+	if !isZip(data) {
+		return nil, errors.New("выгрузка Slack должна быть ZIP-архивом")
+	}
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]*zip.File) // каталог канала -> файлы по дням
+	var usersFile *zip.File
+	var channelFiles []*zip.File
+	for _, f := range zr.File {
+		dir, name := path.Split(f.Name)
+		dir = strings.TrimSuffix(dir, "/")
+		switch {
+		case dir == "" && name == "users.json":
+			usersFile = f
+		case dir == "" && (name == "channels.json" || name == "groups.json"):
+			channelFiles = append(channelFiles, f)
+		case dir != "" && !strings.Contains(dir, "/") && strings.HasSuffix(name, ".json"):
+			files[dir] = append(files[dir], f)
+		}
+	}
+
+	if channel == "" {
+		if len(files) != 1 {
+			names := make([]string, 0, len(files))
+			for name := range files {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("в выгрузке несколько каналов, укажите один из них: %s", strings.Join(names, ", "))
+		}
+		for name := range files {
+			channel = name
+		}
+	}
+	channel = strings.TrimPrefix(channel, "#")
+	days, ok := files[channel]
+	if !ok {
+		return nil, fmt.Errorf("в выгрузке нет канала %s", channel)
+	}
+	if err := checkSlackChannel(zr, channelFiles, channel); err != nil {
+		return nil, err
+	}
+
+	people := make(map[string]slackUser)
+	if usersFile != nil {
+		data, err := zr.readFile(usersFile)
+		if err != nil {
+			return nil, err
+		}
+		var list []slackUser
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать users.json: %w", err)
+		}
+		for _, u := range list {
+			people[u.ID] = u
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Name < days[j].Name })
+	var raw []slackMessage
+	for _, f := range days {
+		data, err := zr.readFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var day []slackMessage
+		if err := json.Unmarshal(data, &day); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать %s: %w", f.Name, err)
+		}
+		raw = append(raw, day...)
+	}
+
+	export := &Export{Name: channel}
+	var authors users
+	for _, m := range raw {
+		msg := Message{ID: m.TS}
+		if m.ThreadTS != "" && m.ThreadTS != m.TS {
+			msg.ReplyTo = m.ThreadTS
+		}
+		sentAt, err := slackTime(m.TS)
+		if err != nil {
+			msg.Skip = "не удалось разобрать дату"
+			export.Messages = append(export.Messages, msg)
+			continue
+		}
+		msg.SentAt = sentAt
+
+		author, ok := slackAuthor(&m, people)
+		msg.AuthorID = author.ID
+		switch {
+		case m.Type != "message" || !slackContentSubtypes[m.Subtype]:
+			msg.Skip = "служебное сообщение"
+		case !ok:
+			msg.Skip = "неизвестный автор"
+		default:
+			msg.Text, msg.Entities = slackText(m.Text, people)
+			if len(m.Files) > 0 {
+				msg.Lost = "вложение не перенесено"
+			}
+			if strings.TrimSpace(msg.Text) == "" {
+				msg.Skip, msg.Lost = "сообщение без текста", ""
+				if len(m.Files) > 0 {
+					msg.Skip = "вложения не переносятся"
+				}
+			}
+		}
+		if msg.Skip == "" {
+			authors.add(author)
+		}
+		export.Messages = append(export.Messages, msg)
+	}
+	export.Users = authors.list
+	return export, nil
+}
+
+// checkSlackChannel отличает канал от посторонних каталогов архива, если есть список каналов
+func checkSlackChannel(zr *zipArchive, channelFiles []*zip.File, channel string) error {
+	if len(channelFiles) == 0 {
+		return nil
+	}
+	for _, f := range channelFiles {
+		data, err := zr.readFile(f)
+		if err != nil {
+			return err
+		}
+		var list []slackChannel
+		if err := json.Unmarshal(data, &list); err != nil {
+			return fmt.Errorf("не удалось разобрать %s: %w", f.Name, err)
+		}
+		for _, c := range list {
+			if c.Name == channel {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("в выгрузке нет канала %s", channel)
+}
+
+func slackAuthor(m *slackMessage, people map[string]slackUser) (User, bool) {
+	if m.User != "" {
+		u := User{ID: m.User, Name: m.User}
+		if p, ok := people[m.User]; ok {
+			u.Name, u.Username, u.Email = slackName(p), p.Name, p.Profile.Email
+		}
+		return u, true
+	}
+	if m.BotID != "" {
+		name := m.Username
+		if name == "" {
+			name = "Бот"
+		}
+		return User{ID: "bot:" + m.BotID, Name: name}, true
+	}
+	return User{}, false
+}
+
+func slackName(u slackUser) string {
+	switch {
+	case u.Profile.DisplayName != "":
+		return u.Profile.DisplayName
+	case u.Profile.RealName != "":
+		return u.Profile.RealName
+	default:
+		return u.Name
+	}
+}
+
+// slackTime разбирает ts вида "1700000000.123456"
+func slackTime(ts string) (time.Time, error) {
+	sec, frac, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var usec int64
+	if frac != "" {
+		frac = (frac + "000000")[:6]
+		if usec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.Unix(s, usec*1000).UTC(), nil
+}
+
+// slackText переводит разметку Slack в текст: упоминания — в @имя, ссылки с подписью — в
+// сущности text_link. Жирный и курсив Slack остаются как есть.
+func slackText(text string, people map[string]slackUser) (string, []model.MessageEntity) {
+	var b textBuilder
+	pos := 0
+	for _, loc := range slackLink.FindAllStringSubmatchIndex(text, -1) {
+		b.write(html.UnescapeString(text[pos:loc[0]]))
+		pos = loc[1]
+
+		inner := text[loc[2]:loc[3]]
+		target, label, _ := strings.Cut(inner, "|")
+		label = html.UnescapeString(label)
+		switch {
+		case strings.HasPrefix(target, "@"):
+			name := label
+			if p, ok := people[target[1:]]; ok {
+				name = p.Name
+			}
+			if name == "" {
+				name = target[1:]
+			}
+			b.write("@" + name)
+		case strings.HasPrefix(target, "#"):
+			if label == "" {
+				label = target[1:]
+			}
+			b.write("#" + label)
+		case strings.HasPrefix(target, "!"):
+			// <!here>, <!channel>, <!subteam^ID|@team>
+			if label == "" {
+				label = "@" + strings.TrimPrefix(target, "!")
+			}
+			b.write(label)
+		default:
+			target = html.UnescapeString(target)
+			if label == "" {
+				b.write(strings.TrimPrefix(target, "mailto:"))
+			} else {
+				b.writeEntity(label, model.MessageEntity{Type: model.EntityTextLink, URL: target})
+			}
+		}
+	}
+	b.write(html.UnescapeString(text[pos:]))
+	return b.result()
+}
@@ -0,0 +1,199 @@
+package chatimport
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"messenger/internal/model"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type tgExport struct {
+	Name     *string         `json:"name"`
+	Messages []tgMessage     `json:"messages"`
+	Chats    json.RawMessage `json:"chats"` // есть только в выгрузке всего аккаунта
+}
+
+type tgMessage struct {
+	ID            int64           `json:"id"`
+	Type          string          `json:"type"` // message или service
+	Date          string          `json:"date"`
+	DateUnix      string          `json:"date_unixtime"`
+	From          *string         `json:"from"`
+	FromID        string          `json:"from_id"`
+	ReplyTo       int64           `json:"reply_to_message_id"`
+	Text          json.RawMessage `json:"text"`
+	TextEntities  []tgEntity      `json:"text_entities"`
+	Photo         string          `json:"photo"`
+	File          string          `json:"file"`
+	MediaType     string          `json:"media_type"`
+	Poll          json.RawMessage `json:"poll"`
+	LocationInfo  json.RawMessage `json:"location_information"`
+	ContactInfo   json.RawMessage `json:"contact_information"`
+	ForwardedFrom *string         `json:"forwarded_from"`
+}
+
+type tgEntity struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Href     string `json:"href"`
+	Language string `json:"language"`
+}
+
+func parseTelegram(data []byte) (*Export, error) {
+	if isZip(data) {
+		var err error
+		if data, err = telegramResultFromZip(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var raw tgExport
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать выгрузку Telegram: %w", err)
+	}
+	if raw.Messages == nil {
+		if raw.Chats != nil {
+			return nil, errors.New("это выгрузка всего аккаунта Telegram; выгрузите историю одного чата")
+		}
+		return nil, errors.New("в выгрузке Telegram нет сообщений")
+	}
+
+	export := &Export{Name: "Telegram"}
+	if raw.Name != nil && *raw.Name != "" {
+		export.Name = *raw.Name
+	}
+	var authors users
+	for _, m := range raw.Messages {
+		id := strconv.FormatInt(m.ID, 10)
+		msg := Message{ID: id, AuthorID: m.FromID}
+		if m.ReplyTo != 0 {
+			msg.ReplyTo = strconv.FormatInt(m.ReplyTo, 10)
+		}
+
+		sentAt, err := telegramDate(m.Date, m.DateUnix)
+		if err != nil {
+			msg.Skip = "не удалось разобрать дату"
+			export.Messages = append(export.Messages, msg)
+			continue
+		}
+		msg.SentAt = sentAt
+
+		switch {
+		case m.Type != "message":
+			msg.Skip = "служебное сообщение"
+		case m.FromID == "":
+			msg.Skip = "неизвестный автор"
+		case len(m.Poll) > 0:
+			msg.Skip = "опросы не переносятся"
+		default:
+			msg.Text, msg.Entities, err = telegramText(m.Text, m.TextEntities)
+			if err != nil {
+				msg.Skip = "не удалось разобрать текст"
+				break
+			}
+			if m.Photo != "" || m.File != "" || m.MediaType != "" || len(m.LocationInfo) > 0 || len(m.ContactInfo) > 0 {
+				msg.Lost = "вложение не перенесено"
+			}
+			if strings.TrimSpace(msg.Text) == "" {
+				msg.Skip, msg.Lost = "сообщение без текста", ""
+				if m.Photo != "" || m.File != "" || m.MediaType != "" {
+					msg.Skip = "вложения не переносятся"
+				}
+			}
+		}
+
+		if msg.Skip == "" {
+			name := "Удаленный аккаунт"
+			if m.From != nil && *m.From != "" {
+				name = *m.From
+			}
+			authors.add(User{ID: m.FromID, Name: name})
+		}
+		export.Messages = append(export.Messages, msg)
+	}
+	export.Users = authors.list
+	return export, nil
+}
+
+// telegramResultFromZip достает result.json из архива папки выгрузки
+func telegramResultFromZip(data []byte) ([]byte, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	var result *zip.File
+	for _, f := range zr.File {
+		if path.Base(f.Name) == "result.json" && (result == nil || len(f.Name) < len(result.Name)) {
+			result = f
+		}
+	}
+	if result == nil {
+		return nil, errors.New("в архиве нет result.json")
+	}
+	return zr.readFile(result)
+}
+
+// telegramDate предпочитает date_unixtime: date записана в часовом поясе того, кто выгружал
+func telegramDate(date, unix string) (time.Time, error) {
+	if unix != "" {
+		sec, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(sec, 0).UTC(), nil
+	}
+	return time.Parse("2006-01-02T15:04:05", date)
+}
+
+// telegramText собирает текст из text_entities, а в старых выгрузках — из массива text
+func telegramText(raw json.RawMessage, entities []tgEntity) (string, []model.MessageEntity, error) {
+	if len(entities) == 0 && len(raw) > 0 {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			return s, nil, nil
+		}
+		var parts []json.RawMessage
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return "", nil, err
+		}
+		for _, p := range parts {
+			var e tgEntity
+			if json.Unmarshal(p, &s) == nil {
+				e = tgEntity{Type: "plain", Text: s}
+			} else if err := json.Unmarshal(p, &e); err != nil {
+				return "", nil, err
+			}
+			entities = append(entities, e)
+		}
+	}
+
+	var b textBuilder
+	for _, e := range entities {
+		switch e.Type {
+		case "bold":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityBold})
+		case "italic":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityItalic})
+		case "code":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityCode})
+		case "pre":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityPre, Language: e.Language})
+		case "text_link":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityTextLink, URL: e.Href})
+		case "spoiler":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntitySpoiler})
+		case "blockquote":
+			b.writeEntity(e.Text, model.MessageEntity{Type: model.EntityBlockquote})
+		default:
+			// Упоминания, ссылки, хештеги и прочее переносятся как текст
+			b.write(e.Text)
+		}
+	}
+	text, ents := b.result()
+	return text, ents, nil
+}
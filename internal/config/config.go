@@ -1 +1,144 @@
 package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JWTConfig описывает параметры выпуска и проверки токенов доступа
+type JWTConfig struct {
+	Algorithm        string        // RS256 или EdDSA
+	Issuer           string        // значение claim iss
+	Audience         []string      // допустимые значения claim aud
+	TokenTTL         time.Duration // время жизни выпускаемых токенов
+	RotationInterval time.Duration // как часто выпускается новый ключ подписи
+	KeysDir          string        // каталог с PEM-ключами; пустой — ключи живут только в памяти
+}
+
+func LoadJWT() JWTConfig {
+	return JWTConfig{
+		Algorithm:        getEnv("JWT_ALGORITHM", "EdDSA"),
+		Issuer:           getEnv("JWT_ISSUER", "messenger"),
+		Audience:         getList("JWT_AUDIENCE", []string{"messenger"}),
+		TokenTTL:         getDuration("JWT_TOKEN_TTL", 24*time.Hour),
+		RotationInterval: getDuration("JWT_ROTATION_INTERVAL", 7*24*time.Hour),
+		KeysDir:          getEnv("JWT_KEYS_DIR", "keys"),
+	}
+}
+
+// WebhookConfig описывает глобальные вебхуки, получающие события всех чатов
+type WebhookConfig struct {
+	GlobalURLs   []string
+	GlobalSecret string
+}
+
+func LoadWebhooks() WebhookConfig {
+	return WebhookConfig{
+		GlobalURLs:   getList("WEBHOOK_GLOBAL_URLS", nil),
+		GlobalSecret: getEnv("WEBHOOK_GLOBAL_SECRET", ""),
+	}
+}
+
+const (
+	SearchBackendPostgres = "postgres"
+	SearchBackendEmbedded = "embedded"
+)
+
+// SearchConfig выбирает реализацию поискового индекса
+type SearchConfig struct {
+	Backend  string // postgres или embedded
+	IndexDir string // каталог встроенного индекса
+}
+
+func LoadSearch() SearchConfig {
+	return SearchConfig{
+		Backend:  getEnv("SEARCH_BACKEND", SearchBackendPostgres),
+		IndexDir: getEnv("SEARCH_INDEX_DIR", "data/search"),
+	}
+}
+
+// StorageConfig описывает хранилище файлов (аватары и т.п.)
+type StorageConfig struct {
+	BlobDir string
+}
+
+func LoadStorage() StorageConfig {
+	return StorageConfig{
+		BlobDir: getEnv("BLOB_DIR", "data/blobs"),
+	}
+}
+
+// LinkPreviewConfig ограничивает загрузку страниц для превью ссылок
+type LinkPreviewConfig struct {
+	Timeout      time.Duration
+	MaxBodyBytes int64
+	MaxRedirects int
+}
+
+func LoadLinkPreview() LinkPreviewConfig {
+	return LinkPreviewConfig{
+		Timeout:      getDuration("LINK_PREVIEW_TIMEOUT", 5*time.Second),
+		MaxBodyBytes: int64(getInt("LINK_PREVIEW_MAX_BYTES", 1<<20)),
+		MaxRedirects: getInt("LINK_PREVIEW_MAX_REDIRECTS", 3),
+	}
+}
+
+// AccountConfig описывает удаление аккаунтов по запросу пользователя
+type AccountConfig struct {
+	DeletionGrace   time.Duration // сколько ждать перед удалением; пользователь может передумать
+	DeletedMessages string        // anonymize — сообщения остаются от "Удаленного аккаунта", delete — удаляются
+}
+
+func LoadAccount() AccountConfig {
+	return AccountConfig{
+		DeletionGrace:   getDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour),
+		DeletedMessages: getEnv("ACCOUNT_DELETED_MESSAGES", "anonymize"),
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
+
+func getList(key string, fallback []string) []string {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
+
+func getInt(key string, fallback int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,16 @@
+-- Персональные токены доступа для интеграций
+
+CREATE TABLE IF NOT EXISTS api_tokens (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+name VARCHAR(100) NOT NULL,
+token_hash CHAR(64) UNIQUE NOT NULL, -- SHA-256 от токена, сам токен не храним
+token_prefix VARCHAR(16) NOT NULL, -- первые символы токена, чтобы пользователь мог его узнать
+scopes TEXT[] NOT NULL,
+expires_at TIMESTAMP,
+last_used_at TIMESTAMP,
+revoked_at TIMESTAMP,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens (user_id);
@@ -0,0 +1,32 @@
+-- Боты: отдельные учетные записи в users, управляемые владельцем-человеком
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS bots (
+user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+token_hash CHAR(64) UNIQUE NOT NULL, -- SHA-256 от токена бота
+webhook_url TEXT,
+webhook_secret TEXT,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Слэш-команды, которые обрабатывает бот
+CREATE TABLE IF NOT EXISTS bot_commands (
+bot_id UUID REFERENCES bots(user_id) ON DELETE CASCADE,
+command VARCHAR(32) NOT NULL,
+description VARCHAR(256) NOT NULL DEFAULT '',
+PRIMARY KEY (bot_id, command)
+);
+
+-- Очередь обновлений, которые бот забирает long polling'ом или получает через вебхук
+CREATE TABLE IF NOT EXISTS bot_updates (
+id BIGSERIAL PRIMARY KEY,
+bot_id UUID REFERENCES bots(user_id) ON DELETE CASCADE,
+payload JSONB NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_bots_owner_id ON bots (owner_id);
+CREATE INDEX IF NOT EXISTS idx_bot_commands_command ON bot_commands (command);
+CREATE INDEX IF NOT EXISTS idx_bot_updates_bot_id ON bot_updates (bot_id, id);
@@ -0,0 +1,15 @@
+-- Роли участников чатов
+
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS role VARCHAR(10) NOT NULL DEFAULT 'member' CHECK (role IN ('member', 'admin'));
+
+-- Для групп, созданных до появления ролей, администратором становится первый участник
+UPDATE chat_members cm SET role = 'admin'
+FROM (
+    SELECT DISTINCT ON (m.chat_id) m.chat_id, m.user_id
+    FROM chat_members m
+    JOIN chats c ON c.id = m.chat_id
+    WHERE c.type = 'group'
+      AND NOT EXISTS (SELECT 1 FROM chat_members a WHERE a.chat_id = m.chat_id AND a.role = 'admin')
+    ORDER BY m.chat_id, m.joined_at
+) first_member
+WHERE cm.chat_id = first_member.chat_id AND cm.user_id = first_member.user_id;
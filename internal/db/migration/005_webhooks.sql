@@ -0,0 +1,33 @@
+-- Исходящие вебхуки: подписки на события чатов и очередь доставки
+
+CREATE TABLE IF NOT EXISTS webhooks (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE, -- NULL — глобальная подписка на все чаты
+created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+url TEXT NOT NULL,
+secret TEXT NOT NULL, -- ключ HMAC-подписи тела запроса
+events TEXT[] NOT NULL DEFAULT '{}', -- пустой массив — все события
+is_active BOOLEAN NOT NULL DEFAULT true,
+failure_count INT NOT NULL DEFAULT 0, -- неудачные доставки подряд
+disabled_at TIMESTAMP,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+id BIGSERIAL PRIMARY KEY,
+webhook_id UUID REFERENCES webhooks(id) ON DELETE CASCADE,
+event VARCHAR(50) NOT NULL,
+payload JSONB NOT NULL,
+status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+attempts INT NOT NULL DEFAULT 0,
+next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+response_status INT,
+last_error TEXT,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+delivered_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhooks_global_url ON webhooks (url) WHERE chat_id IS NULL;
+CREATE INDEX IF NOT EXISTS idx_webhooks_chat_id ON webhooks (chat_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id DESC);
@@ -0,0 +1,19 @@
+-- Входящие вебхуки: секретные адреса для публикации сообщений в чат
+
+-- Подпись и вложения, переданные отправителем вместе с сообщением
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender_display_name VARCHAR(100);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS sender_icon_url TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS attachments JSONB;
+
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+sender_id UUID REFERENCES users(id) ON DELETE CASCADE, -- служебный пользователь, от имени которого пишутся сообщения
+name VARCHAR(100) NOT NULL,
+token_hash CHAR(64) UNIQUE NOT NULL,
+created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+revoked_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_incoming_webhooks_chat_id ON incoming_webhooks (chat_id);
@@ -0,0 +1,9 @@
+-- Полнотекстовый поиск по сообщениям
+-- Конфигурация 'russian' стеммит кириллицу русским словарем, а латиницу (asciiword) — английским,
+-- поэтому одного вектора достаточно для сообщений на обоих языках
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (to_tsvector('russian', content)) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_messages_search_vector ON messages USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_messages_sender_id ON messages (sender_id);
@@ -0,0 +1,9 @@
+-- Профили пользователей: отображаемое имя, описание, аватар, статус и часовой пояс
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bio VARCHAR(500);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key TEXT; -- ключ файла в хранилище, сам файл в базе не храним
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_text VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_expires_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS time_zone VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS profile_updated_at TIMESTAMP;
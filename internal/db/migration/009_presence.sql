@@ -0,0 +1,5 @@
+-- Время последнего визита и настройки приватности присутствия
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS privacy_last_seen VARCHAR(10) NOT NULL DEFAULT 'everyone'
+    CHECK (privacy_last_seen IN ('everyone', 'contacts', 'nobody'));
@@ -0,0 +1,23 @@
+-- Контакты, блокировки и настройка "кто может начать личный чат"
+
+CREATE TABLE IF NOT EXISTS contacts (
+owner_id UUID REFERENCES users(id) ON DELETE CASCADE,
+contact_id UUID REFERENCES users(id) ON DELETE CASCADE,
+custom_name VARCHAR(64), -- имя, под которым владелец сохранил контакт
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+PRIMARY KEY (owner_id, contact_id),
+CHECK (owner_id != contact_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+blocker_id UUID REFERENCES users(id) ON DELETE CASCADE,
+blocked_id UUID REFERENCES users(id) ON DELETE CASCADE,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+PRIMARY KEY (blocker_id, blocked_id),
+CHECK (blocker_id != blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked_id ON user_blocks (blocked_id);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS privacy_private_chats VARCHAR(10) NOT NULL DEFAULT 'everyone'
+    CHECK (privacy_private_chats IN ('everyone', 'contacts', 'nobody'));
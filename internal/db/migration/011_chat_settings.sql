@@ -0,0 +1,22 @@
+-- Личные настройки чатов участника и пользовательские папки
+
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS muted_until TIMESTAMP;
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS pin_order INT; -- NULL — чат не закреплен
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS marked_unread BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS chat_folders (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+name VARCHAR(32) NOT NULL,
+position INT NOT NULL DEFAULT 0,
+include_chat_ids UUID[] NOT NULL DEFAULT '{}',
+exclude_chat_ids UUID[] NOT NULL DEFAULT '{}',
+include_types TEXT[] NOT NULL DEFAULT '{}', -- типы чатов, которые попадают в папку целиком
+unread_only BOOLEAN NOT NULL DEFAULT false,
+exclude_muted BOOLEAN NOT NULL DEFAULT false,
+exclude_archived BOOLEAN NOT NULL DEFAULT true,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_folders_user_id ON chat_folders (user_id);
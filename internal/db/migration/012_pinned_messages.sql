@@ -0,0 +1,15 @@
+-- Закрепленные сообщения и служебные (системные) сообщения
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS type VARCHAR(10) NOT NULL DEFAULT 'text' CHECK (type IN ('text', 'system'));
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS system_action JSONB; -- что произошло в чате, для type = 'system'
+
+CREATE TABLE IF NOT EXISTS pinned_messages (
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+pinned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+position INT NOT NULL, -- чем больше, тем позже закреплено
+pinned_at TIMESTAMP NOT NULL,
+PRIMARY KEY (chat_id, message_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pinned_messages_chat_position ON pinned_messages (chat_id, position DESC);
@@ -0,0 +1,9 @@
+-- Пересылка сообщений между чатами
+
+-- Исходное сообщение: вложения пересланной копии берутся из него, а не копируются
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+-- Автор и время оригинала на момент пересылки
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS forwarded_from JSONB;
+
+-- Запрет пересылать сообщения из чата
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS no_forwards BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1,15 @@
+-- Упоминания пользователей в сообщениях
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS entities JSONB; -- разметка текста, смещения в UTF-16
+
+CREATE TABLE IF NOT EXISTS message_mentions (
+message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+created_at TIMESTAMP NOT NULL,
+read_at TIMESTAMP,
+PRIMARY KEY (message_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_mentions_user ON message_mentions (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_message_mentions_unread ON message_mentions (user_id, chat_id) WHERE read_at IS NULL;
@@ -0,0 +1,5 @@
+-- Форматированный текст: content хранит текст без разметки, entities — разметку,
+-- source — исходник в формате format для редактирования
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS source TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS format VARCHAR(10) NOT NULL DEFAULT 'plain' CHECK (format IN ('plain', 'markdown', 'html'));
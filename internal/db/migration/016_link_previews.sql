@@ -0,0 +1,13 @@
+-- Превью ссылок: кэш по адресу и снимок превью в сообщении
+
+CREATE TABLE IF NOT EXISTS link_previews (
+url TEXT PRIMARY KEY,
+title TEXT,
+description TEXT,
+image_url TEXT,
+site_name TEXT,
+failed BOOLEAN NOT NULL DEFAULT false, -- страницу не удалось загрузить или в ней нет данных для превью
+fetched_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS link_preview JSONB;
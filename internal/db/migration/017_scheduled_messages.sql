@@ -0,0 +1,19 @@
+-- Отложенные сообщения
+
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+sender_id UUID REFERENCES users(id) ON DELETE CASCADE,
+content TEXT NOT NULL,
+format VARCHAR(10) NOT NULL DEFAULT 'plain' CHECK (format IN ('plain', 'markdown', 'html')),
+send_at TIMESTAMP NOT NULL,
+status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'failed')),
+claimed_at TIMESTAMP, -- когда экземпляр сервера взял сообщение на отправку
+attempts INT NOT NULL DEFAULT 0,
+last_error TEXT,
+created_at TIMESTAMP NOT NULL,
+updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages (send_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_sender ON scheduled_messages (sender_id, send_at);
@@ -0,0 +1,6 @@
+-- Исчезающие сообщения: таймер чата и срок жизни сообщения
+
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS message_ttl INT; -- в секундах; NULL — автоудаление выключено
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_messages_expires_at ON messages (expires_at) WHERE expires_at IS NOT NULL;
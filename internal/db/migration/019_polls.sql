@@ -0,0 +1,41 @@
+-- Опросы
+
+-- Ограничение пересоздается один раз: замена блокирует таблицу и заново проверяет все сообщения
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conrelid = 'messages'::regclass AND conname = 'messages_type_check'
+          AND pg_get_constraintdef(oid) LIKE '%''poll''%'
+    ) THEN
+        ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_type_check;
+        ALTER TABLE messages ADD CONSTRAINT messages_type_check CHECK (type IN ('text', 'system', 'poll'));
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS polls (
+message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE,
+question TEXT NOT NULL,
+multiple BOOLEAN NOT NULL DEFAULT false,
+anonymous BOOLEAN NOT NULL DEFAULT true,
+quiz BOOLEAN NOT NULL DEFAULT false,
+correct_option INT, -- номер правильного варианта в викторине
+closes_at TIMESTAMP,
+closed_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS poll_options (
+message_id UUID REFERENCES polls(message_id) ON DELETE CASCADE,
+position INT NOT NULL,
+text TEXT NOT NULL,
+PRIMARY KEY (message_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS poll_votes (
+message_id UUID REFERENCES polls(message_id) ON DELETE CASCADE,
+option INT NOT NULL,
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+voted_at TIMESTAMP NOT NULL,
+PRIMARY KEY (message_id, user_id, option),
+FOREIGN KEY (message_id, option) REFERENCES poll_options(message_id, position) ON DELETE CASCADE
+);
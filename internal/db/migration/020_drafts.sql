@@ -0,0 +1,10 @@
+-- Черновики: один на пользователя в каждом чате, общий для всех устройств
+
+CREATE TABLE IF NOT EXISTS drafts (
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+text TEXT NOT NULL DEFAULT '',
+reply_to_message_id UUID REFERENCES messages(id) ON DELETE SET NULL,
+updated_at TIMESTAMP NOT NULL,
+PRIMARY KEY (user_id, chat_id)
+);
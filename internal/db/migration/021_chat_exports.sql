@@ -0,0 +1,22 @@
+-- Экспорт истории чата в архив
+
+CREATE TABLE IF NOT EXISTS chat_exports (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+requested_by UUID REFERENCES users(id) ON DELETE CASCADE,
+status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+exported INT NOT NULL DEFAULT 0, -- сколько сообщений уже записано
+total INT NOT NULL DEFAULT 0,
+blob_key TEXT, -- ключ готового архива в хранилище файлов
+error TEXT,
+attempts INT NOT NULL DEFAULT 0,
+claimed_at TIMESTAMP, -- аренда задачи, продлевается с каждым отчетом о прогрессе
+created_at TIMESTAMP NOT NULL,
+finished_at TIMESTAMP,
+expires_at TIMESTAMP -- когда архив будет удален
+);
+
+-- Одновременно у пользователя идет не больше одной выгрузки чата
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_exports_active ON chat_exports (chat_id, requested_by) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_chat_exports_queue ON chat_exports (created_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_chat_exports_expires_at ON chat_exports (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,44 @@
+-- Импорт истории из других мессенджеров
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS placeholder BOOLEAN NOT NULL DEFAULT false; -- автор импортированных сообщений без своего аккаунта
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS reply_to_message_id UUID REFERENCES messages(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS chat_imports (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+requested_by UUID REFERENCES users(id) ON DELETE CASCADE,
+source VARCHAR(10) NOT NULL CHECK (source IN ('telegram', 'slack')),
+name VARCHAR(100), -- название нового чата; по умолчанию берется из выгрузки
+channel TEXT, -- канал в выгрузке Slack
+user_map JSONB, -- явное сопоставление: ID автора в выгрузке -> логин или email
+blob_key TEXT NOT NULL, -- загруженная выгрузка в хранилище файлов
+status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+chat_id UUID REFERENCES chats(id) ON DELETE SET NULL,
+processed INT NOT NULL DEFAULT 0, -- сколько сообщений выгрузки обработано; с этого места импорт продолжается
+total INT NOT NULL DEFAULT 0,
+imported INT NOT NULL DEFAULT 0,
+skipped INT NOT NULL DEFAULT 0,
+report JSONB NOT NULL DEFAULT '[]', -- пропущенное и потерянное при переносе
+error TEXT,
+attempts INT NOT NULL DEFAULT 0,
+claimed_at TIMESTAMP, -- аренда задачи, продлевается с каждой записанной пачкой
+created_at TIMESTAMP NOT NULL,
+finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_imports_queue ON chat_imports (created_at) WHERE status IN ('pending', 'running');
+
+-- Сопоставления на время импорта: авторы и перенесенные сообщения (для ответов)
+CREATE TABLE IF NOT EXISTS chat_import_users (
+import_id UUID REFERENCES chat_imports(id) ON DELETE CASCADE,
+foreign_id TEXT NOT NULL,
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+placeholder BOOLEAN NOT NULL,
+PRIMARY KEY (import_id, foreign_id)
+);
+
+CREATE TABLE IF NOT EXISTS chat_import_messages (
+import_id UUID REFERENCES chat_imports(id) ON DELETE CASCADE,
+foreign_id TEXT NOT NULL,
+message_id UUID REFERENCES messages(id) ON DELETE CASCADE,
+PRIMARY KEY (import_id, foreign_id)
+);
@@ -0,0 +1,30 @@
+-- Выгрузка данных аккаунта и удаление аккаунта с отсрочкой
+
+-- Строка удаленного пользователя остается, чтобы у собеседников сохранилась история:
+-- личные данные стираются, а сам аккаунт помечается deleted_at
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP; -- когда аккаунт будет удален; NULL — удаление не запрошено
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_claimed_at TIMESTAMP; -- аренда задачи удаления
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_due ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL;
+-- Постраничная выгрузка сообщений пользователя; индекс idx_messages_sender_id из 007 покрывает только sender_id
+CREATE INDEX IF NOT EXISTS idx_messages_sender_created ON messages (sender_id, created_at, id);
+
+CREATE TABLE IF NOT EXISTS account_exports (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+exported INT NOT NULL DEFAULT 0, -- сколько сообщений пользователя уже записано
+blob_key TEXT, -- ключ готового архива в хранилище файлов
+error TEXT,
+attempts INT NOT NULL DEFAULT 0,
+claimed_at TIMESTAMP, -- аренда задачи, продлевается с каждой страницей сообщений
+created_at TIMESTAMP NOT NULL,
+finished_at TIMESTAMP,
+expires_at TIMESTAMP -- когда архив будет удален
+);
+
+-- Одновременно у пользователя идет не больше одной выгрузки аккаунта
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_exports_active ON account_exports (user_id) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_account_exports_queue ON account_exports (created_at) WHERE status IN ('pending', 'running');
+CREATE INDEX IF NOT EXISTS idx_account_exports_expires_at ON account_exports (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,12 @@
+-- Глобальные роли пользователей и блокировка аккаунтов
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(10) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspended_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS suspend_reason VARCHAR(500);
+-- Сессии, выданные раньше этого времени, недействительны: после сброса пароля и блокировки
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_valid_after TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_created_at ON users (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_suspended ON users (suspended_at) WHERE suspended_at IS NOT NULL;
@@ -0,0 +1,22 @@
+-- Подтверждение авторства импортированных сообщений
+
+-- Задача импорта, которой перенесено сообщение. Внешнего ключа нет, чтобы отметка
+-- оставалась и после удаления задачи.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS import_id UUID;
+CREATE INDEX IF NOT EXISTS idx_messages_import_id ON messages (import_id, sender_id) WHERE import_id IS NOT NULL;
+
+-- Пользователь, которому после импорта будет предложено стать автором вместо заглушки
+ALTER TABLE chat_import_users ADD COLUMN IF NOT EXISTS candidate_id UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Сообщения заглушки переходят к пользователю только после того, как он подтвердит авторство
+CREATE TABLE IF NOT EXISTS chat_import_claims (
+id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+import_id UUID REFERENCES chat_imports(id) ON DELETE CASCADE,
+chat_id UUID REFERENCES chats(id) ON DELETE CASCADE,
+placeholder_id UUID REFERENCES users(id) ON DELETE CASCADE,
+user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+created_at TIMESTAMP NOT NULL,
+UNIQUE (import_id, placeholder_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_chat_import_claims_user_id ON chat_import_claims (user_id);
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"messenger/internal/service"
+	"messenger/internal/storage"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{accountService: accountService}
+}
+
+// RequestExport ставит выгрузку данных аккаунта в очередь; о готовности архива клиент узнает по WebSocket
+func (h *AccountHandler) RequestExport(c *gin.Context) {
+	val, _ := c.Get("userID")
+	export, err := h.accountService.RequestExport(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, export)
+}
+
+func (h *AccountHandler) GetExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	export, err := h.accountService.GetExport(exportID, val.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+func (h *AccountHandler) DownloadExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	archive, export, err := h.accountService.OpenExport(exportID, val.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) || errors.Is(err, storage.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	defer archive.Close()
+
+	name := fmt.Sprintf("account-export-%s.zip", export.CreatedAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Type", "application/zip")
+	modified := time.Time{}
+	if export.FinishedAt != nil {
+		modified = *export.FinishedAt
+	}
+	http.ServeContent(c.Writer, c.Request, name, modified, archive)
+}
+
+// DeleteAccount назначает удаление аккаунта; тело запроса — {"password": "..."}
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password is required"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	deletion, err := h.accountService.ScheduleDeletion(val.(uuid.UUID), req.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+func (h *AccountHandler) CancelDeletion(c *gin.Context) {
+	val, _ := c.Get("userID")
+	if err := h.accountService.CancelDeletion(val.(uuid.UUID)); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+// ListUsers — GET /api/admin/users?q=&role=&status=&bot=&limit=&offset=
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	filter := model.UserListFilter{
+		Query: c.Query("q"),
+		Role:  model.UserRole(c.Query("role")),
+		State: c.Query("status"),
+	}
+	if raw := c.Query("bot"); raw != "" {
+		bots, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bot filter"})
+			return
+		}
+		filter.Bots = &bots
+	}
+	for _, p := range []struct {
+		name   string
+		target *int
+	}{
+		{"limit", &filter.Limit},
+		{"offset", &filter.Offset},
+	} {
+		if raw := c.Query(p.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + p.name})
+				return
+			}
+			*p.target = n
+		}
+	}
+
+	list, err := h.adminService.ListUsers(filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	user, err := h.adminService.GetUser(userID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) GetUserChats(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	chats, err := h.adminService.GetUserChats(userID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, chats)
+}
+
+// SuspendUser блокирует аккаунт; тело запроса — {"reason": "..."}, причина необязательна
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+	}
+
+	actorID, _ := c.Get("userID")
+	user, err := h.adminService.Suspend(actorID.(uuid.UUID), userID, req.Reason)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) UnsuspendUser(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	actorID, _ := c.Get("userID")
+	user, err := h.adminService.Unsuspend(actorID.(uuid.UUID), userID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// ResetPassword отвечает временным паролем; он показывается только в этом ответе
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	actorID, _ := c.Get("userID")
+	password, err := h.adminService.ResetPassword(actorID.(uuid.UUID), userID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"temporary_password": password})
+}
+
+// SetRole — тело запроса {"role": "user" | "moderator" | "admin"}
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, ok := parseAdminUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Role model.UserRole `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Role == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role is required"})
+		return
+	}
+
+	actorID, _ := c.Get("userID")
+	user, err := h.adminService.SetRole(actorID.(uuid.UUID), userID, req.Role)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AdminHandler) GetChat(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+	overview, err := h.adminService.GetChat(chatID)
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, overview)
+}
+
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	stats, err := h.adminService.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func parseAdminUserID(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrChatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInsufficientRole):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
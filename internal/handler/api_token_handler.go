@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type APITokenHandler struct {
+	tokenService *service.APITokenService
+}
+
+func NewAPITokenHandler(tokenService *service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{tokenService: tokenService}
+}
+
+type CreateAPITokenRequest struct {
+	Name      string        `json:"name" binding:"required"`
+	Scopes    []model.Scope `json:"scopes" binding:"required"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+}
+
+func (h *APITokenHandler) CreateToken(c *gin.Context) {
+	var req CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	token, plaintext, err := h.tokenService.CreateToken(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Открытое значение токена отдается только при создании
+	c.JSON(http.StatusCreated, gin.H{
+		"token":     plaintext,
+		"api_token": token,
+	})
+}
+
+func (h *APITokenHandler) ListTokens(c *gin.Context) {
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	tokens, err := h.tokenService.ListTokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *APITokenHandler) RevokeToken(c *gin.Context) {
+	tokenID, err := uuid.Parse(c.Param("token_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid token id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	if err := h.tokenService.RevokeToken(tokenID, userID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
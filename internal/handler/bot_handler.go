@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type BotHandler struct {
+	botService     *service.BotService
+	messageService *service.MessageService
+}
+
+func NewBotHandler(botService *service.BotService, messageService *service.MessageService) *BotHandler {
+	return &BotHandler{botService: botService, messageService: messageService}
+}
+
+// --- Управление ботами владельцем ---
+
+type CreateBotRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+func (h *BotHandler) CreateBot(c *gin.Context) {
+	var req CreateBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	ownerID := val.(uuid.UUID)
+
+	bot, token, err := h.botService.CreateBot(ownerID, req.Username)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bot": bot, "token": token})
+}
+
+func (h *BotHandler) ListBots(c *gin.Context) {
+	val, _ := c.Get("userID")
+	ownerID := val.(uuid.UUID)
+
+	bots, err := h.botService.ListBots(ownerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, bots)
+}
+
+func (h *BotHandler) RegenerateToken(c *gin.Context) {
+	botID, err := uuid.Parse(c.Param("bot_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bot id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	ownerID := val.(uuid.UUID)
+
+	token, err := h.botService.RegenerateToken(botID, ownerID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": token})
+}
+
+func (h *BotHandler) DeleteBot(c *gin.Context) {
+	botID, err := uuid.Parse(c.Param("bot_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bot id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	ownerID := val.(uuid.UUID)
+
+	if err := h.botService.DeleteBot(botID, ownerID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// --- Bot API: запросы от имени самого бота ---
+
+func (h *BotHandler) GetMe(c *gin.Context) {
+	val, _ := c.Get("bot")
+	c.JSON(http.StatusOK, val.(*model.Bot))
+}
+
+type BotSendMessageRequest struct {
+	ChatID  uuid.UUID `json:"chat_id" binding:"required"`
+	Content string    `json:"content" binding:"required"`
+}
+
+// SendMessage отправляет сообщение от имени бота; так же бот отвечает на команды —
+// chat_id берется из полученного обновления
+func (h *BotHandler) SendMessage(c *gin.Context) {
+	var req BotSendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	m := model.Message{
+		ChatID:   req.ChatID,
+		SenderID: val.(uuid.UUID),
+		Content:  req.Content,
+	}
+
+	if err := h.messageService.SendMessage(&m); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, m)
+}
+
+func (h *BotHandler) GetUpdates(c *gin.Context) {
+	offset, _ := strconv.ParseInt(c.Query("offset"), 10, 64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	timeout, _ := strconv.Atoi(c.Query("timeout"))
+
+	val, _ := c.Get("userID")
+	botID := val.(uuid.UUID)
+
+	updates, err := h.botService.GetUpdates(c.Request.Context(), botID, offset, limit, time.Duration(timeout)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, updates)
+}
+
+type SetWebhookRequest struct {
+	URL    string `json:"url" binding:"required"`
+	Secret string `json:"secret"`
+}
+
+func (h *BotHandler) SetWebhook(c *gin.Context) {
+	var req SetWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	botID := val.(uuid.UUID)
+
+	if err := h.botService.SetWebhook(botID, req.URL, req.Secret); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *BotHandler) DeleteWebhook(c *gin.Context) {
+	val, _ := c.Get("userID")
+	botID := val.(uuid.UUID)
+
+	if err := h.botService.SetWebhook(botID, "", ""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *BotHandler) SetCommands(c *gin.Context) {
+	var commands []model.BotCommand
+	if err := c.ShouldBindJSON(&commands); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	botID := val.(uuid.UUID)
+
+	if err := h.botService.SetCommands(botID, commands); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *BotHandler) GetCommands(c *gin.Context) {
+	val, _ := c.Get("userID")
+	botID := val.(uuid.UUID)
+
+	commands, err := h.botService.GetCommands(botID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, commands)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"messenger/internal/service"
+	"messenger/internal/storage"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ChatExportHandler struct {
+	exportService *service.ChatExportService
+}
+
+func NewChatExportHandler(exportService *service.ChatExportService) *ChatExportHandler {
+	return &ChatExportHandler{exportService: exportService}
+}
+
+// RequestExport ставит выгрузку в очередь; о прогрессе и готовности архива клиент узнает по WebSocket
+func (h *ChatExportHandler) RequestExport(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	export, err := h.exportService.RequestExport(chatID, val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, export)
+}
+
+func (h *ChatExportHandler) GetExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	export, err := h.exportService.GetExport(exportID, val.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+func (h *ChatExportHandler) DownloadExport(c *gin.Context) {
+	exportID, err := uuid.Parse(c.Param("export_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	archive, export, err := h.exportService.OpenArchive(exportID, val.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, service.ErrExportNotFound) || errors.Is(err, storage.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	defer archive.Close()
+
+	name := fmt.Sprintf("chat-export-%s.zip", export.CreatedAt.Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Type", "application/zip")
+	modified := time.Time{}
+	if export.FinishedAt != nil {
+		modified = *export.FinishedAt
+	}
+	http.ServeContent(c.Writer, c.Request, name, modified, archive)
+}
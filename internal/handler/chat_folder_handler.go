@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ChatFolderHandler struct {
+	folderService *service.ChatFolderService
+}
+
+func NewChatFolderHandler(folderService *service.ChatFolderService) *ChatFolderHandler {
+	return &ChatFolderHandler{folderService: folderService}
+}
+
+func (h *ChatFolderHandler) CreateFolder(c *gin.Context) {
+	var f model.ChatFolder
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.folderService.CreateFolder(val.(uuid.UUID), &f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, f)
+}
+
+func (h *ChatFolderHandler) ListFolders(c *gin.Context) {
+	val, _ := c.Get("userID")
+	folders, err := h.folderService.ListFolders(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if folders == nil {
+		folders = []model.ChatFolder{}
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+func (h *ChatFolderHandler) UpdateFolder(c *gin.Context) {
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder id"})
+		return
+	}
+
+	var f model.ChatFolder
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	f.ID = folderID
+
+	val, _ := c.Get("userID")
+	if err := h.folderService.UpdateFolder(val.(uuid.UUID), &f); err != nil {
+		respondFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, f)
+}
+
+func (h *ChatFolderHandler) DeleteFolder(c *gin.Context) {
+	folderID, err := uuid.Parse(c.Param("folder_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.folderService.DeleteFolder(val.(uuid.UUID), folderID); err != nil {
+		respondFolderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func respondFolderError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrChatFolderNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"messenger/internal/model"
 	"messenger/internal/service"
 	"net/http"
 
@@ -84,7 +85,112 @@ func (h *ChatHandler) GetUserChats(c *gin.Context) {
 		return
 	}
 
-	chats, _ := h.chatService.GetUserChats(userID)
+	// ?folder_id= показывает чаты папки, ?archived=true — архив
+	var filter model.ChatListFilter
+	if folderID := c.Query("folder_id"); folderID != "" {
+		id, err := uuid.Parse(folderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid folder_id"})
+			return
+		}
+		filter.FolderID = &id
+	}
+	filter.Archived = c.Query("archived") == "true"
+
+	chats, _ := h.chatService.GetUserChats(userID, filter)
 
 	c.JSON(http.StatusOK, chats)
 }
+
+func (h *ChatHandler) UpdateSettings(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var upd model.ChatSettingsUpdate
+	if err := c.ShouldBindJSON(&upd); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.chatService.UpdateSettings(chatID, val.(uuid.UUID), &upd); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+type ReorderPinsRequest struct {
+	ChatIDs []uuid.UUID `json:"chat_ids"`
+}
+
+func (h *ChatHandler) ReorderPins(c *gin.Context) {
+	var req ReorderPinsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.chatService.ReorderPins(val.(uuid.UUID), req.ChatIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+type ChatRestrictionsRequest struct {
+	NoForwards bool `json:"no_forwards"`
+}
+
+func (h *ChatHandler) UpdateRestrictions(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req ChatRestrictionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.chatService.SetNoForwards(chatID, val.(uuid.UUID), req.NoForwards); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+type AddBotRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+func (h *ChatHandler) AddBot(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req AddBotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	if err := h.chatService.AddBotToGroup(chatID, userID, req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
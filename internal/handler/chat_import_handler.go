@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ChatImportHandler struct {
+	importService *service.ChatImportService
+}
+
+func NewChatImportHandler(importService *service.ChatImportService) *ChatImportHandler {
+	return &ChatImportHandler{importService: importService}
+}
+
+// StartImport принимает форму multipart/form-data: файл выгрузки в поле "file", источник
+// в "source" (telegram или slack) и необязательные "name", "channel" и "user_map" — JSON-объект
+// вида {"ID автора в выгрузке": "логин или email"}. Сообщения авторов записываются от имени
+// заглушек; найденным пользователям после импорта предлагается подтвердить авторство.
+func (h *ChatImportHandler) StartImport(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxImportSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "export file is required"})
+		return
+	}
+	if file.Size > service.MaxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "export file is too large"})
+		return
+	}
+
+	i := &model.ChatImport{
+		Source:  c.PostForm("source"),
+		Name:    c.PostForm("name"),
+		Channel: c.PostForm("channel"),
+	}
+	if raw := c.PostForm("user_map"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &i.UserMap); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "user_map must be a JSON object"})
+			return
+		}
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read export file"})
+		return
+	}
+	defer f.Close()
+
+	val, _ := c.Get("userID")
+	i.RequestedBy = val.(uuid.UUID)
+	if err := h.importService.StartImport(i, f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, i)
+}
+
+func (h *ChatImportHandler) GetImport(c *gin.Context) {
+	importID, err := uuid.Parse(c.Param("import_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid import id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	i, err := h.importService.GetImport(importID, val.(uuid.UUID))
+	if err != nil {
+		if errors.Is(err, service.ErrImportNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, i)
+}
+
+// ListClaims — предложения подтвердить авторство импортированных сообщений
+func (h *ChatImportHandler) ListClaims(c *gin.Context) {
+	val, _ := c.Get("userID")
+	claims, err := h.importService.ListClaims(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, claims)
+}
+
+// AcceptClaim переводит сообщения заглушки на текущего пользователя и добавляет его в чат
+func (h *ChatImportHandler) AcceptClaim(c *gin.Context) {
+	claimID, ok := parseClaimID(c)
+	if !ok {
+		return
+	}
+	val, _ := c.Get("userID")
+	claim, err := h.importService.AcceptClaim(claimID, val.(uuid.UUID))
+	if err != nil {
+		respondClaimError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, claim)
+}
+
+func (h *ChatImportHandler) DeclineClaim(c *gin.Context) {
+	claimID, ok := parseClaimID(c)
+	if !ok {
+		return
+	}
+	val, _ := c.Get("userID")
+	if err := h.importService.DeclineClaim(claimID, val.(uuid.UUID)); err != nil {
+		respondClaimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func parseClaimID(c *gin.Context) (uuid.UUID, bool) {
+	claimID, err := uuid.Parse(c.Param("claim_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid claim id"})
+		return uuid.Nil, false
+	}
+	return claimID, true
+}
+
+func respondClaimError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrImportClaimNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
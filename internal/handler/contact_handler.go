@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type ContactHandler struct {
+	contactService *service.ContactService
+}
+
+func NewContactHandler(contactService *service.ContactService) *ContactHandler {
+	return &ContactHandler{contactService: contactService}
+}
+
+type AddContactRequest struct {
+	UserID     uuid.UUID `json:"user_id" binding:"required"`
+	CustomName string    `json:"custom_name"`
+}
+
+func (h *ContactHandler) AddContact(c *gin.Context) {
+	var req AddContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.contactService.AddContact(val.(uuid.UUID), req.UserID, req.CustomName); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *ContactHandler) ListContacts(c *gin.Context) {
+	val, _ := c.Get("userID")
+	contacts, err := h.contactService.ListContacts(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if contacts == nil {
+		contacts = []model.Contact{}
+	}
+	c.JSON(http.StatusOK, contacts)
+}
+
+func (h *ContactHandler) RemoveContact(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.contactService.RemoveContact(val.(uuid.UUID), userID); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+type BlockUserRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+}
+
+func (h *ContactHandler) BlockUser(c *gin.Context) {
+	var req BlockUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.contactService.Block(val.(uuid.UUID), req.UserID); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *ContactHandler) ListBlocked(c *gin.Context) {
+	val, _ := c.Get("userID")
+	blocked, err := h.contactService.ListBlocked(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if blocked == nil {
+		blocked = []model.BlockedUser{}
+	}
+	c.JSON(http.StatusOK, blocked)
+}
+
+func (h *ContactHandler) UnblockUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.contactService.Unblock(val.(uuid.UUID), userID); err != nil {
+		respondContactError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func respondContactError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrContactNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
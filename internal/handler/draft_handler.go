@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DraftHandler struct {
+	draftService *service.DraftService
+}
+
+func NewDraftHandler(draftService *service.DraftService) *DraftHandler {
+	return &DraftHandler{draftService: draftService}
+}
+
+type SaveDraftRequest struct {
+	Text             string     `json:"text"`
+	ReplyToMessageID *uuid.UUID `json:"reply_to_message_id"`
+}
+
+func (h *DraftHandler) GetDraft(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	draft, err := h.draftService.GetDraft(chatID, val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if draft == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// SaveDraft сохраняет черновик; пустой текст без ответа удаляет его
+func (h *DraftHandler) SaveDraft(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	// Подключение, из которого клиент сохраняет черновик, не получает его обратно
+	connectionID, _ := uuid.Parse(c.GetHeader("X-Connection-ID"))
+
+	val, _ := c.Get("userID")
+	draft, err := h.draftService.SaveDraft(val.(uuid.UUID), connectionID, &model.Draft{
+		ChatID:           chatID,
+		Text:             req.Text,
+		ReplyToMessageID: req.ReplyToMessageID,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "reply target not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if draft == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type IncomingWebhookHandler struct {
+	incomingWebhookService *service.IncomingWebhookService
+}
+
+func NewIncomingWebhookHandler(incomingWebhookService *service.IncomingWebhookService) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{incomingWebhookService: incomingWebhookService}
+}
+
+type CreateIncomingWebhookRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func (h *IncomingWebhookHandler) CreateWebhook(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req CreateIncomingWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	webhook, token, err := h.incomingWebhookService.CreateWebhook(chatID, userID, req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Адрес содержит секретный токен и показывается только при создании
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": webhook,
+		"url":     "/hooks/" + token,
+	})
+}
+
+func (h *IncomingWebhookHandler) ListWebhooks(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	webhooks, err := h.incomingWebhookService.ListWebhooks(chatID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *IncomingWebhookHandler) RevokeWebhook(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+	webhookID, err := uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	if err := h.incomingWebhookService.RevokeWebhook(chatID, webhookID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+// Post принимает сообщение на секретный адрес вебхука; аутентификация — сам токен в адресе
+func (h *IncomingWebhookHandler) Post(c *gin.Context) {
+	var payload model.IncomingWebhookPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	message, err := h.incomingWebhookService.Post(c.Param("token"), payload)
+	switch {
+	case errors.Is(err, service.ErrIncomingWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, service.ErrRateLimited):
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
@@ -1,26 +1,38 @@
 package handler
 
 import (
+	"errors"
 	"messenger/internal/model"
 	"messenger/internal/service"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 type MessageHandler struct {
-	messageService *service.MessageService
+	messageService   *service.MessageService
+	scheduledService *service.ScheduledMessageService
+	draftService     *service.DraftService
 }
 
-func NewMessageHandler(messageService *service.MessageService) *MessageHandler {
-	return &MessageHandler{messageService: messageService}
+func NewMessageHandler(messageService *service.MessageService, scheduledService *service.ScheduledMessageService, draftService *service.DraftService) *MessageHandler {
+	return &MessageHandler{messageService: messageService, scheduledService: scheduledService, draftService: draftService}
+}
+
+type SendMessageRequest struct {
+	ChatID  uuid.UUID        `json:"chat_id"`
+	Content string           `json:"content"`
+	Format  model.TextFormat `json:"format"`  // plain (по умолчанию), markdown или html
+	SendAt  *time.Time       `json:"send_at"` // если задано, сообщение будет отправлено в это время
 }
 
 func (h *MessageHandler) SendMessage(c *gin.Context) {
-	var m model.Message
+	var req SendMessageRequest
 
-	if err := c.ShouldBindJSON(&m); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"ошибка": "недействительный текст запроса"})
 		return
 	}
@@ -30,16 +42,67 @@ func (h *MessageHandler) SendMessage(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"ошибка": "неавторизован"})
 		return
 	}
-	m.SenderID = val.(uuid.UUID)
+
+	// Подпись и вложения могут задавать только интеграции, поэтому из запроса берутся лишь чат, текст и его формат.
+	// Без формата текст сохраняется как есть: клиенты, не знающие о разметке, не должны ее получать.
+
+	if req.SendAt != nil {
+		scheduled := model.ScheduledMessage{
+			ChatID:   req.ChatID,
+			SenderID: val.(uuid.UUID),
+			Content:  req.Content,
+			Format:   req.Format,
+			SendAt:   *req.SendAt,
+		}
+		if err := h.scheduledService.Schedule(&scheduled); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusAccepted, scheduled)
+		return
+	}
+
+	m := model.Message{
+		ChatID:   req.ChatID,
+		SenderID: val.(uuid.UUID),
+		Content:  req.Content,
+		Format:   req.Format,
+	}
 
 	if err := h.messageService.SendMessage(&m); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"ошибка": err.Error()})
 		return
 	}
+	h.draftService.ClearOnSend(m.ChatID, m.SenderID, time.Now().UTC())
 
 	c.JSON(http.StatusCreated, m)
 }
 
+type ForwardMessagesRequest struct {
+	MessageIDs []uuid.UUID `json:"message_ids" binding:"required"`
+	ChatIDs    []uuid.UUID `json:"chat_ids" binding:"required"`
+}
+
+func (h *MessageHandler) ForwardMessages(c *gin.Context) {
+	var req ForwardMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	messages, err := h.messageService.ForwardMessages(val.(uuid.UUID), req.MessageIDs, req.ChatIDs)
+	if err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, messages)
+}
+
 func (h *MessageHandler) GetMessages(c *gin.Context) {
 	chatIDStr := c.Param("chat_id")
 	chatID, err := uuid.Parse(chatIDStr)
@@ -51,14 +114,16 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 	val, _ := c.Get("userID")
 	userID := val.(uuid.UUID)
 
-	// 1. Помечаем как прочитанные
-	// Мы делаем это ПЕРЕД получением, чтобы в ответе эти сообщения могли уже иметь статус прочитанных (по желанию)
-	_ = h.messageService.MarkChatAsRead(chatID, userID)
-
-	// 2. Получаем историю сообщений
-	messages, err := h.messageService.GetMessagesByChatID(chatID)
+	// 1. Получаем историю сообщений; заодно проверяется, что пользователь — участник чата
+	messages, err := h.messageService.GetMessagesByChatID(chatID, userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"ошибка": err.Error()})
+		respondChatAccessError(c, err)
+		return
+	}
+
+	// 2. Помечаем как прочитанные
+	if err := h.messageService.MarkChatAsRead(chatID, userID); err != nil {
+		respondChatAccessError(c, err)
 		return
 	}
 
@@ -66,14 +131,177 @@ func (h *MessageHandler) GetMessages(c *gin.Context) {
 }
 
 func (h *MessageHandler) MarkAsRead(c *gin.Context) {
-	chatID, _ := uuid.Parse(c.Param("chat_id"))
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
 	val, _ := c.Get("userID")
 	userID := val.(uuid.UUID)
 
 	if err := h.messageService.MarkChatAsRead(chatID, userID); err != nil {
+		respondChatAccessError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func respondChatAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotChatMember):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrChatNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// SearchMessages ищет сообщения в чатах пользователя.
+// Параметры: q, chat_id, sender_id, from, to (RFC3339), limit, cursor.
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	val, _ := c.Get("userID")
+	q := model.MessageSearchQuery{
+		UserID: val.(uuid.UUID),
+		Text:   c.Query("q"),
+	}
+
+	var err error
+	if q.ChatID, err = parseOptionalUUID(c.Query("chat_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat_id"})
 		return
 	}
+	if q.SenderID, err = parseOptionalUUID(c.Query("sender_id")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid sender_id"})
+		return
+	}
+	if q.From, err = parseOptionalTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	if q.To, err = parseOptionalTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	page, err := h.messageService.SearchMessages(q, c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
 
+// ListScheduled возвращает отложенные сообщения пользователя; chat_id — необязательный фильтр
+func (h *MessageHandler) ListScheduled(c *gin.Context) {
+	chatID, err := parseOptionalUUID(c.Query("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat_id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	messages, err := h.scheduledService.List(val.(uuid.UUID), chatID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+func (h *MessageHandler) UpdateScheduled(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("scheduled_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled message id"})
+		return
+	}
+
+	var upd model.ScheduledMessageUpdate
+	if err := c.ShouldBindJSON(&upd); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	m, err := h.scheduledService.Update(id, val.(uuid.UUID), &upd)
+	if err != nil {
+		if errors.Is(err, service.ErrScheduledMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+func (h *MessageHandler) CancelScheduled(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("scheduled_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scheduled message id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.scheduledService.Cancel(id, val.(uuid.UUID)); err != nil {
+		if errors.Is(err, service.ErrScheduledMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
+
+// GetMentions возвращает сообщения, в которых упомянут пользователь.
+// Параметры: unread (true — только непрочитанные), limit, cursor.
+func (h *MessageHandler) GetMentions(c *gin.Context) {
+	val, _ := c.Get("userID")
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	page, err := h.messageService.GetMentions(val.(uuid.UUID), c.Query("unread") == "true", limit, c.Query("cursor"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func parseOptionalUUID(raw string) (*uuid.UUID, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func parseOptionalTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
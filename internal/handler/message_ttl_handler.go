@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MessageTTLHandler struct {
+	ttlService *service.MessageTTLService
+}
+
+func NewMessageTTLHandler(ttlService *service.MessageTTLService) *MessageTTLHandler {
+	return &MessageTTLHandler{ttlService: ttlService}
+}
+
+// SetMessageTTLRequest — таймер в секундах: 0 — выключен, 86400 — сутки, 604800 — неделя
+// или произвольное значение в допустимых пределах
+type SetMessageTTLRequest struct {
+	TTLSeconds *int `json:"ttl_seconds" binding:"required"`
+}
+
+func (h *MessageTTLHandler) SetTTL(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req SetMessageTTLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	if err := h.ttlService.SetTTL(chatID, val.(uuid.UUID), *req.TTLSeconds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type PollHandler struct {
+	pollService *service.PollService
+}
+
+func NewPollHandler(pollService *service.PollService) *PollHandler {
+	return &PollHandler{pollService: pollService}
+}
+
+type CreatePollRequest struct {
+	Question      string     `json:"question" binding:"required"`
+	Options       []string   `json:"options" binding:"required"`
+	Multiple      bool       `json:"multiple"`
+	Anonymous     *bool      `json:"anonymous"` // по умолчанию голосование анонимное
+	Quiz          bool       `json:"quiz"`
+	CorrectOption *int       `json:"correct_option"`
+	ClosesAt      *time.Time `json:"closes_at"`
+}
+
+type VotePollRequest struct {
+	Options []int `json:"options" binding:"required"`
+}
+
+func (h *PollHandler) CreatePoll(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req CreatePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	poll := &model.Poll{
+		Question:      req.Question,
+		Multiple:      req.Multiple,
+		Anonymous:     req.Anonymous == nil || *req.Anonymous,
+		Quiz:          req.Quiz,
+		CorrectOption: req.CorrectOption,
+		ClosesAt:      req.ClosesAt,
+	}
+	for _, text := range req.Options {
+		poll.Options = append(poll.Options, model.PollOption{Text: text})
+	}
+
+	val, _ := c.Get("userID")
+	message, err := h.pollService.CreatePoll(chatID, val.(uuid.UUID), poll)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, message)
+}
+
+func (h *PollHandler) Vote(c *gin.Context) {
+	pollID, err := uuid.Parse(c.Param("poll_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+		return
+	}
+
+	var req VotePollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	poll, err := h.pollService.Vote(pollID, val.(uuid.UUID), req.Options)
+	if err != nil {
+		respondPollError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
+
+func (h *PollHandler) RetractVote(c *gin.Context) {
+	pollID, err := uuid.Parse(c.Param("poll_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	poll, err := h.pollService.Retract(pollID, val.(uuid.UUID))
+	if err != nil {
+		respondPollError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
+
+func (h *PollHandler) ClosePoll(c *gin.Context) {
+	pollID, err := uuid.Parse(c.Param("poll_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid poll id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	poll, err := h.pollService.Close(pollID, val.(uuid.UUID))
+	if err != nil {
+		respondPollError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, poll)
+}
+
+func respondPollError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPollNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrPollClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	}
+}
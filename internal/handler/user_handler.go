@@ -1,21 +1,25 @@
 package handler
 
 import (
+	"errors"
 	"messenger/internal/model"
 	"messenger/internal/service"
+	"messenger/internal/storage"
 	"messenger/internal/utils"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
 type UserHandler struct {
 	userService *service.UserService
+	keys        *utils.KeySet
 }
 
-func NewUserHandler(userService *service.UserService) *UserHandler {
-	return &UserHandler{userService: userService}
+func NewUserHandler(userService *service.UserService, keys *utils.KeySet) *UserHandler {
+	return &UserHandler{userService: userService, keys: keys}
 
 }
 
@@ -47,12 +51,16 @@ func (h *UserHandler) Login(c *gin.Context) {
 	// Сервис должен проверить пароль и вернуть пользователя
 	user, err := h.userService.LoginUser(req.Email, req.Password)
 	if err != nil {
+		if errors.Is(err, service.ErrAccountSuspended) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
 	// Генерируем токен
-	token, err := utils.GenerateJWT(user.ID, "your_secret_key", 24*time.Hour)
+	token, err := utils.GenerateJWT(user.ID, h.keys, h.keys.TokenTTL())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
 		return
@@ -83,3 +91,134 @@ func (h *UserHandler) SearchUsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, users)
 }
+
+func (h *UserHandler) GetMe(c *gin.Context) {
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	user, err := h.userService.GetProfile(userID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	var upd model.ProfileUpdate
+	if err := c.ShouldBindJSON(&upd); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	user, err := h.userService.UpdateProfile(val.(uuid.UUID), &upd)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UploadAvatar принимает изображение в поле "avatar" формы multipart/form-data
+func (h *UserHandler) UploadAvatar(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxAvatarSize+1<<20)
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "avatar file is required"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read avatar"})
+		return
+	}
+	defer f.Close()
+
+	val, _ := c.Get("userID")
+	user, err := h.userService.SetAvatar(val.(uuid.UUID), f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) DeleteAvatar(c *gin.Context) {
+	val, _ := c.Get("userID")
+	user, err := h.userService.DeleteAvatar(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) GetUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	user, err := h.userService.GetProfile(val.(uuid.UUID), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *UserHandler) GetAvatar(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	avatar, err := h.userService.OpenAvatar(userID)
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, storage.ErrBlobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "avatar not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer avatar.Close()
+
+	// Ссылка на аватар меняется вместе с файлом, поэтому кешировать можно долго
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, avatar)
+}
+
+func (h *UserHandler) GetPrivacy(c *gin.Context) {
+	val, _ := c.Get("userID")
+	settings, err := h.userService.GetPrivacy(val.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+func (h *UserHandler) UpdatePrivacy(c *gin.Context) {
+	var settings model.PrivacySettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	updated, err := h.userService.UpdatePrivacy(val.(uuid.UUID), &settings)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"messenger/internal/model"
+	"messenger/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService: webhookService}
+}
+
+type CreateWebhookRequest struct {
+	URL    string               `json:"url" binding:"required"`
+	Events []model.WebhookEvent `json:"events"`
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	webhook, err := h.webhookService.CreateWebhook(chatID, userID, req.URL, req.Events)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	webhooks, err := h.webhookService.ListWebhooks(chatID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	chatID, webhookID, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	if err := h.webhookService.DeleteWebhook(chatID, webhookID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *WebhookHandler) EnableWebhook(c *gin.Context) {
+	chatID, webhookID, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	if err := h.webhookService.EnableWebhook(chatID, webhookID, userID); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "success"})
+}
+
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	chatID, webhookID, ok := parseWebhookParams(c)
+	if !ok {
+		return
+	}
+
+	val, _ := c.Get("userID")
+	userID := val.(uuid.UUID)
+
+	deliveries, err := h.webhookService.ListDeliveries(chatID, webhookID, userID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func parseWebhookParams(c *gin.Context) (chatID, webhookID uuid.UUID, ok bool) {
+	chatID, err := uuid.Parse(c.Param("chat_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid chat id"})
+		return chatID, webhookID, false
+	}
+	webhookID, err = uuid.Parse(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook id"})
+		return chatID, webhookID, false
+	}
+	return chatID, webhookID, true
+}
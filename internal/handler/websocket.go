@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	gw "github.com/gorilla/websocket"
 )
 
@@ -16,14 +17,14 @@ var upgrader = gw.Upgrader{
 }
 
 type WebSocketHandler struct {
-	hub       *websocket.Hub
-	jwtSecret string
+	hub  *websocket.Hub
+	keys *utils.KeySet
 }
 
-func NewWebSocketHandler(hub *websocket.Hub, jwtSecret string) *WebSocketHandler {
+func NewWebSocketHandler(hub *websocket.Hub, keys *utils.KeySet) *WebSocketHandler {
 	return &WebSocketHandler{
-		hub:       hub,
-		jwtSecret: jwtSecret,
+		hub:  hub,
+		keys: keys,
 	}
 }
 
@@ -34,7 +35,7 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 		return
 	}
 
-	claims, err := utils.VerifyJWT(token, h.jwtSecret)
+	claims, err := utils.VerifyJWT(token, h.keys)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
@@ -46,7 +47,7 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	}
 
 	client := &websocket.Client{
-		ID:     claims.UserID,
+		ID:     uuid.New(),
 		UserID: claims.UserID,
 		Conn:   conn,
 		Send:   make(chan []byte, 256),
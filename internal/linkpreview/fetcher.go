@@ -0,0 +1,107 @@
+// Package linkpreview загружает страницы по ссылкам из сообщений и собирает из них
+// превью по разметке Open Graph.
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"messenger/internal/model"
+	"messenger/internal/netguard"
+	"mime"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+var (
+	ErrNotHTML   = errors.New("linkpreview: response is not an HTML page")
+	ErrNoPreview = errors.New("linkpreview: page has no preview data")
+)
+
+// Config ограничивает загрузку страниц. Нулевые поля заменяются значениями по умолчанию.
+type Config struct {
+	Timeout      time.Duration // на весь запрос вместе с редиректами
+	MaxBodyBytes int64         // сколько байт страницы читается, остальное отбрасывается
+	MaxRedirects int
+	UserAgent    string
+
+	// AllowAddr решает, можно ли подключаться к адресу. По умолчанию разрешены только
+	// публичные адреса (netguard.IsPublicAddr); тесты могут разрешить локальный HTTP-сервер.
+	AllowAddr func(netip.Addr) bool
+}
+
+// Fetcher загружает страницы с защитой от SSRF, см. netguard
+type Fetcher struct {
+	cfg    Config
+	client *http.Client
+}
+
+func NewFetcher(cfg Config) *Fetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = 1 << 20
+	}
+	if cfg.MaxRedirects <= 0 {
+		cfg.MaxRedirects = 3
+	}
+	if cfg.UserAgent == "" {
+		cfg.UserAgent = "MessengerLinkPreview/1.0"
+	}
+
+	return &Fetcher{cfg: cfg, client: netguard.NewClient(netguard.Config{
+		Timeout:      cfg.Timeout,
+		MaxRedirects: cfg.MaxRedirects,
+		AllowAddr:    cfg.AllowAddr,
+	})}
+}
+
+// Fetch загружает страницу и возвращает ее превью
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*model.LinkPreview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("linkpreview: unsupported url %q", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.cfg.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("linkpreview: unexpected status %d", resp.StatusCode)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "text/html" && mediaType != "application/xhtml+xml") {
+		return nil, ErrNotHTML
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.cfg.MaxBodyBytes), contentType)
+	if err != nil {
+		return nil, err
+	}
+	preview := parsePage(body, resp.Request.URL)
+	if preview.Title == "" && preview.Description == "" {
+		return nil, ErrNoPreview
+	}
+	preview.URL = rawURL
+	return preview, nil
+}
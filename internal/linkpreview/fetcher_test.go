@@ -0,0 +1,141 @@
+package linkpreview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"messenger/internal/netguard"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const ogPage = `<!doctype html>
+<html><head>
+<title>Заголовок из title</title>
+<meta property="og:title" content="  Статья   про Go ">
+<meta property="og:description" content="Описание статьи">
+<meta property="og:site_name" content="Example">
+<meta property="og:image" content="/img/cover.png">
+<meta name="description" content="запасное описание">
+</head><body><meta property="og:title" content="из body не читается"></body></html>`
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, ogPage)
+	})
+	mux.HandleFunc("/title-only", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Просто заголовок</title></head></html>`)
+	})
+	mux.HandleFunc("/huge", func(w http.ResponseWriter, r *http.Request) {
+		// Метаданные идут после большого блока: за пределами лимита они не должны читаться
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<html><head><!--"+strings.Repeat("x", 4096)+"-->")
+		fmt.Fprint(w, `<meta property="og:title" content="поздно"></head></html>`)
+	})
+	mux.HandleFunc("/image", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG"))
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		// /redirect/N перенаправляет N раз, затем на /article
+		var n int
+		fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/redirect/"), "%d", &n)
+		target := "/article"
+		if n > 1 {
+			target = fmt.Sprintf("/redirect/%d", n-1)
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func allowLoopback(addr netip.Addr) bool {
+	return addr.IsLoopback()
+}
+
+func TestFetchOpenGraph(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Config{AllowAddr: allowLoopback})
+
+	p, err := f.Fetch(context.Background(), srv.URL+"/article")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != srv.URL+"/article" {
+		t.Errorf("URL = %q", p.URL)
+	}
+	if p.Title != "Статья про Go" {
+		t.Errorf("Title = %q", p.Title)
+	}
+	if p.Description != "Описание статьи" {
+		t.Errorf("Description = %q", p.Description)
+	}
+	if p.SiteName != "Example" {
+		t.Errorf("SiteName = %q", p.SiteName)
+	}
+	if p.ImageURL != srv.URL+"/img/cover.png" {
+		t.Errorf("ImageURL = %q, want absolute URL", p.ImageURL)
+	}
+
+	p, err = f.Fetch(context.Background(), srv.URL+"/title-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Просто заголовок" || p.Description != "" {
+		t.Errorf("title fallback = %+v", p)
+	}
+}
+
+func TestFetchBlocksLoopbackByDefault(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Config{})
+
+	_, err := f.Fetch(context.Background(), srv.URL+"/article")
+	if !errors.Is(err, netguard.ErrForbiddenAddress) {
+		t.Fatalf("err = %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestFetchBodyLimit(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Config{AllowAddr: allowLoopback, MaxBodyBytes: 1024})
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/huge"); !errors.Is(err, ErrNoPreview) {
+		t.Fatalf("err = %v, want ErrNoPreview", err)
+	}
+}
+
+func TestFetchRejectsNonHTML(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Config{AllowAddr: allowLoopback})
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/image"); !errors.Is(err, ErrNotHTML) {
+		t.Fatalf("err = %v, want ErrNotHTML", err)
+	}
+}
+
+func TestFetchRedirectLimit(t *testing.T) {
+	srv := newTestServer(t)
+	f := NewFetcher(Config{AllowAddr: allowLoopback, MaxRedirects: 2, Timeout: 2 * time.Second})
+
+	p, err := f.Fetch(context.Background(), srv.URL+"/redirect/2")
+	if err != nil {
+		t.Fatalf("2 redirects: %v", err)
+	}
+	if p.Title != "Статья про Go" {
+		t.Errorf("Title after redirects = %q", p.Title)
+	}
+
+	if _, err := f.Fetch(context.Background(), srv.URL+"/redirect/3"); err == nil || !strings.Contains(err.Error(), "redirects") {
+		t.Fatalf("3 redirects: err = %v, want redirect limit error", err)
+	}
+}
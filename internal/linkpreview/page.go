@@ -0,0 +1,95 @@
+package linkpreview
+
+import (
+	"io"
+	"messenger/internal/model"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// parsePage читает <head> и собирает превью: сначала из Open Graph, затем из <title> и
+// <meta name="description">. Ссылка на картинку разрешается относительно адреса страницы.
+func parsePage(r io.Reader, base *url.URL) *model.LinkPreview {
+	meta := make(map[string]string)
+	var title strings.Builder
+	inTitle := false
+
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			switch tok.Data {
+			case "body":
+				break loop
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for _, a := range tok.Attr {
+					switch a.Key {
+					case "property", "name":
+						key = strings.ToLower(a.Val)
+					case "content":
+						content = a.Val
+					}
+				}
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = strings.TrimSpace(content)
+				}
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "title" {
+				inTitle = false
+			} else if string(name) == "head" {
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		}
+	}
+
+	preview := &model.LinkPreview{
+		Title:       firstNonEmpty(meta["og:title"], meta["twitter:title"], title.String()),
+		Description: firstNonEmpty(meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    meta["og:site_name"],
+	}
+	preview.Title = truncate(strings.Join(strings.Fields(preview.Title), " "), maxTitleLength)
+	preview.Description = truncate(preview.Description, maxDescriptionLength)
+	preview.SiteName = truncate(preview.SiteName, maxTitleLength)
+	if image := firstNonEmpty(meta["og:image:secure_url"], meta["og:image"], meta["twitter:image"]); image != "" {
+		if u, err := base.Parse(image); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			preview.ImageURL = u.String()
+		}
+	}
+	return preview
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func truncate(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
@@ -0,0 +1,190 @@
+package markup
+
+import (
+	"messenger/internal/model"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Содержимое этих тегов выбрасывается вместе с ними
+var droppedTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "noscript": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+// У этих тегов не бывает закрывающего
+var voidTags = map[string]bool{
+	"area": true, "base": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// openTag — тег, который еще не закрыт. Тег <code> внутри <pre> лишь задает язык блока
+// и своей сущности не имеет (entity == nil). Отброшенные теги (dropped) тоже запоминаются,
+// чтобы их закрывающий тег не закрыл одноименный разрешенный снаружи.
+type openTag struct {
+	name    string // имя тега во входном HTML
+	clean   string // имя тега в очищенном исходнике
+	entity  *model.MessageEntity
+	start   int
+	dropped bool
+}
+
+// parseHTML принимает безопасное подмножество HTML: <b>, <strong>, <i>, <em>, <code>,
+// <pre> (язык — в <code class="language-x">), <a href>, <blockquote>, <tg-spoiler> и
+// <span class="spoiler">, <br>. Прочие теги отбрасываются, их текст сохраняется; у
+// разрешенных тегов остаются только нужные атрибуты. Исходник собирается заново, поэтому
+// в нем нет ничего, кроме разрешенной разметки.
+func parseHTML(source string) Result {
+	var b builder
+	var clean strings.Builder
+	var stack []openTag
+	skip := 0
+
+	closeTop := func() {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if top.dropped {
+			return
+		}
+		if top.entity != nil {
+			b.add(*top.entity, top.start)
+		}
+		clean.WriteString("</" + top.clean + ">")
+	}
+
+	z := html.NewTokenizer(strings.NewReader(source))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// Конец входа: незакрытые теги закрываются в конце текста
+			for len(stack) > 0 {
+				closeTop()
+			}
+			return b.result(clean.String())
+
+		case html.TextToken:
+			if skip > 0 {
+				continue
+			}
+			text := string(z.Text())
+			b.write(text)
+			clean.WriteString(html.EscapeString(text))
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if droppedTags[tok.Data] {
+				if tt == html.StartTagToken {
+					skip++
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			if tok.Data == "br" {
+				b.write("\n")
+				clean.WriteString("<br>")
+				continue
+			}
+			if tt == html.SelfClosingTagToken {
+				continue
+			}
+
+			// <pre><code class="language-go"> — язык блока кода
+			if tok.Data == "code" && len(stack) > 0 {
+				if top := &stack[len(stack)-1]; top.entity != nil && top.entity.Type == model.EntityPre && top.start == b.offset {
+					top.entity.Language = codeLanguage(tok)
+					tag := "code"
+					if top.entity.Language != "" {
+						tag = `code class="language-` + top.entity.Language + `"`
+					}
+					clean.WriteString("<" + tag + ">")
+					stack = append(stack, openTag{name: "code", clean: "code"})
+					continue
+				}
+			}
+
+			entity, cleanTag, ok := htmlEntity(tok)
+			if !ok {
+				if !voidTags[tok.Data] {
+					stack = append(stack, openTag{name: tok.Data, dropped: true})
+				}
+				continue
+			}
+			stack = append(stack, openTag{name: tok.Data, clean: cleanTag, entity: &entity, start: b.offset})
+			if entity.Type == model.EntityTextLink {
+				clean.WriteString(`<a href="` + html.EscapeString(entity.URL) + `">`)
+			} else {
+				clean.WriteString("<" + cleanTag + ">")
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if droppedTags[string(name)] {
+				if skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+			// Закрываем тег вместе со всеми вложенными, которые забыли закрыть
+			for k := len(stack) - 1; k >= 0; k-- {
+				if stack[k].name == string(name) {
+					for len(stack) > k {
+						closeTop()
+					}
+					break
+				}
+			}
+		}
+	}
+}
+
+// htmlEntity сопоставляет разрешенному тегу сущность и имя тега для очищенного исходника
+func htmlEntity(tok html.Token) (model.MessageEntity, string, bool) {
+	switch tok.Data {
+	case "b", "strong":
+		return model.MessageEntity{Type: model.EntityBold}, "b", true
+	case "i", "em":
+		return model.MessageEntity{Type: model.EntityItalic}, "i", true
+	case "code":
+		return model.MessageEntity{Type: model.EntityCode}, "code", true
+	case "pre":
+		return model.MessageEntity{Type: model.EntityPre}, "pre", true
+	case "blockquote":
+		return model.MessageEntity{Type: model.EntityBlockquote}, "blockquote", true
+	case "tg-spoiler":
+		return model.MessageEntity{Type: model.EntitySpoiler}, "tg-spoiler", true
+	case "span":
+		if attr(tok, "class") == "spoiler" {
+			return model.MessageEntity{Type: model.EntitySpoiler}, "tg-spoiler", true
+		}
+	case "a":
+		if link, ok := SafeURL(attr(tok, "href")); ok {
+			return model.MessageEntity{Type: model.EntityTextLink, URL: link}, "a", true
+		}
+	}
+	return model.MessageEntity{}, "", false
+}
+
+func codeLanguage(tok html.Token) string {
+	for _, class := range strings.Fields(attr(tok, "class")) {
+		if lang, ok := strings.CutPrefix(class, "language-"); ok {
+			return cleanLanguage(lang)
+		}
+	}
+	return ""
+}
+
+func attr(tok html.Token, name string) string {
+	for _, a := range tok.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package markup
+
+import (
+	"messenger/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		text     string
+		clean    string
+		entities []model.MessageEntity
+	}{
+		{"plain text", "привет", "привет", "привет", nil},
+		{"bold and strong", "<b>a</b> <strong>b</strong>", "a b", "<b>a</b> <b>b</b>", []model.MessageEntity{
+			entity(model.EntityBold, 0, 1),
+			entity(model.EntityBold, 2, 1),
+		}},
+		{"italic", "<em>курсив</em>", "курсив", "<i>курсив</i>", []model.MessageEntity{entity(model.EntityItalic, 0, 6)}},
+		{"spoilers", `<tg-spoiler>a</tg-spoiler><span class="spoiler">b</span>`, "ab", "<tg-spoiler>a</tg-spoiler><tg-spoiler>b</tg-spoiler>", []model.MessageEntity{
+			entity(model.EntitySpoiler, 0, 1),
+			entity(model.EntitySpoiler, 1, 1),
+		}},
+		{"pre with language", `<pre><code class="language-go">x := 1</code></pre>`, "x := 1", `<pre><code class="language-go">x := 1</code></pre>`, []model.MessageEntity{
+			{Type: model.EntityPre, Offset: 0, Length: 6, Language: "go"},
+		}},
+		{"link", `<a href="https://example.com" onclick="x()">сайт</a>`, "сайт", `<a href="https://example.com">сайт</a>`, []model.MessageEntity{
+			{Type: model.EntityTextLink, Offset: 0, Length: 4, URL: "https://example.com"},
+		}},
+		{"br", "a<br>b", "a\nb", "a<br>b", nil},
+		{"attributes dropped", `<b style="color:red" onmouseover="x()">a</b>`, "a", "<b>a</b>", []model.MessageEntity{entity(model.EntityBold, 0, 1)}},
+
+		// Запрещенное
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`, "x", "x", nil},
+		{"javascript href mixed case", `<a href="JaVaScRiPt:alert(1)">x</a>`, "x", "x", nil},
+		{"data href", `<a href="data:text/html,<script>">x</a>`, "x", "x", nil},
+		{"unknown tags keep text", "<u>a</u><div>b</div><span>c</span>", "abc", "abc", nil},
+		{"script content dropped", "a<script>alert('<b>x</b>')</script>b", "ab", "ab", nil},
+		{"style content dropped", "<style>b{}</style>текст", "текст", "текст", nil},
+		{"nested dropped tags", "<svg><script>x</script><title>t</title></svg>ok", "ok", "ok", nil},
+		{"text escaped", "&lt;script&gt;", "<script>", "&lt;script&gt;", nil},
+		{"image dropped", `<img src="x" onerror="alert(1)">a`, "a", "a", nil},
+
+		// Вложенность
+		{"unsupported span inside spoiler", `<span class="spoiler">a <span>b</span> c</span>`, "a b c", "<tg-spoiler>a b c</tg-spoiler>", []model.MessageEntity{
+			entity(model.EntitySpoiler, 0, 5),
+		}},
+		{"unsafe link inside link", `<a href="https://a.example">x <a href="javascript:y">y</a> z</a>`, "x y z", `<a href="https://a.example">x y z</a>`, []model.MessageEntity{
+			{Type: model.EntityTextLink, Offset: 0, Length: 5, URL: "https://a.example"},
+		}},
+		{"misnested closes inner", "<b>a<i>b</b>c</i>", "abc", "<b>a<i>b</i></b>c", []model.MessageEntity{
+			entity(model.EntityBold, 0, 2),
+			entity(model.EntityItalic, 1, 1),
+		}},
+		{"unclosed closed at end", "<b>a<i>b", "ab", "<b>a<i>b</i></b>", []model.MessageEntity{
+			entity(model.EntityBold, 0, 2),
+			entity(model.EntityItalic, 1, 1),
+		}},
+		{"stray end tag", "a</b>b", "ab", "ab", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.source, model.FormatHTML)
+			if got.Text != tt.text {
+				t.Errorf("text = %q, want %q", got.Text, tt.text)
+			}
+			if got.Source != tt.clean {
+				t.Errorf("source = %q, want %q", got.Source, tt.clean)
+			}
+			if !reflect.DeepEqual(got.Entities, tt.entities) {
+				t.Errorf("entities = %+v, want %+v", got.Entities, tt.entities)
+			}
+		})
+	}
+}
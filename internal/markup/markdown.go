@@ -0,0 +1,246 @@
+package markup
+
+import (
+	"messenger/internal/model"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parseMarkdown понимает подмножество Markdown:
+//
+//	**жирный** или __жирный__, *курсив* или _курсив_, `код`, ```язык
+//	блок кода
+//	```, [текст](https://ссылка), ||спойлер||, строки цитаты с "> ".
+//
+// Разметка, у которой нет пары, остается текстом; \ экранирует служебный символ. Внутри слова
+// _ и * разметкой не бывают, поэтому \ перед ними там остается текстом: C:\Users\_temp не меняется.
+// HTML в Markdown не разбирается и попадает в текст как есть.
+func parseMarkdown(source string) Result {
+	var b builder
+	lines := strings.Split(source, "\n")
+	for i := 0; i < len(lines); {
+		if i > 0 {
+			b.write("\n")
+		}
+		line := lines[i]
+
+		if lang, ok := fenceOpen(line); ok {
+			if end := fenceClose(lines, i+1); end > 0 {
+				start := b.offset
+				b.write(strings.Join(lines[i+1:end], "\n"))
+				b.add(model.MessageEntity{Type: model.EntityPre, Language: lang}, start)
+				i = end + 1
+				continue
+			}
+		}
+
+		if isQuote(line) {
+			start := b.offset
+			j := i
+			for ; j < len(lines) && isQuote(lines[j]); j++ {
+				if j > i {
+					b.write("\n")
+				}
+				b.inline(strings.TrimPrefix(strings.TrimPrefix(lines[j], ">"), " "))
+			}
+			b.add(model.MessageEntity{Type: model.EntityBlockquote}, start)
+			i = j
+			continue
+		}
+
+		b.inline(line)
+		i++
+	}
+	return b.result(source)
+}
+
+func (b *builder) inline(s string) {
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && isEscapable(s[i+1]) && !intraword(s, i):
+			b.write(s[i+1 : i+2])
+			i += 2
+			continue
+
+		case strings.HasPrefix(s[i:], "```"):
+			if end := strings.Index(s[i+3:], "```"); end > 0 {
+				b.literal(model.EntityPre, s[i+3:i+3+end])
+				i += end + 6
+				continue
+			}
+
+		case s[i] == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end > 0 {
+				b.literal(model.EntityCode, s[i+1:i+1+end])
+				i += end + 2
+				continue
+			}
+
+		case strings.HasPrefix(s[i:], "**"), strings.HasPrefix(s[i:], "__"), strings.HasPrefix(s[i:], "||"):
+			delim := s[i : i+2]
+			end := -1
+			if delim != "__" {
+				end = closingDelim(s, i+2, delim)
+			} else if !wordBefore(s, i) {
+				end = closingUnderscores(s, i+2)
+			}
+			if end > 0 {
+				t := model.EntityBold
+				if delim == "||" {
+					t = model.EntitySpoiler
+				}
+				b.nested(model.MessageEntity{Type: t}, s[i+2:end])
+				i = end + 2
+				continue
+			}
+			// Разделитель без пары — текст целиком, одиночный символ из него не открывает курсив
+			b.write(delim)
+			i += 2
+			continue
+
+		case (s[i] == '*' || s[i] == '_') && !wordBefore(s, i) && i+1 < len(s) && s[i+1] != ' ':
+			if end := closingEmphasis(s, i+1, s[i]); end > 0 {
+				b.nested(model.MessageEntity{Type: model.EntityItalic}, s[i+1:end])
+				i = end + 1
+				continue
+			}
+
+		case s[i] == '[':
+			if text, link, n, ok := parseLink(s[i:]); ok {
+				b.nested(model.MessageEntity{Type: model.EntityTextLink, URL: link}, text)
+				i += n
+				continue
+			}
+		}
+
+		_, size := utf8.DecodeRuneInString(s[i:])
+		b.write(s[i : i+size])
+		i += size
+	}
+}
+
+// literal выводит текст без разбора разметки внутри — для кода
+func (b *builder) literal(t model.EntityType, content string) {
+	start := b.offset
+	b.write(content)
+	b.add(model.MessageEntity{Type: t}, start)
+}
+
+func (b *builder) nested(e model.MessageEntity, content string) {
+	start := b.offset
+	b.inline(content)
+	b.add(e, start)
+}
+
+// closingDelim ищет закрывающий разделитель, пропуская экранированные символы
+func closingDelim(s string, from int, delim string) int {
+	for j := from; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if j > from && strings.HasPrefix(s[j:], delim) {
+			return j
+		}
+	}
+	return -1
+}
+
+// closingUnderscores ищет закрывающее __ не внутри слова, чтобы snake__case оставался текстом
+func closingUnderscores(s string, from int) int {
+	for j := from; j < len(s); j++ {
+		if s[j] == '\\' {
+			j++
+			continue
+		}
+		if j > from && strings.HasPrefix(s[j:], "__") && s[j-1] != ' ' && !wordAfter(s, j+2) {
+			return j
+		}
+	}
+	return -1
+}
+
+// closingEmphasis ищет конец курсива: символ ch не после пробела и не внутри слова,
+// чтобы snake_case и 2*3*4 оставались текстом. Удвоенный символ — отдельный разделитель.
+func closingEmphasis(s string, from int, ch byte) int {
+	for j := from; j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case s[j] == ch && j+1 < len(s) && s[j+1] == ch:
+			j++
+		case s[j] == ch && j > from && s[j-1] != ' ' && !wordAfter(s, j+1):
+			return j
+		}
+	}
+	return -1
+}
+
+// parseLink разбирает [текст](ссылка) в начале s; n — длина разобранной части
+func parseLink(s string) (text, link string, n int, ok bool) {
+	closeText := closingDelim(s, 1, "]")
+	if closeText < 0 || !strings.HasPrefix(s[closeText:], "](") {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(s[closeText+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	link, ok = SafeURL(s[closeText+2 : closeText+2+end])
+	if !ok {
+		return "", "", 0, false
+	}
+	return s[1:closeText], link, closeText + 3 + end, true
+}
+
+func fenceOpen(line string) (string, bool) {
+	if !strings.HasPrefix(line, "```") {
+		return "", false
+	}
+	lang := strings.TrimSpace(line[3:])
+	if strings.ContainsAny(lang, "` \t") {
+		return "", false
+	}
+	return cleanLanguage(lang), true
+}
+
+func fenceClose(lines []string, from int) int {
+	for j := from; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "```" {
+			return j
+		}
+	}
+	return -1
+}
+
+func isQuote(line string) bool {
+	return line == ">" || strings.HasPrefix(line, "> ")
+}
+
+func isEscapable(c byte) bool {
+	return strings.IndexByte("\\`*_[]()|>~#+-=.!{}", c) >= 0
+}
+
+// intraword сообщает, что \ в позиции i стоит перед одиночным _ или * посреди слова,
+// где этот символ и без экранирования остался бы текстом
+func intraword(s string, i int) bool {
+	c := s[i+1]
+	if c != '_' && c != '*' {
+		return false
+	}
+	if i+2 < len(s) && s[i+2] == c {
+		return false
+	}
+	return wordBefore(s, i) && wordAfter(s, i+2)
+}
+
+func wordBefore(s string, i int) bool {
+	r, _ := utf8.DecodeLastRuneInString(s[:i])
+	return i > 0 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func wordAfter(s string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(s[i:])
+	return i < len(s) && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
@@ -0,0 +1,107 @@
+package markup
+
+import (
+	"messenger/internal/model"
+	"reflect"
+	"testing"
+)
+
+func entity(t model.EntityType, offset, length int) model.MessageEntity {
+	return model.MessageEntity{Type: t, Offset: offset, Length: length}
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		text     string
+		entities []model.MessageEntity
+	}{
+		{"plain text", "привет, мир", "привет, мир", nil},
+		{"bold", "**жирный** текст", "жирный текст", []model.MessageEntity{entity(model.EntityBold, 0, 6)}},
+		{"bold underscores", "a __b__ c", "a b c", []model.MessageEntity{entity(model.EntityBold, 2, 1)}},
+		{"italic star", "*курсив*", "курсив", []model.MessageEntity{entity(model.EntityItalic, 0, 6)}},
+		{"italic underscore", "_курсив_", "курсив", []model.MessageEntity{entity(model.EntityItalic, 0, 6)}},
+		{"code keeps markup", "`**x**`", "**x**", []model.MessageEntity{entity(model.EntityCode, 0, 5)}},
+		{"spoiler", "||тайна||", "тайна", []model.MessageEntity{entity(model.EntitySpoiler, 0, 5)}},
+		{"nested", "**a _b_**", "a b", []model.MessageEntity{
+			entity(model.EntityBold, 0, 3),
+			entity(model.EntityItalic, 2, 1),
+		}},
+		{"link", "[сайт](https://example.com)", "сайт", []model.MessageEntity{
+			{Type: model.EntityTextLink, Offset: 0, Length: 4, URL: "https://example.com"},
+		}},
+		{"unsafe link stays text", "[x](javascript:alert(1))", "[x](javascript:alert(1))", nil},
+		{"fenced block", "```go\nfmt.Println()\n```", "fmt.Println()", []model.MessageEntity{
+			{Type: model.EntityPre, Offset: 0, Length: 13, Language: "go"},
+		}},
+		{"quote", "> один\n> два\nтри", "один\nдва\nтри", []model.MessageEntity{entity(model.EntityBlockquote, 0, 8)}},
+
+		// Обычный текст, похожий на разметку, не должен портиться
+		{"snake_case", "snake_case_name", "snake_case_name", nil},
+		{"snake__case", "snake__case__name", "snake__case__name", nil},
+		{"arithmetic", "2*3*4", "2*3*4", nil},
+		{"dunder file", "__init__.py", "init.py", []model.MessageEntity{entity(model.EntityBold, 0, 4)}},
+		{"unpaired double underscore", "__init и _x_", "__init и x", []model.MessageEntity{entity(model.EntityItalic, 9, 1)}},
+		{"windows path", `C:\Users\_temp`, `C:\Users\_temp`, nil},
+		{"escaped star", `\*не курсив\*`, "*не курсив*", nil},
+		{"escaped underscore at word start", `\_x_`, "_x_", nil},
+		{"unpaired", "**не закрыт", "**не закрыт", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.source, model.FormatMarkdown)
+			if got.Text != tt.text {
+				t.Errorf("text = %q, want %q", got.Text, tt.text)
+			}
+			if !reflect.DeepEqual(got.Entities, tt.entities) {
+				t.Errorf("entities = %+v, want %+v", got.Entities, tt.entities)
+			}
+		})
+	}
+}
+
+// Смещения считаются в кодовых единицах UTF-16: эмодзи вне BMP занимает две
+func TestParseMarkdownUTF16Offsets(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		text     string
+		entities []model.MessageEntity
+	}{
+		{"ascii", "ab **cd**", "ab cd", []model.MessageEntity{entity(model.EntityBold, 3, 2)}},
+		{"cyrillic is one unit", "мир **да**", "мир да", []model.MessageEntity{entity(model.EntityBold, 4, 2)}},
+		{"emoji before", "😀 **x**", "😀 x", []model.MessageEntity{entity(model.EntityBold, 3, 1)}},
+		{"emoji inside", "**😀😀**", "😀😀", []model.MessageEntity{entity(model.EntityBold, 0, 4)}},
+		{"flag and code", "🇷🇺 `go`", "🇷🇺 go", []model.MessageEntity{entity(model.EntityCode, 5, 2)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.source, model.FormatMarkdown)
+			if got.Text != tt.text {
+				t.Errorf("text = %q, want %q", got.Text, tt.text)
+			}
+			if !reflect.DeepEqual(got.Entities, tt.entities) {
+				t.Errorf("entities = %+v, want %+v", got.Entities, tt.entities)
+			}
+		})
+	}
+}
+
+func TestParsePlainKeepsText(t *testing.T) {
+	for _, source := range []string{"__init__.py", `C:\Users\_temp`, "**x**"} {
+		got := Parse(source, model.FormatPlain)
+		if got.Text != source || got.Entities != nil {
+			t.Errorf("Parse(%q, plain) = %q %+v", source, got.Text, got.Entities)
+		}
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	tests := map[string]int{"": 0, "abc": 3, "мир": 3, "😀": 2, "a😀b": 4}
+	for s, want := range tests {
+		if got := UTF16Len(s); got != want {
+			t.Errorf("UTF16Len(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package markup разбирает форматированный текст сообщений на текст без разметки и
+// сущности (model.MessageEntity) со смещениями в UTF-16.
+package markup
+
+import (
+	"messenger/internal/model"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Больше сущностей в одном сообщении не сохраняется, остальная разметка становится текстом
+const maxEntities = 100
+
+// Result — результат разбора. Source — исходник для хранения и редактирования:
+// для HTML это уже очищенная разметка.
+type Result struct {
+	Text     string
+	Entities []model.MessageEntity
+	Source   string
+}
+
+// Parse разбирает source в формате format. Текст без формата возвращается как есть.
+func Parse(source string, format model.TextFormat) Result {
+	switch format {
+	case model.FormatMarkdown:
+		return parseMarkdown(source)
+	case model.FormatHTML:
+		return parseHTML(source)
+	default:
+		return Result{Text: source}
+	}
+}
+
+// UTF16Len возвращает длину строки в кодовых единицах UTF-16
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// SortEntities упорядочивает сущности по началу, внешние — раньше вложенных
+func SortEntities(entities []model.MessageEntity) {
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].Offset != entities[j].Offset {
+			return entities[i].Offset < entities[j].Offset
+		}
+		return entities[i].Length > entities[j].Length
+	})
+}
+
+// SafeURL пропускает только абсолютные ссылки с безопасной схемой
+func SafeURL(raw string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto", "tg":
+	default:
+		return "", false
+	}
+	return u.String(), true
+}
+
+// cleanLanguage оставляет от названия языка блока кода только безопасные символы
+func cleanLanguage(lang string) string {
+	if len(lang) > 32 {
+		return ""
+	}
+	for _, r := range lang {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("+#._-", r)) {
+			return ""
+		}
+	}
+	return lang
+}
+
+// builder накапливает текст без разметки и считает смещения в UTF-16
+type builder struct {
+	text     strings.Builder
+	offset   int
+	entities []model.MessageEntity
+}
+
+func (b *builder) write(s string) {
+	b.text.WriteString(s)
+	b.offset += UTF16Len(s)
+}
+
+// add добавляет сущность, закончившуюся на текущей позиции
+func (b *builder) add(e model.MessageEntity, start int) {
+	e.Offset, e.Length = start, b.offset-start
+	if e.Length > 0 && len(b.entities) < maxEntities {
+		b.entities = append(b.entities, e)
+	}
+}
+
+func (b *builder) result(source string) Result {
+	SortEntities(b.entities)
+	return Result{Text: b.text.String(), Entities: b.entities, Source: source}
+}
@@ -0,0 +1,102 @@
+package markup
+
+import (
+	"html"
+	"messenger/internal/model"
+	"strings"
+	"unicode/utf16"
+)
+
+// RenderHTML собирает из текста и сущностей безопасный HTML. Текст экранируется, ссылки
+// проходят SafeURL; сущности, пересекающие границы друг друга, обрезаются по внешней.
+func RenderHTML(text string, entities []model.MessageEntity) string {
+	sorted := append([]model.MessageEntity(nil), entities...)
+	SortEntities(sorted)
+
+	var b strings.Builder
+	var open []model.MessageEntity
+	next, offset := 0, 0
+	for _, r := range text {
+		closeEntities(&b, &open, offset)
+		for next < len(sorted) && sorted[next].Offset <= offset {
+			e := sorted[next]
+			next++
+			if e.Offset < offset || e.Length <= 0 {
+				continue
+			}
+			if len(open) > 0 {
+				// Вложенная сущность не выходит за пределы внешней
+				if outer := open[len(open)-1]; e.Offset+e.Length > outer.Offset+outer.Length {
+					e.Length = outer.Offset + outer.Length - e.Offset
+				}
+			}
+			b.WriteString(entityOpenTag(e))
+			open = append(open, e)
+		}
+		b.WriteString(html.EscapeString(string(r)))
+		offset += utf16.RuneLen(r)
+	}
+	closeEntities(&b, &open, -1)
+	return b.String()
+}
+
+// closeEntities закрывает сущности, закончившиеся к offset; offset < 0 закрывает все
+func closeEntities(b *strings.Builder, open *[]model.MessageEntity, offset int) {
+	for len(*open) > 0 {
+		e := (*open)[len(*open)-1]
+		if offset >= 0 && e.Offset+e.Length > offset {
+			return
+		}
+		b.WriteString(entityCloseTag(e))
+		*open = (*open)[:len(*open)-1]
+	}
+}
+
+func entityOpenTag(e model.MessageEntity) string {
+	switch e.Type {
+	case model.EntityBold:
+		return "<b>"
+	case model.EntityItalic:
+		return "<i>"
+	case model.EntityCode:
+		return "<code>"
+	case model.EntityPre:
+		if e.Language != "" {
+			return `<pre><code class="language-` + html.EscapeString(e.Language) + `">`
+		}
+		return "<pre><code>"
+	case model.EntityTextLink:
+		if link, ok := SafeURL(e.URL); ok {
+			return `<a href="` + html.EscapeString(link) + `" rel="noopener noreferrer">`
+		}
+		return "<span>"
+	case model.EntitySpoiler:
+		return `<span class="spoiler">`
+	case model.EntityBlockquote:
+		return "<blockquote>"
+	default:
+		return `<span class="` + html.EscapeString(string(e.Type)) + `">`
+	}
+}
+
+func entityCloseTag(e model.MessageEntity) string {
+	switch e.Type {
+	case model.EntityBold:
+		return "</b>"
+	case model.EntityItalic:
+		return "</i>"
+	case model.EntityCode:
+		return "</code>"
+	case model.EntityPre:
+		return "</code></pre>"
+	case model.EntityTextLink:
+		if _, ok := SafeURL(e.URL); ok {
+			return "</a>"
+		}
+		return "</span>"
+	case model.EntityBlockquote:
+		return "</blockquote>"
+	default:
+		return "</span>"
+	}
+}
@@ -1,14 +1,19 @@
 package middleware
 
 import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/service"
 	"messenger/internal/utils"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func AuthMiddleware(secret string) gin.HandlerFunc {
+func AuthMiddleware(keys *utils.KeySet, apiTokens *service.APITokenService, users *service.UserService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := ""
 		authHeader := c.GetHeader("Authorization")
@@ -25,14 +30,93 @@ func AuthMiddleware(secret string) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.VerifyJWT(tokenString, secret)
+		// Персональный токен интеграции: доступ ограничен его областями
+		if strings.HasPrefix(tokenString, service.APITokenPrefix) {
+			token, err := apiTokens.Authenticate(tokenString)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+				return
+			}
+			if !authorize(c, users, token.UserID, nil) {
+				return
+			}
+			c.Set("userID", token.UserID)
+			c.Set("apiToken", token)
+			c.Next()
+			return
+		}
+
+		claims, err := utils.VerifyJWT(tokenString, keys)
 		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			return
 		}
 
+		var issuedAt *time.Time
+		if claims.IssuedAt != nil {
+			issuedAt = &claims.IssuedAt.Time
+		}
+		if !authorize(c, users, claims.UserID, issuedAt) {
+			return
+		}
+
 		// Сохраняем userID в контекст, чтобы хендлеры могли его достать
 		c.Set("userID", claims.UserID)
 		c.Next()
 	}
 }
+
+// authorize проверяет состояние аккаунта и кладет его глобальную роль в контекст
+func authorize(c *gin.Context, users *service.UserService, userID uuid.UUID, issuedAt *time.Time) bool {
+	role, err := users.Authorize(userID, issuedAt)
+	switch {
+	case errors.Is(err, service.ErrAccountSuspended):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account suspended"})
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrSessionRevoked):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.Set("userRole", role)
+		return true
+	}
+	return false
+}
+
+// RequireScope пропускает запросы по персональному токену, только если у него есть нужная область.
+// Запросы по JWT сессии пользователя не ограничиваются.
+func RequireScope(scope model.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if val, ok := c.Get("apiToken"); ok {
+			if token := val.(*model.APIToken); !token.HasScope(scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token scope " + string(scope) + " required"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RequireSession пропускает только запросы с JWT сессии пользователя, но не персональные токены
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiToken"); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this endpoint requires a user session"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole пропускает только пользователей с глобальной ролью не ниже role.
+// Ставится после RequireSession: персональные токены административный API не открывают.
+func RequireRole(role model.UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		val, _ := c.Get("userRole")
+		if current, _ := val.(model.UserRole); !current.AtLeast(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": string(role) + " role required"})
+			return
+		}
+		c.Next()
+	}
+}
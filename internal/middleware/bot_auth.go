@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"messenger/internal/service"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BotAuthMiddleware аутентифицирует запросы Bot API по токену бота
+func BotAuthMiddleware(bots *service.BotService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		tokenString := strings.TrimPrefix(strings.TrimPrefix(authHeader, "Bot "), "Bearer ")
+
+		if !strings.HasPrefix(tokenString, service.BotTokenPrefix) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		bot, err := bots.Authenticate(tokenString)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+
+		c.Set("userID", bot.ID)
+		c.Set("bot", bot)
+		c.Next()
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AccountExport — задача выгрузки всех данных пользователя в ZIP-архив.
+// Статусы те же, что у выгрузки чата.
+type AccountExport struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	Status      string     `json:"status"`
+	Exported    int        `json:"exported"` // сколько сообщений пользователя уже записано
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"` // появляется, когда архив готов
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	BlobKey  *string `json:"-"`
+	Attempts int     `json:"-"`
+}
+
+// Membership — чат, в котором состоит пользователь, для выгрузки его данных
+type Membership struct {
+	ChatID   uuid.UUID `json:"chat_id"`
+	Type     TypeChat  `json:"type"`
+	Name     string    `json:"name"` // у личного чата — имя собеседника
+	Role     ChatRole  `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// Что происходит с сообщениями удаленного аккаунта
+const (
+	DeletedMessagesAnonymize = "anonymize" // остаются в чатах от имени удаленного аккаунта
+	DeletedMessagesDelete    = "delete"
+)
+
+// AccountDeletion — запланированное удаление аккаунта
+type AccountDeletion struct {
+	ScheduledAt time.Time `json:"scheduled_at"`
+	Messages    string    `json:"messages"` // DeletedMessagesAnonymize или DeletedMessagesDelete
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Состояния аккаунта для фильтра списка пользователей
+const (
+	AccountActive    = "active"
+	AccountSuspended = "suspended"
+	AccountDeleted   = "deleted"
+)
+
+// UserListFilter выбирает пользователей в административном списке
+type UserListFilter struct {
+	Query  string   // подстрока логина, отображаемого имени или почты
+	Role   UserRole // пусто — любая роль
+	State  string   // AccountActive, AccountSuspended, AccountDeleted; пусто — все
+	Bots   *bool    // nil — и люди, и боты
+	Limit  int
+	Offset int
+}
+
+// UserList — страница административного списка пользователей
+type UserList struct {
+	Users []User `json:"users"`
+	Total int    `json:"total"`
+}
+
+// ChatOverview — сведения о чате для администрации. Текст сообщений сюда не входит.
+type ChatOverview struct {
+	Chat          Chat            `json:"chat"`
+	Members       []MemberProfile `json:"members"`
+	Messages      int             `json:"messages"`
+	LastMessageAt *time.Time      `json:"last_message_at,omitempty"`
+}
+
+// ServerStats — состояние сервера для администрации
+type ServerStats struct {
+	Users           int       `json:"users"` // люди с действующими аккаунтами
+	Bots            int       `json:"bots"`
+	Suspended       int       `json:"suspended"`
+	Deleted         int       `json:"deleted"`
+	PendingDeletion int       `json:"pending_deletion"`
+	NewUsers24h     int       `json:"new_users_24h"`
+	PrivateChats    int       `json:"private_chats"`
+	GroupChats      int       `json:"group_chats"`
+	Messages        int64     `json:"messages"`
+	Online          int       `json:"online"` // подключены по WebSocket к этому экземпляру сервера
+	Away            int       `json:"away"`   // из них отошли
+	StartedAt       time.Time `json:"started_at"`
+	GeneratedAt     time.Time `json:"generated_at"`
+}
+
+// RoleChange — запрос на смену глобальной роли
+type RoleChange struct {
+	UserID uuid.UUID `json:"user_id"`
+	Role   UserRole  `json:"role"`
+}
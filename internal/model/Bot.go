@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Bot struct {
+	ID         uuid.UUID `json:"id"` // совпадает с ID пользователя бота
+	OwnerID    uuid.UUID `json:"owner_id"`
+	Username   string    `json:"username"`
+	WebhookURL *string   `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type BotCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+type BotUpdateType string
+
+const (
+	BotUpdateCommand BotUpdateType = "command"
+	BotUpdateMessage BotUpdateType = "message"
+)
+
+// BotUpdate — событие, доставляемое боту через getUpdates или вебхук
+type BotUpdate struct {
+	ID      int64         `json:"update_id"`
+	Type    BotUpdateType `json:"type"`
+	Message *Message      `json:"message"`
+	Command string        `json:"command,omitempty"`
+	Args    string        `json:"args,omitempty"`
+}
@@ -7,10 +7,12 @@ import (
 )
 
 type Chat struct {
-	ID        uuid.UUID `json:"id"`
-	Type      TypeChat  `json:"type"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         uuid.UUID `json:"id"`
+	Type       TypeChat  `json:"type"`
+	Name       string    `json:"name"`
+	NoForwards bool      `json:"no_forwards"`           // сообщения из чата нельзя пересылать
+	MessageTTL *int      `json:"message_ttl,omitempty"` // таймер автоудаления в секундах
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type ChatListItem struct {
@@ -20,5 +22,17 @@ type ChatListItem struct {
 	LastMessage     string     `json:"last_message"`
 	LastMessageTime time.Time  `json:"last_message_time"`
 	IsOnline        bool       `json:"is_online"`
-	InterlocutorID  *uuid.UUID `json:"interlocutor_id"` // ID собеседника для проверки онлайна
+	Status          string     `json:"status,omitempty"`       // online, away или offline — для личных чатов
+	LastSeenAt      *time.Time `json:"last_seen_at,omitempty"` // последний визит собеседника, если он его не скрыл
+	InterlocutorID  *uuid.UUID `json:"interlocutor_id"`        // ID собеседника для проверки онлайна
+	UnreadCount     int        `json:"unread_count"`
+	UnreadMentions  int        `json:"unread_mentions"`
+	MessageTTL      *int       `json:"message_ttl,omitempty"`
+	MutedUntil      *time.Time `json:"muted_until,omitempty"`
+	IsPinned        bool       `json:"is_pinned"`
+	IsArchived      bool       `json:"is_archived"`
+	MarkedUnread    bool       `json:"marked_unread"`
+	Draft           *Draft     `json:"draft,omitempty"` // черновик текущего пользователя
+
+	InterlocutorPrivacy *Visibility `json:"-"`
 }
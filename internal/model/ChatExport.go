@@ -0,0 +1,32 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ChatExport — задача выгрузки истории чата в ZIP-архив
+type ChatExport struct {
+	ID          uuid.UUID  `json:"id"`
+	ChatID      uuid.UUID  `json:"chat_id"`
+	RequestedBy uuid.UUID  `json:"requested_by"`
+	Status      string     `json:"status"`
+	Exported    int        `json:"exported"` // сколько сообщений уже записано
+	Total       int        `json:"total"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"` // появляется, когда архив готов
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+
+	BlobKey  *string `json:"-"`
+	Attempts int     `json:"-"`
+}
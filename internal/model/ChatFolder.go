@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ChatFolder — пользовательская папка чатов. Чат попадает в папку, если он указан в
+// IncludeChatIDs или его тип есть в IncludeTypes, не указан в ExcludeChatIDs и
+// проходит флаги-фильтры.
+type ChatFolder struct {
+	ID              uuid.UUID   `json:"id"`
+	Name            string      `json:"name"`
+	Position        int         `json:"position"`
+	IncludeChatIDs  []uuid.UUID `json:"include_chat_ids"`
+	ExcludeChatIDs  []uuid.UUID `json:"exclude_chat_ids"`
+	IncludeTypes    []TypeChat  `json:"include_types"`
+	UnreadOnly      bool        `json:"unread_only"`
+	ExcludeMuted    bool        `json:"exclude_muted"`
+	ExcludeArchived *bool       `json:"exclude_archived"` // по умолчанию архивные чаты в папку не попадают
+	CreatedAt       time.Time   `json:"created_at"`
+}
+
+// ChatSettingsUpdate — частичное изменение личных настроек чата; nil оставляет настройку как есть
+type ChatSettingsUpdate struct {
+	MutedUntil   *time.Time `json:"muted_until"` // время в прошлом снимает заглушение
+	Pinned       *bool      `json:"pinned"`
+	Archived     *bool      `json:"archived"`
+	MarkedUnread *bool      `json:"marked_unread"`
+}
+
+// ChatListFilter выбирает, какие чаты вернуть в списке
+type ChatListFilter struct {
+	FolderID *uuid.UUID
+	Archived bool // без папки: только архив или только неархивные чаты
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ImportPending = "pending"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// ChatImport — задача переноса истории из другого мессенджера в новый групповой чат
+type ChatImport struct {
+	ID          uuid.UUID          `json:"id"`
+	RequestedBy uuid.UUID          `json:"requested_by"`
+	Source      string             `json:"source"` // telegram или slack
+	Name        string             `json:"name,omitempty"`
+	Status      string             `json:"status"`
+	ChatID      *uuid.UUID         `json:"chat_id,omitempty"` // появляется, когда чат создан
+	Processed   int                `json:"processed"`
+	Total       int                `json:"total"`
+	Imported    int                `json:"imported"`
+	Skipped     int                `json:"skipped"`
+	Report      []ImportReportItem `json:"report"`
+	Error       string             `json:"error,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	FinishedAt  *time.Time         `json:"finished_at,omitempty"`
+
+	Channel  string            `json:"-"`
+	UserMap  map[string]string `json:"-"` // ID автора в выгрузке -> логин или email того, кому предложить авторство
+	BlobKey  string            `json:"-"`
+	Attempts int               `json:"-"`
+}
+
+// ImportReportItem — что не удалось перенести. Item — ID сообщения в выгрузке.
+type ImportReportItem struct {
+	Item   string `json:"item"`
+	Reason string `json:"reason"`
+}
+
+// ImportedMessage — сообщение, подготовленное к записи в чат
+type ImportedMessage struct {
+	ID        uuid.UUID
+	ForeignID string
+	SenderID  uuid.UUID
+	Content   string
+	Entities  []MessageEntity
+	ReplyTo   *uuid.UUID
+	CreatedAt time.Time
+}
+
+// ImportClaim — предложение пользователю подтвердить, что автор выгрузки — это он. Пока
+// пользователь не согласился, сообщения автора принадлежат заглушке.
+type ImportClaim struct {
+	ID            uuid.UUID `json:"id"`
+	ImportID      uuid.UUID `json:"import_id"`
+	ChatID        uuid.UUID `json:"chat_id"`
+	ChatName      string    `json:"chat_name"`
+	PlaceholderID uuid.UUID `json:"placeholder_id"`
+	AuthorName    string    `json:"author_name"`
+	RequestedBy   uuid.UUID `json:"requested_by"` // кто импортировал историю
+	Messages      int       `json:"messages"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -1,8 +1,30 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChatRole string
+
+const (
+	RoleMember ChatRole = "member"
+	RoleAdmin  ChatRole = "admin"
+)
 
 type ChatMember struct {
 	ChatID uuid.UUID
 	UserID uuid.UUID
+	Role   ChatRole
+}
+
+// MemberProfile — участник чата с данными профиля
+type MemberProfile struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName *string   `json:"display_name"`
+	IsBot       bool      `json:"is_bot"`
+	Role        ChatRole  `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
 }
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Draft — неотправленное сообщение пользователя в чате, синхронизируется между устройствами
+type Draft struct {
+	ChatID           uuid.UUID  `json:"chat_id"`
+	Text             string     `json:"text"`
+	ReplyToMessageID *uuid.UUID `json:"reply_to_message_id,omitempty"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type IncomingWebhook struct {
+	ID        uuid.UUID  `json:"id"`
+	ChatID    uuid.UUID  `json:"chat_id"`
+	SenderID  uuid.UUID  `json:"sender_id"`
+	Name      string     `json:"name"`
+	CreatedBy *uuid.UUID `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+// IncomingWebhookPayload — тело запроса на адрес входящего вебхука
+type IncomingWebhookPayload struct {
+	Text        string              `json:"text"`
+	Username    string              `json:"username"`
+	IconURL     string              `json:"icon_url"`
+	Attachments []MessageAttachment `json:"attachments"`
+}
@@ -0,0 +1,10 @@
+package model
+
+// LinkPreview — превью первой ссылки сообщения по данным Open Graph
+type LinkPreview struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
@@ -7,10 +7,69 @@ import (
 )
 
 type Message struct {
-	ID         uuid.UUID `json:"id"`
+	ID          uuid.UUID           `json:"id"`
+	ChatID      uuid.UUID           `json:"chat_id"`
+	SenderID    uuid.UUID           `json:"sender_id"`
+	SenderName  string              `json:"sender_name"`
+	IconURL     *string             `json:"icon_url,omitempty"`
+	Content     string              `json:"content"` // текст без разметки
+	Entities    []MessageEntity     `json:"entities,omitempty"`
+	Format      TextFormat          `json:"format"`
+	Source      string              `json:"source,omitempty"` // исходник в формате Format; для plain совпадает с Content и не хранится
+	Attachments []MessageAttachment `json:"attachments,omitempty"`
+	LinkPreview *LinkPreview        `json:"link_preview,omitempty"`
+	Type        MessageType         `json:"type"`
+	Action      *SystemAction       `json:"action,omitempty"` // только у системных сообщений
+	Forwarded   *ForwardedFrom      `json:"forwarded_from,omitempty"`
+	ReplyTo     *uuid.UUID          `json:"reply_to_message_id,omitempty"`
+	Poll        *Poll               `json:"poll,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	ExpiresAt   *time.Time          `json:"expires_at,omitempty"` // когда сообщение будет удалено таймером чата
+	ImportID    *uuid.UUID          `json:"import_id,omitempty"`  // задача импорта, если сообщение перенесено из другого мессенджера
+
+	// AttachmentsFrom — сообщение, в котором хранятся вложения: само сообщение или оригинал пересланного
+	AttachmentsFrom *uuid.UUID `json:"-"`
+	// Scheduled — отложенное сообщение, которое сейчас отправляется
+	Scheduled *ScheduledSend `json:"-"`
+}
+
+type MessageType string
+
+const (
+	MessageText   MessageType = "text"
+	MessageSystem MessageType = "system" // служебная запись о событии в чате, SenderID — его автор
+	MessagePoll   MessageType = "poll"   // опрос, вопрос дублируется в Content
+)
+
+// Типы событий в системных сообщениях
+const (
+	ActionMessagePinned     = "message_pinned"
+	ActionMessageTTLChanged = "message_ttl_changed"
+)
+
+// SystemAction описывает событие, которое записано системным сообщением
+type SystemAction struct {
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	MessageID *uuid.UUID `json:"message_id,omitempty"`
+	TTL       *int       `json:"ttl_seconds,omitempty"` // новый таймер автоудаления, 0 — выключен
+}
+
+// ForwardedFrom — сведения об оригинале пересланного сообщения. При пересылке пересланного
+// сохраняется самый первый оригинал.
+type ForwardedFrom struct {
+	MessageID  uuid.UUID `json:"message_id"`
 	ChatID     uuid.UUID `json:"chat_id"`
 	SenderID   uuid.UUID `json:"sender_id"`
 	SenderName string    `json:"sender_name"`
-	Content    string    `json:"content"`
 	CreatedAt  time.Time `json:"created_at"`
 }
+
+// MessageAttachment — карточка со ссылкой, которую присылают интеграции
+type MessageAttachment struct {
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text,omitempty"`
+	URL      string `json:"url,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	Color    string `json:"color,omitempty"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import "github.com/google/uuid"
+
+type EntityType string
+
+const (
+	EntityMention    EntityType = "mention"     // @username участника чата
+	EntityMentionAll EntityType = "mention_all" // @all — все участники чата
+	EntityBold       EntityType = "bold"
+	EntityItalic     EntityType = "italic"
+	EntityCode       EntityType = "code"
+	EntityPre        EntityType = "pre" // блок кода, язык в Language
+	EntityTextLink   EntityType = "text_link"
+	EntitySpoiler    EntityType = "spoiler"
+	EntityBlockquote EntityType = "blockquote"
+)
+
+// MessageEntity — размеченный фрагмент текста сообщения. Offset и Length считаются
+// в кодовых единицах UTF-16, как в JavaScript-клиентах.
+type MessageEntity struct {
+	Type     EntityType `json:"type"`
+	Offset   int        `json:"offset"`
+	Length   int        `json:"length"`
+	UserID   *uuid.UUID `json:"user_id,omitempty"`  // для EntityMention
+	URL      string     `json:"url,omitempty"`      // для EntityTextLink
+	Language string     `json:"language,omitempty"` // для EntityPre
+}
+
+// TextFormat — формат, в котором отправитель прислал текст
+type TextFormat string
+
+const (
+	FormatPlain    TextFormat = "plain"
+	FormatMarkdown TextFormat = "markdown"
+	FormatHTML     TextFormat = "html"
+)
+
+func (f TextFormat) IsValid() bool {
+	return f == FormatPlain || f == FormatMarkdown || f == FormatHTML
+}
+
+// Mention — сообщение, в котором упомянули пользователя
+type Mention struct {
+	Message Message `json:"message"`
+	Read    bool    `json:"read"`
+}
+
+type MentionsPage struct {
+	Results    []Mention `json:"results"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PinnedMessage — закрепленное в чате сообщение
+type PinnedMessage struct {
+	Message  Message    `json:"message"`
+	PinnedBy *uuid.UUID `json:"pinned_by"` // NULL, если закрепивший удален
+	Position int        `json:"position"`
+	PinnedAt time.Time  `json:"pinned_at"`
+}
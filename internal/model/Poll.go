@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Poll — опрос в сообщении типа MessagePoll вместе с результатами
+type Poll struct {
+	Question      string       `json:"question"`
+	Options       []PollOption `json:"options"`
+	Multiple      bool         `json:"multiple"`  // можно выбрать несколько вариантов
+	Anonymous     bool         `json:"anonymous"` // при открытом голосовании видно, кто за что голосовал
+	Quiz          bool         `json:"quiz"`
+	CorrectOption *int         `json:"correct_option,omitempty"` // в викторине виден после ответа или закрытия опроса
+	ClosesAt      *time.Time   `json:"closes_at,omitempty"`
+	Closed        bool         `json:"closed"`
+	TotalVoters   int          `json:"total_voters"`
+	Chosen        []int        `json:"chosen,omitempty"` // варианты, выбранные текущим пользователем
+}
+
+type PollOption struct {
+	Text   string      `json:"text"`
+	Votes  int         `json:"votes"`
+	Voters []uuid.UUID `json:"voters,omitempty"` // только при открытом голосовании
+}
+
+// ForViewer скрывает ответ викторины, пока зритель не проголосовал и опрос открыт
+func (p *Poll) ForViewer() {
+	if p.Quiz && !p.Closed && len(p.Chosen) == 0 {
+		p.CorrectOption = nil
+	}
+}
+
+// Shared возвращает копию без данных конкретного пользователя — для рассылки всем участникам
+func (p *Poll) Shared() *Poll {
+	shared := *p
+	shared.Chosen = nil
+	shared.ForViewer()
+	return &shared
+}
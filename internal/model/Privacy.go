@@ -0,0 +1,23 @@
+package model
+
+type Visibility string
+
+const (
+	VisibilityEveryone Visibility = "everyone"
+	VisibilityContacts Visibility = "contacts"
+	VisibilityNobody   Visibility = "nobody"
+)
+
+func (v Visibility) IsValid() bool {
+	switch v {
+	case VisibilityEveryone, VisibilityContacts, VisibilityNobody:
+		return true
+	}
+	return false
+}
+
+// PrivacySettings — кто может видеть данные пользователя
+type PrivacySettings struct {
+	LastSeen     Visibility `json:"last_seen"`     // онлайн-статус и время последнего визита
+	PrivateChats Visibility `json:"private_chats"` // кто может начать личный чат
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ScheduledPending = "pending"
+	ScheduledFailed  = "failed" // отправить не удалось; автор может изменить время и попробовать снова
+)
+
+// ScheduledMessage — сообщение, которое будет отправлено в SendAt. Текст хранится в исходном
+// формате и разбирается при отправке.
+type ScheduledMessage struct {
+	ID        uuid.UUID  `json:"id"`
+	ChatID    uuid.UUID  `json:"chat_id"`
+	SenderID  uuid.UUID  `json:"sender_id"`
+	Content   string     `json:"content"`
+	Format    TextFormat `json:"format"`
+	SendAt    time.Time  `json:"send_at"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	Attempts  int        `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// ScheduledSend — отправка отложенного сообщения. Его строка удаляется в одной транзакции
+// с записью сообщения и только если Attempt — все еще текущая попытка: после истечения
+// аренды сообщение могла взять другая попытка.
+type ScheduledSend struct {
+	ID      uuid.UUID
+	Attempt int
+}
+
+// ScheduledMessageUpdate — изменение отложенного сообщения; nil-поля не меняются
+type ScheduledMessageUpdate struct {
+	Content *string     `json:"content"`
+	Format  *TextFormat `json:"format"`
+	SendAt  *time.Time  `json:"send_at"`
+}
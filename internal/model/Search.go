@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageSearchQuery — параметры поиска сообщений в чатах пользователя
+type MessageSearchQuery struct {
+	UserID   uuid.UUID
+	ChatIDs  []uuid.UUID // чаты, в которых пользователь состоит; поиск идет только по ним
+	Text     string
+	ChatID   *uuid.UUID
+	SenderID *uuid.UUID
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+
+	// Курсор: позиция последнего результата предыдущей страницы
+	AfterTime *time.Time
+	AfterID   *uuid.UUID
+}
+
+type MessageSearchResult struct {
+	Message Message `json:"message"`
+	Snippet string  `json:"snippet"` // фрагмент текста с совпадениями в <mark>, HTML экранирован
+}
+
+type MessageSearchPage struct {
+	Results    []MessageSearchResult `json:"results"`
+	NextCursor string                `json:"next_cursor,omitempty"`
+}
+
+// SearchDocument — сообщение в виде, в котором его хранит поисковый индекс
+type SearchDocument struct {
+	ID        uuid.UUID `json:"id"`
+	ChatID    uuid.UUID `json:"chat_id"`
+	SenderID  uuid.UUID `json:"sender_id"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchHit — найденное индексом сообщение; само сообщение загружается из базы
+type SearchHit struct {
+	MessageID uuid.UUID
+	CreatedAt time.Time
+	Snippet   string
+}
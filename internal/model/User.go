@@ -7,9 +7,68 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"password,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                  uuid.UUID  `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email,omitempty"` // виден только самому пользователю
+	Password            string     `json:"password,omitempty"`
+	IsBot               bool       `json:"is_bot"`
+	DisplayName         *string    `json:"display_name"`
+	Bio                 *string    `json:"bio"`
+	AvatarURL           *string    `json:"avatar_url"`
+	AvatarKey           *string    `json:"-"` // ключ файла аватара в хранилище
+	StatusText          *string    `json:"status_text"`
+	StatusExpiresAt     *time.Time `json:"status_expires_at"`
+	TimeZone            *string    `json:"time_zone"`
+	Status              string     `json:"status,omitempty"`       // online, away или offline, если его разрешено видеть
+	LastSeenAt          *time.Time `json:"last_seen_at,omitempty"` // пусто, если скрыто настройками приватности
+	LastSeenPrivacy     Visibility `json:"-"`
+	IsDeleted           bool       `json:"is_deleted,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"` // видно только самому пользователю
+	Role                UserRole   `json:"role,omitempty"`                  // видна только самому пользователю и администрации
+	SuspendedAt         *time.Time `json:"suspended_at,omitempty"`
+	SuspendReason       *string    `json:"suspend_reason,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// UserRole — глобальная роль пользователя на сервере, в отличие от роли в чате
+type UserRole string
+
+const (
+	UserRoleUser      UserRole = "user"
+	UserRoleModerator UserRole = "moderator" // ищет пользователей, блокирует их и просматривает чаты
+	UserRoleAdmin     UserRole = "admin"     // кроме того, сбрасывает пароли и назначает модераторов
+)
+
+var userRoleRanks = map[UserRole]int{UserRoleUser: 0, UserRoleModerator: 1, UserRoleAdmin: 2}
+
+func (r UserRole) IsValid() bool {
+	_, ok := userRoleRanks[r]
+	return ok
+}
+
+// AtLeast проверяет, что роль не ниже other
+func (r UserRole) AtLeast(other UserRole) bool {
+	return userRoleRanks[r] >= userRoleRanks[other]
+}
+
+// Outranks проверяет, что роль строго выше other: управлять можно только теми, кто ниже
+func (r UserRole) Outranks(other UserRole) bool {
+	return userRoleRanks[r] > userRoleRanks[other]
+}
+
+// UserAccess — состояние аккаунта, которое проверяется при каждом запросе
+type UserAccess struct {
+	Role               UserRole
+	Suspended          bool
+	Deleted            bool
+	SessionsValidAfter *time.Time
+}
+
+// ProfileUpdate — частичное изменение профиля: nil оставляет поле как есть, пустая строка очищает его
+type ProfileUpdate struct {
+	DisplayName     *string    `json:"display_name"`
+	Bio             *string    `json:"bio"`
+	StatusText      *string    `json:"status_text"`
+	StatusExpiresAt *time.Time `json:"status_expires_at"` // учитывается только вместе со status_text
+	TimeZone        *string    `json:"time_zone"`
 }
@@ -0,0 +1,67 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebhookEvent string
+
+const (
+	EventNewMessage      WebhookEvent = "new_message"
+	EventMessageEdited   WebhookEvent = "message_edited"  // автор изменил текст сообщения
+	EventMessageUpdated  WebhookEvent = "message_updated" // сервер дополнил сообщение, например, превью ссылки
+	EventMessageDeleted  WebhookEvent = "message_deleted"
+	EventMessagePinned   WebhookEvent = "message_pinned"
+	EventMessageUnpinned WebhookEvent = "message_unpinned"
+	EventMessagesRead    WebhookEvent = "messages_read"
+	EventMemberJoined    WebhookEvent = "member_joined"
+	EventMemberLeft      WebhookEvent = "member_left"
+)
+
+// WebhookEvents — события, на которые можно подписать вебхук
+var WebhookEvents = []WebhookEvent{
+	EventNewMessage, EventMessageEdited, EventMessageUpdated, EventMessageDeleted, EventMessagePinned,
+	EventMessageUnpinned, EventMessagesRead, EventMemberJoined, EventMemberLeft,
+}
+
+type Webhook struct {
+	ID           uuid.UUID      `json:"id"`
+	ChatID       *uuid.UUID     `json:"chat_id"` // nil — глобальный вебхук
+	CreatedBy    *uuid.UUID     `json:"created_by"`
+	URL          string         `json:"url"`
+	Secret       string         `json:"secret,omitempty"`
+	Events       []WebhookEvent `json:"events"`
+	IsActive     bool           `json:"is_active"`
+	FailureCount int            `json:"failure_count"`
+	DisabledAt   *time.Time     `json:"disabled_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryFailed    DeliveryStatus = "failed"
+)
+
+type WebhookDelivery struct {
+	ID             int64          `json:"id"`
+	WebhookID      uuid.UUID      `json:"webhook_id"`
+	Event          WebhookEvent   `json:"event"`
+	Status         DeliveryStatus `json:"status"`
+	Attempts       int            `json:"attempts"`
+	NextAttemptAt  time.Time      `json:"next_attempt_at"`
+	ResponseStatus *int           `json:"response_status"`
+	LastError      *string        `json:"last_error"`
+	CreatedAt      time.Time      `json:"created_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+
+	// Для отправки: тело запроса и адрес вебхука
+	Payload []byte `json:"-"`
+	URL     string `json:"-"`
+	Secret  string `json:"-"`
+	Global  bool   `json:"-"` // вебхук из конфигурации сервера, ему доступна внутренняя сеть
+}
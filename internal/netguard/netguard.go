@@ -0,0 +1,100 @@
+// Package netguard строит HTTP-клиенты для запросов по адресам, которые задают пользователи:
+// превью ссылок, вебхуки чатов и ботов. Адрес проверяется в момент подключения, уже после
+// разрешения имени, поэтому подмена DNS не помогает попасть во внутреннюю сеть.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenAddress = errors.New("address is not allowed")
+
+// Config задает клиент. Нулевой AllowAddr разрешает только публичные адреса.
+type Config struct {
+	Timeout      time.Duration // на весь запрос вместе с редиректами
+	MaxRedirects int           // 0 — редиректы не выполняются, возвращается сам ответ 3xx
+
+	// AllowAddr решает, можно ли подключаться к адресу; тесты могут разрешить локальный HTTP-сервер
+	AllowAddr func(netip.Addr) bool
+}
+
+func NewClient(cfg Config) *http.Client {
+	allow := cfg.AllowAddr
+	if allow == nil {
+		allow = IsPublicAddr
+	}
+
+	dialer := &net.Dialer{
+		Timeout: cfg.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			addr, err := netip.ParseAddr(host)
+			if err != nil || !allow(addr.Unmap()) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // через прокси проверка адреса потеряла бы смысл
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   cfg.Timeout,
+		ResponseHeaderTimeout: cfg.Timeout,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       30 * time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if cfg.MaxRedirects <= 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > cfg.MaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", cfg.MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// Диапазоны, которые netip не относит к частным, но которые не ведут в интернет
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // CGNAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 может вести на внутренний IPv4
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// IsPublicAddr сообщает, что адрес доступен из интернета: не локальный, не частный и не служебный
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
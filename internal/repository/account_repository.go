@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AccountRepository хранит выгрузки данных аккаунтов и стирает данные удаленных аккаунтов
+type AccountRepository struct {
+	db *sql.DB
+}
+
+func NewAccountRepository(db *sql.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+const accountExportColumns = `id, user_id, status, exported, COALESCE(error, ''), created_at, finished_at, expires_at, blob_key, attempts`
+
+func scanAccountExport(row rowScanner, e *model.AccountExport) error {
+	return row.Scan(&e.ID, &e.UserID, &e.Status, &e.Exported, &e.Error, &e.CreatedAt, &e.FinishedAt, &e.ExpiresAt, &e.BlobKey, &e.Attempts)
+}
+
+// CreateExport ставит выгрузку в очередь. Возвращает false, если у пользователя уже идет выгрузка.
+func (r *AccountRepository) CreateExport(e *model.AccountExport) (bool, error) {
+	query := `
+		INSERT INTO account_exports(user_id, created_at)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) WHERE status IN ('pending', 'running') DO NOTHING
+		RETURNING ` + accountExportColumns
+	err := scanAccountExport(r.db.QueryRow(query, e.UserID, e.CreatedAt), e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *AccountRepository) GetExport(id uuid.UUID) (*model.AccountExport, error) {
+	return r.queryExport(`SELECT `+accountExportColumns+` FROM account_exports WHERE id = $1`, id)
+}
+
+// FindActiveExport возвращает незавершенную выгрузку пользователя
+func (r *AccountRepository) FindActiveExport(userID uuid.UUID) (*model.AccountExport, error) {
+	query := `SELECT ` + accountExportColumns + ` FROM account_exports WHERE user_id = $1 AND status IN ('pending', 'running') LIMIT 1`
+	return r.queryExport(query, userID)
+}
+
+// ClaimNextExport берет в работу самую старую ожидающую выгрузку или выгрузку, чья аренда истекла
+// до staleBefore. Выгрузка начинается заново. nil — очередь пуста.
+func (r *AccountRepository) ClaimNextExport(now, staleBefore time.Time) (*model.AccountExport, error) {
+	query := `
+		UPDATE account_exports SET status = 'running', claimed_at = $1, attempts = attempts + 1, exported = 0
+		WHERE id = (
+			SELECT id FROM account_exports
+			WHERE status = 'pending' OR (status = 'running' AND claimed_at < $2)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + accountExportColumns
+	return r.queryExport(query, now, staleBefore)
+}
+
+func (r *AccountRepository) queryExport(query string, args ...interface{}) (*model.AccountExport, error) {
+	var e model.AccountExport
+	err := scanAccountExport(r.db.QueryRow(query, args...), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ExportProgress сохраняет прогресс и продлевает аренду
+func (r *AccountRepository) ExportProgress(id uuid.UUID, exported int, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE account_exports SET exported = $2, claimed_at = $3 WHERE id = $1`, id, exported, now)
+	return err
+}
+
+func (r *AccountRepository) CompleteExport(id uuid.UUID, blobKey string, now, expiresAt time.Time) error {
+	query := `UPDATE account_exports SET status = 'done', blob_key = $2, finished_at = $3, expires_at = $4 WHERE id = $1`
+	_, err := r.db.Exec(query, id, blobKey, now, expiresAt)
+	return err
+}
+
+func (r *AccountRepository) FailExport(id uuid.UUID, reason string, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE account_exports SET status = 'failed', error = $2, finished_at = $3 WHERE id = $1`, id, reason, now)
+	return err
+}
+
+// DeleteExpiredExports удаляет выгрузки с истекшим сроком хранения и возвращает ключи их архивов
+func (r *AccountRepository) DeleteExpiredExports(now time.Time) ([]string, error) {
+	rows, err := r.db.Query(`DELETE FROM account_exports WHERE expires_at <= $1 RETURNING blob_key`, now)
+	if err != nil {
+		return nil, err
+	}
+	return scanBlobKeys(rows)
+}
+
+// ClaimDueDeletion берет в работу аккаунт, срок удаления которого наступил, или аккаунт, чья
+// аренда истекла до staleBefore. uuid.Nil — удалять некого.
+func (r *AccountRepository) ClaimDueDeletion(now, staleBefore time.Time) (uuid.UUID, error) {
+	query := `
+		UPDATE users SET deletion_claimed_at = $1
+		WHERE id = (
+			SELECT id FROM users
+			WHERE deleted_at IS NULL AND deletion_scheduled_at <= $1
+			  AND (deletion_claimed_at IS NULL OR deletion_claimed_at < $2)
+			ORDER BY deletion_scheduled_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id`
+	var id uuid.UUID
+	err := r.db.QueryRow(query, now, staleBefore).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return id, err
+}
+
+// RenewDeletion продлевает аренду удаления аккаунта
+func (r *AccountRepository) RenewDeletion(id uuid.UUID, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE users SET deletion_claimed_at = $2 WHERE id = $1 AND deleted_at IS NULL`, id, now)
+	return err
+}
+
+// ErasedAccount — что осталось сделать за пределами базы после стирания аккаунта
+type ErasedAccount struct {
+	BlobKeys  []string                  // аватар и архивы выгрузок в хранилище файлов
+	LeftChats map[uuid.UUID][]uuid.UUID // группы, которые покинули пользователь и его боты, и кто именно
+}
+
+// Erase стирает личные данные аккаунта. Строка пользователя остается, чтобы у собеседников
+// сохранились история и личные чаты: в них он показывается как displayName. Из групп
+// пользователь выходит; группа без администратора получает нового — участника, вступившего
+// раньше всех. Боты пользователя лишаются токенов и тоже выходят из групп.
+// Возвращает nil, если аккаунт уже стерт.
+func (r *AccountRepository) Erase(userID uuid.UUID, displayName string, now time.Time) (*ErasedAccount, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var avatarKey sql.NullString
+	err = tx.QueryRow(`SELECT avatar_key FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, userID).Scan(&avatarKey)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	erased := &ErasedAccount{}
+	if avatarKey.Valid {
+		erased.BlobKeys = append(erased.BlobKeys, avatarKey.String)
+	}
+
+	botIDs, err := queryUUIDs(tx, `DELETE FROM bots WHERE owner_id = $1 RETURNING user_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE users SET deleted_at = $2 WHERE id = ANY($1)`, pq.Array(botIDs), now); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		DELETE FROM chat_members cm USING chats c
+		WHERE c.id = cm.chat_id AND c.type = 'group' AND (cm.user_id = $1 OR cm.user_id = ANY($2))
+		RETURNING cm.chat_id, cm.user_id`, userID, pq.Array(botIDs))
+	if err != nil {
+		return nil, err
+	}
+	erased.LeftChats = make(map[uuid.UUID][]uuid.UUID)
+	var leftIDs []uuid.UUID
+	for rows.Next() {
+		var chatID, memberID uuid.UUID
+		if err := rows.Scan(&chatID, &memberID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if _, ok := erased.LeftChats[chatID]; !ok {
+			leftIDs = append(leftIDs, chatID)
+		}
+		erased.LeftChats[chatID] = append(erased.LeftChats[chatID], memberID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(`
+		UPDATE chat_members cm SET role = 'admin'
+		FROM (
+			SELECT DISTINCT ON (m.chat_id) m.chat_id, m.user_id
+			FROM chat_members m
+			JOIN users u ON u.id = m.user_id
+			WHERE m.chat_id = ANY($1) AND NOT u.is_bot
+			  AND NOT EXISTS (SELECT 1 FROM chat_members a WHERE a.chat_id = m.chat_id AND a.role = 'admin')
+			ORDER BY m.chat_id, m.joined_at
+		) first_member
+		WHERE cm.chat_id = first_member.chat_id AND cm.user_id = first_member.user_id`, pq.Array(leftIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	// Группы без участников и личные чаты, оба участника которых удалены, больше никто не увидит
+	query := `
+		SELECT c.id FROM chats c
+		WHERE (c.id = ANY($2) AND NOT EXISTS (SELECT 1 FROM chat_members cm WHERE cm.chat_id = c.id))
+		   OR (c.type = 'private'
+		       AND EXISTS (SELECT 1 FROM chat_members cm WHERE cm.chat_id = c.id AND cm.user_id = $1)
+		       AND NOT EXISTS (
+		           SELECT 1 FROM chat_members cm JOIN users u ON u.id = cm.user_id
+		           WHERE cm.chat_id = c.id AND cm.user_id != $1 AND u.deleted_at IS NULL))
+		FOR UPDATE`
+	emptyIDs, err := queryUUIDs(tx, query, userID, pq.Array(leftIDs))
+	if err != nil {
+		return nil, err
+	}
+	// Сообщения удаляются вместе с чатами; пересланные в другие чаты копии сохраняют вложения
+	_, err = tx.Exec(`
+		UPDATE messages f SET attachments = o.attachments
+		FROM messages o
+		WHERE f.forwarded_message_id = o.id AND o.chat_id = ANY($1) AND f.attachments IS NULL AND o.attachments IS NOT NULL`, pq.Array(emptyIDs))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM chats WHERE id = ANY($1)`, pq.Array(emptyIDs)); err != nil {
+		return nil, err
+	}
+
+	for _, q := range []string{
+		`DELETE FROM contacts WHERE owner_id = $1 OR contact_id = $1`,
+		`DELETE FROM user_blocks WHERE blocker_id = $1 OR blocked_id = $1`,
+		`DELETE FROM api_tokens WHERE user_id = $1`,
+		`DELETE FROM drafts WHERE user_id = $1`,
+		`DELETE FROM scheduled_messages WHERE sender_id = $1`,
+		`DELETE FROM chat_folders WHERE user_id = $1`,
+		`DELETE FROM message_mentions WHERE user_id = $1`,
+	} {
+		if _, err := tx.Exec(q, userID); err != nil {
+			return nil, err
+		}
+	}
+	for _, q := range []string{
+		`DELETE FROM chat_exports WHERE requested_by = $1 RETURNING blob_key`,
+		`DELETE FROM account_exports WHERE user_id = $1 RETURNING blob_key`,
+		`DELETE FROM chat_imports WHERE requested_by = $1 AND status IN ('pending', 'running') RETURNING blob_key`,
+	} {
+		rows, err := tx.Query(q, userID)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := scanBlobKeys(rows)
+		if err != nil {
+			return nil, err
+		}
+		erased.BlobKeys = append(erased.BlobKeys, keys...)
+	}
+
+	// Имя автора хранится в пересланных копиях его сообщений
+	_, err = tx.Exec(`
+		UPDATE messages SET forwarded_from = jsonb_set(forwarded_from, '{sender_name}', to_jsonb($2::text))
+		WHERE forwarded_from->>'sender_id' = $1::text`, userID, displayName)
+	if err != nil {
+		return nil, err
+	}
+
+	hexID := strings.ReplaceAll(userID.String(), "-", "")
+	_, err = tx.Exec(`
+		UPDATE users SET
+			username = $2, email = $3, password = '', display_name = $4,
+			bio = NULL, avatar_key = NULL, status_text = NULL, status_expires_at = NULL, time_zone = NULL,
+			last_seen_at = NULL, privacy_last_seen = 'nobody', privacy_private_chats = 'nobody',
+			deletion_scheduled_at = NULL, deletion_claimed_at = NULL, deleted_at = $5, profile_updated_at = $5
+		WHERE id = $1`,
+		userID, "deleted_"+hexID[:16], userID.String()+"@deleted.invalid", displayName, now)
+	if err != nil {
+		return nil, err
+	}
+	return erased, tx.Commit()
+}
+
+func queryUUIDs(tx *sql.Tx, query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func scanBlobKeys(rows *sql.Rows) ([]string, error) {
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key sql.NullString
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key.Valid {
+			keys = append(keys, key.String)
+		}
+	}
+	return keys, rows.Err()
+}
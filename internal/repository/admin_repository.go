@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"messenger/internal/model"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AdminRepository выполняет запросы административного API по всем пользователям и чатам
+type AdminRepository struct {
+	db *sql.DB
+}
+
+func NewAdminRepository(db *sql.DB) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+// ListUsers возвращает страницу пользователей по фильтру, новые первыми, и общее число подходящих.
+// Авторы импортированных сообщений без своего аккаунта в список не попадают.
+func (r *AdminRepository) ListUsers(f model.UserListFilter) (*model.UserList, error) {
+	conds := []string{"NOT placeholder"}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.Query != "" {
+		p := arg("%" + f.Query + "%")
+		conds = append(conds, "(username ILIKE "+p+" OR display_name ILIKE "+p+" OR email ILIKE "+p+")")
+	}
+	if f.Role != "" {
+		conds = append(conds, "role = "+arg(f.Role))
+	}
+	switch f.State {
+	case model.AccountActive:
+		conds = append(conds, "suspended_at IS NULL AND deleted_at IS NULL")
+	case model.AccountSuspended:
+		conds = append(conds, "suspended_at IS NOT NULL AND deleted_at IS NULL")
+	case model.AccountDeleted:
+		conds = append(conds, "deleted_at IS NOT NULL")
+	}
+	if f.Bots != nil {
+		conds = append(conds, "is_bot = "+arg(*f.Bots))
+	}
+	where := " WHERE " + strings.Join(conds, " AND ")
+
+	list := &model.UserList{Users: []model.User{}}
+	if err := r.db.QueryRow(`SELECT count(*) FROM users`+where, args...).Scan(&list.Total); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users` + where +
+		` ORDER BY created_at DESC, id DESC LIMIT ` + arg(f.Limit) + ` OFFSET ` + arg(f.Offset)
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list.Users = append(list.Users, *u)
+	}
+	return list, rows.Err()
+}
+
+// Stats считает пользователей и чаты. Сообщения и подключения считаются отдельно.
+func (r *AdminRepository) Stats(now time.Time) (*model.ServerStats, error) {
+	var s model.ServerStats
+	query := `
+		SELECT
+			count(*) FILTER (WHERE NOT is_bot AND deleted_at IS NULL),
+			count(*) FILTER (WHERE is_bot AND deleted_at IS NULL),
+			count(*) FILTER (WHERE suspended_at IS NOT NULL AND deleted_at IS NULL),
+			count(*) FILTER (WHERE deleted_at IS NOT NULL),
+			count(*) FILTER (WHERE deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL),
+			count(*) FILTER (WHERE NOT is_bot AND created_at > $1)
+		FROM users
+		WHERE NOT placeholder`
+	err := r.db.QueryRow(query, now.Add(-24*time.Hour)).Scan(
+		&s.Users, &s.Bots, &s.Suspended, &s.Deleted, &s.PendingDeletion, &s.NewUsers24h)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT
+			count(*) FILTER (WHERE type = 'private'),
+			count(*) FILTER (WHERE type <> 'private')
+		FROM chats`
+	if err := r.db.QueryRow(query).Scan(&s.PrivateChats, &s.GroupChats); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LastMessageAt возвращает время последнего сообщения чата; nil, если сообщений нет
+func (r *AdminRepository) LastMessageAt(chatID uuid.UUID) (*time.Time, error) {
+	var at *time.Time
+	err := r.db.QueryRow(`SELECT max(created_at) FROM messages WHERE chat_id = $1`, chatID).Scan(&at)
+	return at, err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type APITokenRepository struct {
+	db *sql.DB
+}
+
+func NewAPITokenRepository(db *sql.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+const apiTokenColumns = `id, user_id, name, token_prefix, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+func (r *APITokenRepository) Create(t *model.APIToken, tokenHash string) error {
+	query := `
+		INSERT INTO api_tokens(user_id, name, token_hash, token_prefix, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+	return r.db.QueryRow(query, t.UserID, t.Name, tokenHash, t.Prefix, pq.Array(scopesToStrings(t.Scopes)), t.ExpiresAt).
+		Scan(&t.ID, &t.CreatedAt)
+}
+
+func (r *APITokenRepository) GetByHash(tokenHash string) (*model.APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE token_hash = $1`
+	t, err := scanAPIToken(r.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return t, err
+}
+
+func (r *APITokenRepository) ListByUser(userID uuid.UUID) ([]model.APIToken, error) {
+	query := `SELECT ` + apiTokenColumns + ` FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []model.APIToken
+	for rows.Next() {
+		t, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// Revoke отзывает токен; возвращает false, если у пользователя нет такого активного токена
+func (r *APITokenRepository) Revoke(id, userID uuid.UUID) (bool, error) {
+	query := `UPDATE api_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	res, err := r.db.Exec(query, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// TouchLastUsed обновляет время последнего использования не чаще раза в минуту,
+// чтобы не писать в базу на каждый запрос
+func (r *APITokenRepository) TouchLastUsed(id uuid.UUID) error {
+	query := `
+		UPDATE api_tokens SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIToken(row rowScanner) (*model.APIToken, error) {
+	var t model.APIToken
+	var scopes []string
+	err := row.Scan(&t.ID, &t.UserID, &t.Name, &t.Prefix, pq.Array(&scopes), &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		t.Scopes = append(t.Scopes, model.Scope(s))
+	}
+	return &t, nil
+}
+
+func scopesToStrings(scopes []model.Scope) []string {
+	out := make([]string, len(scopes))
+	for i, s := range scopes {
+		out[i] = string(s)
+	}
+	return out
+}
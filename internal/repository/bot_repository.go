@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"messenger/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type BotRepository struct {
+	db *sql.DB
+}
+
+func NewBotRepository(db *sql.DB) *BotRepository {
+	return &BotRepository{db: db}
+}
+
+// Create заводит пользователя-бота и запись о нем в одной транзакции
+func (r *BotRepository) Create(bot *model.Bot, email, tokenHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// У бота нет пароля: '!' не является bcrypt-хешем, поэтому вход по паролю невозможен
+	userQuery := `INSERT INTO users(username, email, password, is_bot) VALUES ($1, $2, '!', true) RETURNING id`
+	if err = tx.QueryRow(userQuery, bot.Username, email).Scan(&bot.ID); err != nil {
+		return err
+	}
+
+	botQuery := `INSERT INTO bots(user_id, owner_id, token_hash) VALUES ($1, $2, $3) RETURNING created_at`
+	if err = tx.QueryRow(botQuery, bot.ID, bot.OwnerID, tokenHash).Scan(&bot.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const botSelect = `
+	SELECT b.user_id, b.owner_id, u.username, b.webhook_url, b.created_at
+	FROM bots b
+	JOIN users u ON u.id = b.user_id`
+
+func (r *BotRepository) GetByID(botID uuid.UUID) (*model.Bot, error) {
+	bot, err := scanBot(r.db.QueryRow(botSelect+` WHERE b.user_id = $1`, botID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return bot, err
+}
+
+// GetByTokenHash ищет бота по токену. Бот заблокированного аккаунта или заблокированный сам не находится.
+func (r *BotRepository) GetByTokenHash(tokenHash string) (*model.Bot, error) {
+	query := botSelect + `
+		JOIN users o ON o.id = b.owner_id
+		WHERE b.token_hash = $1 AND u.suspended_at IS NULL AND o.suspended_at IS NULL`
+	bot, err := scanBot(r.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return bot, err
+}
+
+func (r *BotRepository) ListByOwner(ownerID uuid.UUID) ([]model.Bot, error) {
+	rows, err := r.db.Query(botSelect+` WHERE b.owner_id = $1 ORDER BY b.created_at`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bots []model.Bot
+	for rows.Next() {
+		bot, err := scanBot(rows)
+		if err != nil {
+			return nil, err
+		}
+		bots = append(bots, *bot)
+	}
+	return bots, rows.Err()
+}
+
+func (r *BotRepository) UpdateToken(botID uuid.UUID, tokenHash string) error {
+	_, err := r.db.Exec(`UPDATE bots SET token_hash = $2 WHERE user_id = $1`, botID, tokenHash)
+	return err
+}
+
+func (r *BotRepository) SetWebhook(botID uuid.UUID, url, secret *string) error {
+	_, err := r.db.Exec(`UPDATE bots SET webhook_url = $2, webhook_secret = $3 WHERE user_id = $1`, botID, url, secret)
+	return err
+}
+
+func (r *BotRepository) GetWebhook(botID uuid.UUID) (url, secret *string, err error) {
+	err = r.db.QueryRow(`SELECT webhook_url, webhook_secret FROM bots WHERE user_id = $1`, botID).Scan(&url, &secret)
+	return url, secret, err
+}
+
+// Delete удаляет бота вместе с его пользователем; сообщения бота удаляются каскадно
+func (r *BotRepository) Delete(botID uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM users WHERE id = $1 AND is_bot`, botID)
+	return err
+}
+
+func (r *BotRepository) SetCommands(botID uuid.UUID, commands []model.BotCommand) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`DELETE FROM bot_commands WHERE bot_id = $1`, botID); err != nil {
+		return err
+	}
+	for _, cmd := range commands {
+		query := `INSERT INTO bot_commands(bot_id, command, description) VALUES ($1, $2, $3)`
+		if _, err = tx.Exec(query, botID, cmd.Command, cmd.Description); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *BotRepository) GetCommands(botID uuid.UUID) ([]model.BotCommand, error) {
+	rows, err := r.db.Query(`SELECT command, description FROM bot_commands WHERE bot_id = $1 ORDER BY command`, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []model.BotCommand
+	for rows.Next() {
+		var cmd model.BotCommand
+		if err := rows.Scan(&cmd.Command, &cmd.Description); err != nil {
+			return nil, err
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, rows.Err()
+}
+
+// FindCommandBots возвращает ботов-участников чата, зарегистрировавших команду.
+// Если botUsername не пуст, ищется только бот с этим именем.
+func (r *BotRepository) FindCommandBots(chatID uuid.UUID, command, botUsername string) ([]uuid.UUID, error) {
+	query := `
+		SELECT bc.bot_id
+		FROM bot_commands bc
+		JOIN chat_members cm ON cm.user_id = bc.bot_id AND cm.chat_id = $1
+		JOIN users u ON u.id = bc.bot_id
+		WHERE bc.command = $2 AND ($3::text = '' OR u.username = $3::text)`
+	rows, err := r.db.Query(query, chatID, command, botUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var botIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		botIDs = append(botIDs, id)
+	}
+	return botIDs, rows.Err()
+}
+
+// AddUpdate ставит обновление в очередь бота и проставляет его ID
+func (r *BotRepository) AddUpdate(botID uuid.UUID, update *model.BotUpdate) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(`INSERT INTO bot_updates(bot_id, payload) VALUES ($1, $2) RETURNING id`, botID, payload).Scan(&update.ID)
+}
+
+// GetUpdates подтверждает (удаляет) обновления до offset и возвращает следующие
+func (r *BotRepository) GetUpdates(botID uuid.UUID, offset int64, limit int) ([]model.BotUpdate, error) {
+	if _, err := r.db.Exec(`DELETE FROM bot_updates WHERE bot_id = $1 AND id < $2`, botID, offset); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, payload FROM bot_updates WHERE bot_id = $1 AND id >= $2 ORDER BY id LIMIT $3`
+	rows, err := r.db.Query(query, botID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := []model.BotUpdate{}
+	for rows.Next() {
+		var id int64
+		var payload []byte
+		if err := rows.Scan(&id, &payload); err != nil {
+			return nil, err
+		}
+		var update model.BotUpdate
+		if err := json.Unmarshal(payload, &update); err != nil {
+			return nil, err
+		}
+		update.ID = id
+		updates = append(updates, update)
+	}
+	return updates, rows.Err()
+}
+
+func (r *BotRepository) DeleteUpdate(updateID int64) error {
+	_, err := r.db.Exec(`DELETE FROM bot_updates WHERE id = $1`, updateID)
+	return err
+}
+
+func scanBot(row rowScanner) (*model.Bot, error) {
+	var bot model.Bot
+	if err := row.Scan(&bot.ID, &bot.OwnerID, &bot.Username, &bot.WebhookURL, &bot.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &bot, nil
+}
+
+// GetChatBots возвращает ботов-участников чата
+func (r *BotRepository) GetChatBots(chatID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT cm.user_id
+		FROM chat_members cm
+		JOIN bots b ON b.user_id = cm.user_id
+		WHERE cm.chat_id = $1`
+	rows, err := r.db.Query(query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var botIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		botIDs = append(botIDs, id)
+	}
+	return botIDs, rows.Err()
+}
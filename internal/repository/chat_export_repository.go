@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChatExportRepository struct {
+	db *sql.DB
+}
+
+func NewChatExportRepository(db *sql.DB) *ChatExportRepository {
+	return &ChatExportRepository{db: db}
+}
+
+const chatExportColumns = `id, chat_id, requested_by, status, exported, total, COALESCE(error, ''), created_at, finished_at, expires_at, blob_key, attempts`
+
+func scanChatExport(row rowScanner, e *model.ChatExport) error {
+	return row.Scan(&e.ID, &e.ChatID, &e.RequestedBy, &e.Status, &e.Exported, &e.Total, &e.Error,
+		&e.CreatedAt, &e.FinishedAt, &e.ExpiresAt, &e.BlobKey, &e.Attempts)
+}
+
+// Create ставит выгрузку в очередь. Возвращает false, если у пользователя уже идет выгрузка этого чата.
+func (r *ChatExportRepository) Create(e *model.ChatExport) (bool, error) {
+	query := `
+		INSERT INTO chat_exports(chat_id, requested_by, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, requested_by) WHERE status IN ('pending', 'running') DO NOTHING
+		RETURNING ` + chatExportColumns
+	err := scanChatExport(r.db.QueryRow(query, e.ChatID, e.RequestedBy, e.CreatedAt), e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *ChatExportRepository) Get(id uuid.UUID) (*model.ChatExport, error) {
+	var e model.ChatExport
+	err := scanChatExport(r.db.QueryRow(`SELECT `+chatExportColumns+` FROM chat_exports WHERE id = $1`, id), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// FindActive возвращает незавершенную выгрузку чата, запрошенную пользователем
+func (r *ChatExportRepository) FindActive(chatID, userID uuid.UUID) (*model.ChatExport, error) {
+	query := `
+		SELECT ` + chatExportColumns + ` FROM chat_exports
+		WHERE chat_id = $1 AND requested_by = $2 AND status IN ('pending', 'running')
+		LIMIT 1`
+	var e model.ChatExport
+	err := scanChatExport(r.db.QueryRow(query, chatID, userID), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// ClaimNext берет в работу самую старую ожидающую выгрузку или выгрузку, чья аренда истекла
+// до staleBefore — ее экземпляр сервера упал. Выгрузка начинается заново. nil — очередь пуста.
+func (r *ChatExportRepository) ClaimNext(now, staleBefore time.Time) (*model.ChatExport, error) {
+	query := `
+		UPDATE chat_exports SET status = 'running', claimed_at = $1, attempts = attempts + 1, exported = 0
+		WHERE id = (
+			SELECT id FROM chat_exports
+			WHERE status = 'pending' OR (status = 'running' AND claimed_at < $2)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + chatExportColumns
+	var e model.ChatExport
+	err := scanChatExport(r.db.QueryRow(query, now, staleBefore), &e)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Progress сохраняет прогресс и продлевает аренду
+func (r *ChatExportRepository) Progress(id uuid.UUID, exported, total int, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE chat_exports SET exported = $2, total = $3, claimed_at = $4 WHERE id = $1`, id, exported, total, now)
+	return err
+}
+
+func (r *ChatExportRepository) Complete(id uuid.UUID, blobKey string, now, expiresAt time.Time) error {
+	query := `UPDATE chat_exports SET status = 'done', blob_key = $2, finished_at = $3, expires_at = $4 WHERE id = $1`
+	_, err := r.db.Exec(query, id, blobKey, now, expiresAt)
+	return err
+}
+
+func (r *ChatExportRepository) Fail(id uuid.UUID, reason string, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE chat_exports SET status = 'failed', error = $2, finished_at = $3 WHERE id = $1`, id, reason, now)
+	return err
+}
+
+// DeleteExpired удаляет выгрузки с истекшим сроком хранения и возвращает ключи их архивов
+func (r *ChatExportRepository) DeleteExpired(now time.Time) ([]string, error) {
+	rows, err := r.db.Query(`DELETE FROM chat_exports WHERE expires_at <= $1 RETURNING blob_key`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key sql.NullString
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if key.Valid {
+			keys = append(keys, key.String)
+		}
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type ChatFolderRepository struct {
+	db *sql.DB
+}
+
+func NewChatFolderRepository(db *sql.DB) *ChatFolderRepository {
+	return &ChatFolderRepository{db: db}
+}
+
+const chatFolderColumns = `id, name, position, include_chat_ids, exclude_chat_ids, include_types, unread_only, exclude_muted, exclude_archived, created_at`
+
+func (r *ChatFolderRepository) Create(userID uuid.UUID, f *model.ChatFolder) error {
+	query := `
+		INSERT INTO chat_folders(user_id, name, position, include_chat_ids, exclude_chat_ids, include_types, unread_only, exclude_muted, exclude_archived)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at`
+	return r.db.QueryRow(query, userID, f.Name, f.Position, pq.Array(uuidStrings(f.IncludeChatIDs)), pq.Array(uuidStrings(f.ExcludeChatIDs)),
+		pq.Array(chatTypeStrings(f.IncludeTypes)), f.UnreadOnly, f.ExcludeMuted, f.ExcludeArchived).Scan(&f.ID, &f.CreatedAt)
+}
+
+// Update заменяет правила папки; возвращает false, если папка не найдена у пользователя
+func (r *ChatFolderRepository) Update(userID uuid.UUID, f *model.ChatFolder) (bool, error) {
+	query := `
+		UPDATE chat_folders SET name = $3, position = $4, include_chat_ids = $5, exclude_chat_ids = $6,
+			include_types = $7, unread_only = $8, exclude_muted = $9, exclude_archived = $10
+		WHERE id = $1 AND user_id = $2
+		RETURNING created_at`
+	err := r.db.QueryRow(query, f.ID, userID, f.Name, f.Position, pq.Array(uuidStrings(f.IncludeChatIDs)), pq.Array(uuidStrings(f.ExcludeChatIDs)),
+		pq.Array(chatTypeStrings(f.IncludeTypes)), f.UnreadOnly, f.ExcludeMuted, f.ExcludeArchived).Scan(&f.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *ChatFolderRepository) Delete(userID, folderID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM chat_folders WHERE id = $1 AND user_id = $2`, folderID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ChatFolderRepository) List(userID uuid.UUID) ([]model.ChatFolder, error) {
+	rows, err := r.db.Query(`SELECT `+chatFolderColumns+` FROM chat_folders WHERE user_id = $1 ORDER BY position, created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var folders []model.ChatFolder
+	for rows.Next() {
+		var f model.ChatFolder
+		var include, exclude, types []string
+		err := rows.Scan(&f.ID, &f.Name, &f.Position, pq.Array(&include), pq.Array(&exclude), pq.Array(&types),
+			&f.UnreadOnly, &f.ExcludeMuted, &f.ExcludeArchived, &f.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if f.IncludeChatIDs, err = parseUUIDs(include); err != nil {
+			return nil, err
+		}
+		if f.ExcludeChatIDs, err = parseUUIDs(exclude); err != nil {
+			return nil, err
+		}
+		f.IncludeTypes = make([]model.TypeChat, len(types))
+		for i, t := range types {
+			f.IncludeTypes[i] = model.TypeChat(t)
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+func (r *ChatFolderRepository) Count(userID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT count(*) FROM chat_folders WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
+
+func parseUUIDs(values []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, len(values))
+	for i, v := range values {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func chatTypeStrings(types []model.TypeChat) []string {
+	out := make([]string, len(types))
+	for i, t := range types {
+		out[i] = string(t)
+	}
+	return out
+}
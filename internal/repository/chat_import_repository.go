@@ -0,0 +1,375 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ChatImportRepository struct {
+	db *sql.DB
+}
+
+func NewChatImportRepository(db *sql.DB) *ChatImportRepository {
+	return &ChatImportRepository{db: db}
+}
+
+const chatImportColumns = `id, requested_by, source, COALESCE(name, ''), status, chat_id, processed, total, imported, skipped, report,
+	COALESCE(error, ''), created_at, finished_at, COALESCE(channel, ''), user_map, blob_key, attempts`
+
+func scanChatImport(row rowScanner, i *model.ChatImport) error {
+	var report, userMap []byte
+	if err := row.Scan(&i.ID, &i.RequestedBy, &i.Source, &i.Name, &i.Status, &i.ChatID, &i.Processed, &i.Total, &i.Imported, &i.Skipped, &report,
+		&i.Error, &i.CreatedAt, &i.FinishedAt, &i.Channel, &userMap, &i.BlobKey, &i.Attempts); err != nil {
+		return err
+	}
+	i.Report, i.UserMap = []model.ImportReportItem{}, nil
+	if err := json.Unmarshal(report, &i.Report); err != nil {
+		return err
+	}
+	if userMap != nil {
+		if err := json.Unmarshal(userMap, &i.UserMap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *ChatImportRepository) Create(i *model.ChatImport) error {
+	var userMap []byte
+	if len(i.UserMap) > 0 {
+		var err error
+		if userMap, err = json.Marshal(i.UserMap); err != nil {
+			return err
+		}
+	}
+	query := `
+		INSERT INTO chat_imports(id, requested_by, source, name, channel, user_map, blob_key, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $7, $8)
+		RETURNING ` + chatImportColumns
+	return scanChatImport(r.db.QueryRow(query, i.ID, i.RequestedBy, i.Source, i.Name, i.Channel, userMap, i.BlobKey, i.CreatedAt), i)
+}
+
+func (r *ChatImportRepository) Get(id uuid.UUID) (*model.ChatImport, error) {
+	var i model.ChatImport
+	err := scanChatImport(r.db.QueryRow(`SELECT `+chatImportColumns+` FROM chat_imports WHERE id = $1`, id), &i)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// ClaimNext берет в работу самый старый ожидающий импорт или импорт, чья аренда истекла до
+// staleBefore. Прогресс сохраняется: импорт продолжится с processed. nil — очередь пуста.
+func (r *ChatImportRepository) ClaimNext(now, staleBefore time.Time) (*model.ChatImport, error) {
+	query := `
+		UPDATE chat_imports SET status = 'running', claimed_at = $1, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM chat_imports
+			WHERE status = 'pending' OR (status = 'running' AND claimed_at < $2)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + chatImportColumns
+	var i model.ChatImport
+	err := scanChatImport(r.db.QueryRow(query, now, staleBefore), &i)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// ImportUser — автор выгрузки, сопоставленный пользователю. CandidateID — пользователь,
+// которому после импорта будет предложено стать автором вместо заглушки.
+type ImportUser struct {
+	UserID      uuid.UUID
+	Placeholder bool
+	CandidateID *uuid.UUID
+}
+
+func (r *ChatImportRepository) GetUsers(importID uuid.UUID) (map[string]ImportUser, error) {
+	rows, err := r.db.Query(`SELECT foreign_id, user_id, placeholder, candidate_id FROM chat_import_users WHERE import_id = $1`, importID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]ImportUser)
+	for rows.Next() {
+		var foreignID string
+		var u ImportUser
+		if err := rows.Scan(&foreignID, &u.UserID, &u.Placeholder, &u.CandidateID); err != nil {
+			return nil, err
+		}
+		users[foreignID] = u
+	}
+	return users, rows.Err()
+}
+
+func (r *ChatImportRepository) SaveUser(importID uuid.UUID, foreignID string, u ImportUser) error {
+	query := `
+		INSERT INTO chat_import_users(import_id, foreign_id, user_id, placeholder, candidate_id) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (import_id, foreign_id) DO NOTHING`
+	_, err := r.db.Exec(query, importID, foreignID, u.UserID, u.Placeholder, u.CandidateID)
+	return err
+}
+
+// GetMessages возвращает уже перенесенные сообщения: ID в выгрузке -> ID в чате
+func (r *ChatImportRepository) GetMessages(importID uuid.UUID) (map[string]uuid.UUID, error) {
+	rows, err := r.db.Query(`SELECT foreign_id, message_id FROM chat_import_messages WHERE import_id = $1`, importID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var foreignID string
+		var id uuid.UUID
+		if err := rows.Scan(&foreignID, &id); err != nil {
+			return nil, err
+		}
+		messages[foreignID] = id
+	}
+	return messages, rows.Err()
+}
+
+// CreateChat создает групповой чат импорта и запоминает его в задаче одной транзакцией,
+// чтобы при повторном запуске не появился второй чат
+func (r *ChatImportRepository) CreateChat(importID uuid.UUID, name string, creatorID uuid.UUID, memberIDs []uuid.UUID) (*model.Chat, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	chat := model.Chat{Type: model.TypeGroup, Name: name}
+	err = tx.QueryRow(`INSERT INTO chats(type, name) VALUES ($1, $2) RETURNING id, created_at`, chat.Type, chat.Name).Scan(&chat.ID, &chat.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range memberIDs {
+		role := model.RoleMember
+		if userID == creatorID {
+			role = model.RoleAdmin
+		}
+		if _, err := tx.Exec(`INSERT INTO chat_members(chat_id, user_id, role) VALUES ($1, $2, $3)`, chat.ID, userID, role); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE chat_imports SET chat_id = $2 WHERE id = $1`, importID, chat.ID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// SaveBatch записывает пачку сообщений в чат вместе с прогрессом задачи: после сбоя пачка
+// либо записана целиком вместе с processed, либо не записана вовсе. Сообщения помечаются import_id.
+func (r *ChatImportRepository) SaveBatch(i *model.ChatImport, messages []model.ImportedMessage, report []model.ImportReportItem, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, m := range messages {
+		var entities []byte
+		if len(m.Entities) > 0 {
+			if entities, err = json.Marshal(m.Entities); err != nil {
+				return err
+			}
+		}
+		query := `
+			INSERT INTO messages(id, chat_id, sender_id, content, entities, format, type, reply_to_message_id, created_at, import_id)
+			VALUES ($1, $2, $3, $4, $5, 'plain', 'text', $6, $7, $8)`
+		if _, err := tx.Exec(query, m.ID, i.ChatID, m.SenderID, m.Content, entities, m.ReplyTo, m.CreatedAt, i.ID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO chat_import_messages(import_id, foreign_id, message_id) VALUES ($1, $2, $3)`, i.ID, m.ForeignID, m.ID); err != nil {
+			return err
+		}
+	}
+
+	if report == nil {
+		report = []model.ImportReportItem{}
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE chat_imports SET processed = $2, total = $3, imported = $4, skipped = $5,
+			report = report || $6::jsonb, claimed_at = $7
+		WHERE id = $1`
+	if _, err := tx.Exec(query, i.ID, i.Processed, i.Total, i.Imported, i.Skipped, reportJSON, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Complete завершает импорт, предлагает найденным пользователям подтвердить авторство
+// сообщений заглушек и удаляет сопоставления, нужные только на время переноса.
+// Возвращает пользователей, которым создано предложение.
+func (r *ChatImportRepository) Complete(importID, chatID uuid.UUID, now time.Time) ([]uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE chat_imports SET status = 'done', finished_at = $2 WHERE id = $1`, importID, now); err != nil {
+		return nil, err
+	}
+
+	// Предложение имеет смысл, только если у заглушки остались сообщения
+	query := `
+		INSERT INTO chat_import_claims(import_id, chat_id, placeholder_id, user_id, created_at)
+		SELECT iu.import_id, $2, iu.user_id, iu.candidate_id, $3
+		FROM chat_import_users iu
+		WHERE iu.import_id = $1 AND iu.placeholder AND iu.candidate_id IS NOT NULL
+		  AND EXISTS (SELECT 1 FROM messages m WHERE m.import_id = iu.import_id AND m.sender_id = iu.user_id)
+		ON CONFLICT (import_id, placeholder_id) DO NOTHING
+		RETURNING user_id`
+	rows, err := tx.Query(query, importID, chatID, now)
+	if err != nil {
+		return nil, err
+	}
+	var claimants []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		claimants = append(claimants, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM chat_import_messages WHERE import_id = $1`, importID); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_import_users WHERE import_id = $1`, importID); err != nil {
+		return nil, err
+	}
+	return claimants, tx.Commit()
+}
+
+const importClaimColumns = `c.id, c.import_id, c.chat_id, COALESCE(ch.name, ''), c.placeholder_id,
+	COALESCE(p.display_name, p.username), i.requested_by,
+	(SELECT COUNT(*) FROM messages m WHERE m.import_id = c.import_id AND m.sender_id = c.placeholder_id), c.created_at`
+
+const importClaimJoins = `
+		JOIN chats ch ON ch.id = c.chat_id
+		JOIN users p ON p.id = c.placeholder_id
+		JOIN chat_imports i ON i.id = c.import_id`
+
+func scanImportClaim(row rowScanner, c *model.ImportClaim) error {
+	return row.Scan(&c.ID, &c.ImportID, &c.ChatID, &c.ChatName, &c.PlaceholderID, &c.AuthorName, &c.RequestedBy, &c.Messages, &c.CreatedAt)
+}
+
+// ListClaims возвращает предложения, адресованные пользователю
+func (r *ChatImportRepository) ListClaims(userID uuid.UUID) ([]model.ImportClaim, error) {
+	query := `SELECT ` + importClaimColumns + ` FROM chat_import_claims c` + importClaimJoins + `
+		WHERE c.user_id = $1
+		ORDER BY c.created_at`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claims := []model.ImportClaim{}
+	for rows.Next() {
+		var c model.ImportClaim
+		if err := scanImportClaim(rows, &c); err != nil {
+			return nil, err
+		}
+		claims = append(claims, c)
+	}
+	return claims, rows.Err()
+}
+
+// AcceptClaim одной транзакцией передает пользователю сообщения заглушки, добавляет его
+// в чат импорта и удаляет предложение. Возвращает переданные сообщения; nil — предложения нет.
+func (r *ChatImportRepository) AcceptClaim(id, userID uuid.UUID) (*model.ImportClaim, []model.Message, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var c model.ImportClaim
+	query := `SELECT ` + importClaimColumns + ` FROM chat_import_claims c` + importClaimJoins + `
+		WHERE c.id = $1 AND c.user_id = $2
+		FOR UPDATE OF c`
+	if err := scanImportClaim(tx.QueryRow(query, id, userID), &c); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	rows, err := tx.Query(`
+		UPDATE messages SET sender_id = $3
+		WHERE import_id = $1 AND sender_id = $2
+		RETURNING id, chat_id, content, created_at`, c.ImportID, c.PlaceholderID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var messages []model.Message
+	for rows.Next() {
+		m := model.Message{SenderID: userID}
+		if err := rows.Scan(&m.ID, &m.ChatID, &m.Content, &m.CreatedAt); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := tx.Exec(`INSERT INTO chat_members(chat_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, c.ChatID, userID); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_import_claims WHERE id = $1`, id); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return &c, messages, nil
+}
+
+// DeclineClaim удаляет предложение; сообщения остаются у заглушки. Возвращает false, если предложения нет.
+func (r *ChatImportRepository) DeclineClaim(id, userID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM chat_import_claims WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ChatImportRepository) Fail(importID uuid.UUID, reason string, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE chat_imports SET status = 'failed', error = $2, finished_at = $3 WHERE id = $1`, importID, reason, now)
+	return err
+}
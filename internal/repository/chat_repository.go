@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"errors"
 	"messenger/internal/model"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type ChatRepository struct {
@@ -16,15 +18,28 @@ func NewChatRepository(db *sql.DB) *ChatRepository {
 	return &ChatRepository{db: db}
 }
 
-func (r *ChatRepository) GetUserChats(userID uuid.UUID) ([]model.ChatListItem, error) {
+// GetUserChats возвращает чаты пользователя: закрепленные сверху в заданном порядке,
+// остальные — по времени последнего сообщения. Без папки возвращается либо архив, либо
+// все неархивные чаты; с папкой — чаты, подходящие под ее правила.
+func (r *ChatRepository) GetUserChats(userID uuid.UUID, filter model.ChatListFilter, now time.Time) ([]model.ChatListItem, error) {
 	query := `
 		SELECT 
 			c.id, 
 			c.type, 
-			COALESCE(c.name, u.username) as name,
+			COALESCE(c.name, u.display_name, u.username) as name,
 			COALESCE(m.content, '') as last_message,
 			COALESCE(m.created_at, c.created_at) as last_message_time,
-			u.id as interlocutor_id
+			u.id as interlocutor_id,
+			u.last_seen_at,
+			u.privacy_last_seen,
+			unread.n,
+			CASE WHEN cm.muted_until > $4 THEN cm.muted_until END,
+			cm.pin_order IS NOT NULL,
+			cm.archived,
+			cm.marked_unread,
+			(SELECT count(*) FROM message_mentions mm WHERE mm.chat_id = c.id AND mm.user_id = $1 AND mm.read_at IS NULL),
+			c.message_ttl,
+			d.text, d.reply_to_message_id, d.updated_at
 		FROM chats c
 		JOIN chat_members cm ON c.id = cm.chat_id
 		-- Джойним собеседника только если это приватный чат
@@ -44,10 +59,25 @@ func (r *ChatRepository) GetUserChats(userID uuid.UUID) ([]model.ChatListItem, e
 			ORDER BY created_at DESC 
 			LIMIT 1
 		) m ON true
+		LEFT JOIN LATERAL (
+			SELECT count(*) AS n
+			FROM messages
+			WHERE chat_id = c.id AND sender_id != $1 AND read_at IS NULL
+		) unread ON true
+		LEFT JOIN drafts d ON d.chat_id = c.id AND d.user_id = $1
+		LEFT JOIN chat_folders f ON f.id = $2 AND f.user_id = $1
 		WHERE cm.user_id = $1
-		ORDER BY last_message_time DESC`
+		  AND CASE WHEN $2::uuid IS NULL THEN cm.archived = $3
+		      ELSE f.id IS NOT NULL
+		       AND (c.id = ANY(f.include_chat_ids) OR c.type = ANY(f.include_types))
+		       AND NOT c.id = ANY(f.exclude_chat_ids)
+		       AND (NOT f.unread_only OR unread.n > 0 OR cm.marked_unread)
+		       AND (NOT f.exclude_muted OR cm.muted_until IS NULL OR cm.muted_until <= $4)
+		       AND (NOT f.exclude_archived OR NOT cm.archived)
+		  END
+		ORDER BY cm.pin_order ASC NULLS LAST, last_message_time DESC`
 
-	rows, err := r.db.Query(query, userID)
+	rows, err := r.db.Query(query, userID, filter.FolderID, filter.Archived, now)
 	if err != nil {
 		return nil, err
 	}
@@ -56,12 +86,93 @@ func (r *ChatRepository) GetUserChats(userID uuid.UUID) ([]model.ChatListItem, e
 	var chats []model.ChatListItem
 	for rows.Next() {
 		var chat model.ChatListItem
-		if err := rows.Scan(&chat.ID, &chat.Type, &chat.Name, &chat.LastMessage, &chat.LastMessageTime, &chat.InterlocutorID); err != nil {
+		var draftText sql.NullString
+		var draftReplyTo *uuid.UUID
+		var draftUpdatedAt *time.Time
+		if err := rows.Scan(&chat.ID, &chat.Type, &chat.Name, &chat.LastMessage, &chat.LastMessageTime, &chat.InterlocutorID,
+			&chat.LastSeenAt, &chat.InterlocutorPrivacy, &chat.UnreadCount, &chat.MutedUntil, &chat.IsPinned,
+			&chat.IsArchived, &chat.MarkedUnread, &chat.UnreadMentions, &chat.MessageTTL,
+			&draftText, &draftReplyTo, &draftUpdatedAt); err != nil {
 			return nil, err
 		}
+		if draftUpdatedAt != nil {
+			chat.Draft = &model.Draft{ChatID: chat.ID, Text: draftText.String, ReplyToMessageID: draftReplyTo, UpdatedAt: *draftUpdatedAt}
+		}
 		chats = append(chats, chat)
 	}
-	return chats, nil
+	return chats, rows.Err()
+}
+
+// UpdateSettings меняет личные настройки чата участника. Закрепляемый чат ставится в конец
+// списка закрепленных. Возвращает false, если пользователь не состоит в чате.
+func (r *ChatRepository) UpdateSettings(chatID, userID uuid.UUID, upd *model.ChatSettingsUpdate, now time.Time) (bool, error) {
+	query := `
+		UPDATE chat_members SET
+			muted_until = CASE WHEN $3::timestamp IS NULL THEN muted_until
+			                   WHEN $3 <= $7 THEN NULL ELSE $3 END,
+			pin_order = CASE WHEN $4::boolean IS NULL THEN pin_order
+			                 WHEN NOT $4 THEN NULL
+			                 ELSE COALESCE(pin_order, (SELECT COALESCE(MAX(pin_order), 0) + 1 FROM chat_members WHERE user_id = $2)) END,
+			archived = COALESCE($5, archived),
+			marked_unread = COALESCE($6, marked_unread)
+		WHERE chat_id = $1 AND user_id = $2`
+	res, err := r.db.Exec(query, chatID, userID, upd.MutedUntil, upd.Pinned, upd.Archived, upd.MarkedUnread, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetPinnedChatIDs возвращает закрепленные чаты пользователя в порядке закрепления
+func (r *ChatRepository) GetPinnedChatIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`select chat_id from chat_members where user_id = $1 and pin_order is not null order by pin_order`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chatIDs []uuid.UUID
+	for rows.Next() {
+		var chatID uuid.UUID
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
+// ReorderPins задает новый порядок закрепленных чатов
+func (r *ChatRepository) ReorderPins(userID uuid.UUID, chatIDs []uuid.UUID) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `update chat_members set pin_order = $3 where user_id = $1 and chat_id = $2 and pin_order is not null`
+	for i, chatID := range chatIDs {
+		if _, err := tx.Exec(query, userID, chatID, i+1); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ClearMarkedUnread снимает отметку "непрочитано", когда пользователь прочитал чат
+func (r *ChatRepository) ClearMarkedUnread(chatID, userID uuid.UUID) error {
+	_, err := r.db.Exec(`update chat_members set marked_unread = false where chat_id = $1 and user_id = $2 and marked_unread`, chatID, userID)
+	return err
+}
+
+// UnarchiveOnNewMessage возвращает чат из архива у участников, которые его не заглушили
+func (r *ChatRepository) UnarchiveOnNewMessage(chatID uuid.UUID, now time.Time) error {
+	query := `
+		update chat_members set archived = false
+		where chat_id = $1 and archived and (muted_until is null or muted_until <= $2)`
+	_, err := r.db.Exec(query, chatID, now)
+	return err
 }
 
 func (r *ChatRepository) CreatePrivateChat(
@@ -116,7 +227,8 @@ func (r *ChatRepository) CreatePrivateChat(
 	return &chat, nil
 }
 
-func (r *ChatRepository) CreateGroupChat(name string, userIDs []uuid.UUID) (*model.Chat, error) {
+// CreateGroupChat создает группу; создатель становится ее администратором
+func (r *ChatRepository) CreateGroupChat(name string, creatorID uuid.UUID, userIDs []uuid.UUID) (*model.Chat, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
@@ -133,9 +245,13 @@ func (r *ChatRepository) CreateGroupChat(name string, userIDs []uuid.UUID) (*mod
 		return nil, err
 	}
 
-	memberQuery := `INSERT INTO chat_members(chat_id, user_id) VALUES ($1, $2)`
+	memberQuery := `INSERT INTO chat_members(chat_id, user_id, role) VALUES ($1, $2, $3)`
 	for _, uID := range userIDs {
-		if _, err = tx.Exec(memberQuery, chat.ID, uID); err != nil {
+		role := model.RoleMember
+		if uID == creatorID {
+			role = model.RoleAdmin
+		}
+		if _, err = tx.Exec(memberQuery, chat.ID, uID, role); err != nil {
 			return nil, err
 		}
 	}
@@ -177,6 +293,37 @@ func (r *ChatRepository) ExistPrivateChatByUsers(
 
 }
 
+func (r *ChatRepository) GetByID(chatID uuid.UUID) (*model.Chat, error) {
+	var chat model.Chat
+	query := `select id, type, coalesce(name, ''), no_forwards, message_ttl, created_at from chats where id = $1`
+	err := r.db.QueryRow(query, chatID).Scan(&chat.ID, &chat.Type, &chat.Name, &chat.NoForwards, &chat.MessageTTL, &chat.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func (r *ChatRepository) SetNoForwards(chatID uuid.UUID, noForwards bool) error {
+	_, err := r.db.Exec(`update chats set no_forwards = $2 where id = $1`, chatID, noForwards)
+	return err
+}
+
+// SetMessageTTL задает таймер автоудаления; nil выключает его
+func (r *ChatRepository) SetMessageTTL(chatID uuid.UUID, ttl *int) error {
+	_, err := r.db.Exec(`update chats set message_ttl = $2 where id = $1`, chatID, ttl)
+	return err
+}
+
+// AddMember добавляет пользователя в чат; повторное добавление ничего не меняет
+func (r *ChatRepository) AddMember(chatID, userID uuid.UUID) error {
+	query := `insert into chat_members(chat_id, user_id) values ($1, $2) on conflict do nothing`
+	_, err := r.db.Exec(query, chatID, userID)
+	return err
+}
+
 func (r *ChatRepository) IsChatMember(chatID, userID uuid.UUID) (bool, error) {
 	var exists bool
 	query := `select exists(select 1 from chat_members where chat_id=$1 AND user_id=$2)`
@@ -188,6 +335,19 @@ func (r *ChatRepository) IsChatMember(chatID, userID uuid.UUID) (bool, error) {
 	return exists, nil
 }
 
+// IsChatAdmin проверяет права администратора. В личных чатах оба участника считаются администраторами.
+func (r *ChatRepository) IsChatAdmin(chatID, userID uuid.UUID) (bool, error) {
+	var isAdmin bool
+	query := `
+		select exists(
+			select 1 from chat_members cm
+			join chats c on c.id = cm.chat_id
+			where cm.chat_id = $1 and cm.user_id = $2 and (cm.role = 'admin' or c.type = 'private')
+		)`
+	err := r.db.QueryRow(query, chatID, userID).Scan(&isAdmin)
+	return isAdmin, err
+}
+
 func (r *ChatRepository) Exists(chatID uuid.UUID) (bool, error) {
 	var exists bool
 	query := `select exists(select 1 from chats where id = $1)`
@@ -195,6 +355,25 @@ func (r *ChatRepository) Exists(chatID uuid.UUID) (bool, error) {
 	return exists, err
 }
 
+// GetUserChatIDs возвращает идентификаторы всех чатов пользователя
+func (r *ChatRepository) GetUserChatIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(`select chat_id from chat_members where user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chatIDs []uuid.UUID
+	for rows.Next() {
+		var chatID uuid.UUID
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, err
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs, rows.Err()
+}
+
 func (r *ChatRepository) GetChatMembers(chatID uuid.UUID) ([]uuid.UUID, error) {
 	query := `select user_id from chat_members where chat_id = $1`
 	rows, err := r.db.Query(query, chatID)
@@ -213,3 +392,111 @@ func (r *ChatRepository) GetChatMembers(chatID uuid.UUID) ([]uuid.UUID, error) {
 	}
 	return userIDs, nil
 }
+
+// GetMemberProfiles возвращает участников чата с профилями в порядке вступления
+func (r *ChatRepository) GetMemberProfiles(chatID uuid.UUID) ([]model.MemberProfile, error) {
+	query := `
+		select u.id, u.username, u.display_name, u.is_bot, cm.role, COALESCE(cm.joined_at, c.created_at)
+		from chat_members cm
+		join users u on u.id = cm.user_id
+		join chats c on c.id = cm.chat_id
+		where cm.chat_id = $1
+		order by cm.joined_at, u.username`
+	rows, err := r.db.Query(query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []model.MemberProfile{}
+	for rows.Next() {
+		var m model.MemberProfile
+		if err := rows.Scan(&m.UserID, &m.Username, &m.DisplayName, &m.IsBot, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// GetMemberships возвращает чаты пользователя в порядке вступления; личные чаты названы по собеседнику
+func (r *ChatRepository) GetMemberships(userID uuid.UUID) ([]model.Membership, error) {
+	query := `
+		select c.id, c.type, COALESCE(c.name, o.display_name, o.username, ''), cm.role, COALESCE(cm.joined_at, c.created_at)
+		from chat_members cm
+		join chats c on c.id = cm.chat_id
+		left join lateral (
+			select u.display_name, u.username
+			from chat_members cm2
+			join users u on u.id = cm2.user_id
+			where c.type = 'private' and cm2.chat_id = c.id and cm2.user_id != $1
+			limit 1
+		) o on true
+		where cm.user_id = $1
+		order by cm.joined_at, c.id`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	memberships := []model.Membership{}
+	for rows.Next() {
+		var m model.Membership
+		if err := rows.Scan(&m.ChatID, &m.Type, &m.Name, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
+// GetMemberIDsByUsernames находит среди участников чата пользователей с указанными логинами;
+// ключ результата — логин в нижнем регистре
+func (r *ChatRepository) GetMemberIDsByUsernames(chatID uuid.UUID, usernames []string) (map[string]uuid.UUID, error) {
+	query := `
+		select lower(u.username), u.id
+		from chat_members cm
+		join users u on u.id = cm.user_id
+		where cm.chat_id = $1 and lower(u.username) = any($2)`
+	rows, err := r.db.Query(query, chatID, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]uuid.UUID)
+	for rows.Next() {
+		var name string
+		var id uuid.UUID
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// GetChatPeerIDs возвращает всех пользователей, у которых есть общий чат с userID
+func (r *ChatRepository) GetChatPeerIDs(userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		select distinct cm2.user_id
+		from chat_members cm1
+		join chat_members cm2 on cm2.chat_id = cm1.chat_id
+		where cm1.user_id = $1 and cm2.user_id != $1`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, id)
+	}
+	return userIDs, rows.Err()
+}
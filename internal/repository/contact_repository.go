@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"database/sql"
+	"messenger/internal/model"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type ContactRepository struct {
+	db *sql.DB
+}
+
+func NewContactRepository(db *sql.DB) *ContactRepository {
+	return &ContactRepository{db: db}
+}
+
+// AddContact добавляет контакт или меняет имя, под которым он сохранен
+func (r *ContactRepository) AddContact(ownerID, contactID uuid.UUID, customName *string) error {
+	query := `
+		INSERT INTO contacts(owner_id, contact_id, custom_name) VALUES ($1, $2, $3)
+		ON CONFLICT (owner_id, contact_id) DO UPDATE SET custom_name = EXCLUDED.custom_name`
+	_, err := r.db.Exec(query, ownerID, contactID, customName)
+	return err
+}
+
+// RemoveContact возвращает false, если такого контакта не было
+func (r *ContactRepository) RemoveContact(ownerID, contactID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM contacts WHERE owner_id = $1 AND contact_id = $2`, ownerID, contactID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ContactRepository) ListContacts(ownerID uuid.UUID) ([]model.Contact, error) {
+	query := `
+		SELECT u.id, u.username, u.display_name, c.custom_name, c.created_at
+		FROM contacts c
+		JOIN users u ON u.id = c.contact_id
+		WHERE c.owner_id = $1
+		ORDER BY COALESCE(c.custom_name, u.display_name, u.username)`
+	rows, err := r.db.Query(query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var contacts []model.Contact
+	for rows.Next() {
+		var c model.Contact
+		if err := rows.Scan(&c.UserID, &c.Username, &c.DisplayName, &c.CustomName, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// GetContactIDs возвращает идентификаторы всех контактов владельца
+func (r *ContactRepository) GetContactIDs(ownerID uuid.UUID) ([]uuid.UUID, error) {
+	return r.queryIDs(`SELECT contact_id FROM contacts WHERE owner_id = $1`, ownerID)
+}
+
+func (r *ContactRepository) IsContact(ownerID, contactID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM contacts WHERE owner_id = $1 AND contact_id = $2)`
+	err := r.db.QueryRow(query, ownerID, contactID).Scan(&exists)
+	return exists, err
+}
+
+func (r *ContactRepository) Block(blockerID, blockedID uuid.UUID) error {
+	query := `INSERT INTO user_blocks(blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err := r.db.Exec(query, blockerID, blockedID)
+	return err
+}
+
+func (r *ContactRepository) Unblock(blockerID, blockedID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerID, blockedID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *ContactRepository) ListBlocked(blockerID uuid.UUID) ([]model.BlockedUser, error) {
+	query := `
+		SELECT u.id, u.username, b.created_at
+		FROM user_blocks b
+		JOIN users u ON u.id = b.blocked_id
+		WHERE b.blocker_id = $1
+		ORDER BY b.created_at DESC`
+	rows, err := r.db.Query(query, blockerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocked []model.BlockedUser
+	for rows.Next() {
+		var b model.BlockedUser
+		if err := rows.Scan(&b.UserID, &b.Username, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		blocked = append(blocked, b)
+	}
+	return blocked, rows.Err()
+}
+
+func (r *ContactRepository) IsBlocked(blockerID, blockedID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2)`
+	err := r.db.QueryRow(query, blockerID, blockedID).Scan(&exists)
+	return exists, err
+}
+
+// GetBlockedIDs возвращает тех, кого заблокировал пользователь
+func (r *ContactRepository) GetBlockedIDs(blockerID uuid.UUID) ([]uuid.UUID, error) {
+	return r.queryIDs(`SELECT blocked_id FROM user_blocks WHERE blocker_id = $1`, blockerID)
+}
+
+// GetBlockersAmong возвращает тех из userIDs, кто заблокировал blockedID
+func (r *ContactRepository) GetBlockersAmong(blockedID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT blocker_id FROM user_blocks WHERE blocked_id = $1 AND blocker_id = ANY($2)`
+	return r.queryIDs(query, blockedID, pq.Array(userIDs))
+}
+
+func (r *ContactRepository) queryIDs(query string, args ...interface{}) ([]uuid.UUID, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsPrivateChatBlocked проверяет, заблокировал ли кто-то из участников личного чата другого.
+// Для групповых чатов всегда возвращает false.
+func (r *ContactRepository) IsPrivateChatBlocked(chatID, senderID uuid.UUID) (bool, error) {
+	var blocked bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM chats c
+			JOIN chat_members cm ON cm.chat_id = c.id AND cm.user_id != $2
+			JOIN user_blocks b ON (b.blocker_id = cm.user_id AND b.blocked_id = $2)
+			                   OR (b.blocker_id = $2 AND b.blocked_id = cm.user_id)
+			WHERE c.id = $1 AND c.type = 'private'
+		)`
+	err := r.db.QueryRow(query, chatID, senderID).Scan(&blocked)
+	return blocked, err
+}
+
+// IsPrivateChatWithDeleted проверяет, удален ли аккаунт кого-то из участников личного чата.
+// Для групповых чатов всегда возвращает false.
+func (r *ContactRepository) IsPrivateChatWithDeleted(chatID uuid.UUID) (bool, error) {
+	var deleted bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM chats c
+			JOIN chat_members cm ON cm.chat_id = c.id
+			JOIN users u ON u.id = cm.user_id
+			WHERE c.id = $1 AND c.type = 'private' AND u.deleted_at IS NOT NULL
+		)`
+	err := r.db.QueryRow(query, chatID).Scan(&deleted)
+	return deleted, err
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type DraftRepository struct {
+	db *sql.DB
+}
+
+func NewDraftRepository(db *sql.DB) *DraftRepository {
+	return &DraftRepository{db: db}
+}
+
+func (r *DraftRepository) Get(userID, chatID uuid.UUID) (*model.Draft, error) {
+	query := `SELECT chat_id, text, reply_to_message_id, updated_at FROM drafts WHERE user_id = $1 AND chat_id = $2`
+	var d model.Draft
+	err := r.db.QueryRow(query, userID, chatID).Scan(&d.ChatID, &d.Text, &d.ReplyToMessageID, &d.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *DraftRepository) Save(userID uuid.UUID, d *model.Draft) error {
+	query := `
+		INSERT INTO drafts(user_id, chat_id, text, reply_to_message_id, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, chat_id) DO UPDATE
+		SET text = EXCLUDED.text, reply_to_message_id = EXCLUDED.reply_to_message_id, updated_at = EXCLUDED.updated_at`
+	_, err := r.db.Exec(query, userID, d.ChatID, d.Text, d.ReplyToMessageID, d.UpdatedAt)
+	return err
+}
+
+// Delete удаляет черновик; false, если его не было
+func (r *DraftRepository) Delete(userID, chatID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM drafts WHERE user_id = $1 AND chat_id = $2`, userID, chatID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteIfOlder удаляет черновик, сохраненный не позже before, — чтобы отправка сообщения
+// не стерла черновик, который пользователь успел начать на другом устройстве
+func (r *DraftRepository) DeleteIfOlder(userID, chatID uuid.UUID, before time.Time) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM drafts WHERE user_id = $1 AND chat_id = $2 AND updated_at <= $3`, userID, chatID, before)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
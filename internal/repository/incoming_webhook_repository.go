@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type IncomingWebhookRepository struct {
+	db *sql.DB
+}
+
+func NewIncomingWebhookRepository(db *sql.DB) *IncomingWebhookRepository {
+	return &IncomingWebhookRepository{db: db}
+}
+
+const incomingWebhookColumns = `id, chat_id, sender_id, name, created_by, created_at, revoked_at`
+
+// Create заводит служебного отправителя, добавляет его в чат и сохраняет вебхук в одной транзакции
+func (r *IncomingWebhookRepository) Create(w *model.IncomingWebhook, senderUsername, senderEmail, tokenHash string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Служебный пользователь не может войти по паролю: '!' не является bcrypt-хешем
+	userQuery := `INSERT INTO users(username, email, password, is_bot) VALUES ($1, $2, '!', true) RETURNING id`
+	if err = tx.QueryRow(userQuery, senderUsername, senderEmail).Scan(&w.SenderID); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`INSERT INTO chat_members(chat_id, user_id) VALUES ($1, $2)`, w.ChatID, w.SenderID); err != nil {
+		return err
+	}
+
+	hookQuery := `
+		INSERT INTO incoming_webhooks(chat_id, sender_id, name, token_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	if err = tx.QueryRow(hookQuery, w.ChatID, w.SenderID, w.Name, tokenHash, w.CreatedBy).Scan(&w.ID, &w.CreatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *IncomingWebhookRepository) GetByTokenHash(tokenHash string) (*model.IncomingWebhook, error) {
+	query := `SELECT ` + incomingWebhookColumns + ` FROM incoming_webhooks WHERE token_hash = $1`
+	w, err := scanIncomingWebhook(r.db.QueryRow(query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (r *IncomingWebhookRepository) GetByID(id uuid.UUID) (*model.IncomingWebhook, error) {
+	query := `SELECT ` + incomingWebhookColumns + ` FROM incoming_webhooks WHERE id = $1`
+	w, err := scanIncomingWebhook(r.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (r *IncomingWebhookRepository) ListByChat(chatID uuid.UUID) ([]model.IncomingWebhook, error) {
+	query := `SELECT ` + incomingWebhookColumns + ` FROM incoming_webhooks WHERE chat_id = $1 ORDER BY created_at`
+	rows, err := r.db.Query(query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []model.IncomingWebhook
+	for rows.Next() {
+		w, err := scanIncomingWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+// Revoke отзывает вебхук и убирает его отправителя из чата. Сам отправитель
+// не удаляется, чтобы уже опубликованные сообщения остались в истории.
+func (r *IncomingWebhookRepository) Revoke(w *model.IncomingWebhook) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`UPDATE incoming_webhooks SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, w.ID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM chat_members WHERE chat_id = $1 AND user_id = $2`, w.ChatID, w.SenderID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanIncomingWebhook(row rowScanner) (*model.IncomingWebhook, error) {
+	var w model.IncomingWebhook
+	if err := row.Scan(&w.ID, &w.ChatID, &w.SenderID, &w.Name, &w.CreatedBy, &w.CreatedAt, &w.RevokedAt); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
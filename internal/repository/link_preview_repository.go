@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+)
+
+type LinkPreviewRepository struct {
+	db *sql.DB
+}
+
+func NewLinkPreviewRepository(db *sql.DB) *LinkPreviewRepository {
+	return &LinkPreviewRepository{db: db}
+}
+
+// Get ищет превью в кэше. Удачные превью действительны, если загружены позже freshSince,
+// неудачные — позже failedFreshSince. found = true и nil-превью означают закэшированную неудачу.
+func (r *LinkPreviewRepository) Get(url string, freshSince, failedFreshSince time.Time) (preview *model.LinkPreview, found bool, err error) {
+	query := `
+		SELECT COALESCE(title, ''), COALESCE(description, ''), COALESCE(image_url, ''), COALESCE(site_name, ''), failed
+		FROM link_previews
+		WHERE url = $1 AND fetched_at > CASE WHEN failed THEN $3 ELSE $2 END`
+	p := model.LinkPreview{URL: url}
+	var failed bool
+	err = r.db.QueryRow(query, url, freshSince, failedFreshSince).Scan(&p.Title, &p.Description, &p.ImageURL, &p.SiteName, &failed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if failed {
+		return nil, true, nil
+	}
+	return &p, true, nil
+}
+
+// Save кэширует превью; nil означает, что превью для адреса получить не удалось
+func (r *LinkPreviewRepository) Save(url string, p *model.LinkPreview, now time.Time) error {
+	query := `
+		INSERT INTO link_previews(url, title, description, image_url, site_name, failed, fetched_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+		ON CONFLICT (url) DO UPDATE SET title = EXCLUDED.title, description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url, site_name = EXCLUDED.site_name, failed = EXCLUDED.failed, fetched_at = EXCLUDED.fetched_at`
+	if p == nil {
+		p = &model.LinkPreview{}
+	}
+	_, err := r.db.Exec(query, url, p.Title, p.Description, p.ImageURL, p.SiteName, p.Title == "" && p.Description == "", now)
+	return err
+}
@@ -2,9 +2,13 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"messenger/internal/model"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type MessageRepository struct {
@@ -15,35 +19,120 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
+// messageColumns — колонки сообщения в порядке scanMessage. Алиасы задает messageJoins:
+// m — сообщение, u — отправитель, fm — оригинал пересланного сообщения.
+const messageColumns = `m.id, m.chat_id, m.sender_id, COALESCE(m.sender_display_name, u.display_name, u.username), m.sender_icon_url,
+	m.content, m.entities, m.format, COALESCE(m.source, ''), COALESCE(m.attachments, fm.attachments), m.link_preview, m.type, m.system_action, m.forwarded_from, m.reply_to_message_id, m.created_at, m.expires_at, m.import_id,
+	CASE WHEN m.attachments IS NOT NULL THEN m.id WHEN fm.attachments IS NOT NULL THEN fm.id END`
+
+const messageJoins = `
+		JOIN users u ON m.sender_id = u.id
+		LEFT JOIN messages fm ON fm.id = m.forwarded_message_id`
+
+// ErrScheduledClaimLost — отложенное сообщение уже отправлено или взято другой попыткой
+var ErrScheduledClaimLost = errors.New("отложенное сообщение взято другой попыткой")
+
 func (r *MessageRepository) SendMessage(message *model.Message) error {
+	attachments, err := marshalAttachments(message.Attachments)
+	if err != nil {
+		return err
+	}
+	if message.Type == "" {
+		message.Type = model.MessageText
+	}
+	if message.Format == "" {
+		message.Format = model.FormatPlain
+	}
+	var entities, action, forwarded, preview []byte
+	var forwardedID *uuid.UUID
+	if len(message.Entities) > 0 {
+		if entities, err = json.Marshal(message.Entities); err != nil {
+			return err
+		}
+	}
+	if message.Action != nil {
+		if action, err = json.Marshal(message.Action); err != nil {
+			return err
+		}
+	}
+	if message.LinkPreview != nil {
+		if preview, err = json.Marshal(message.LinkPreview); err != nil {
+			return err
+		}
+	}
+	if message.Forwarded != nil {
+		if forwarded, err = json.Marshal(message.Forwarded); err != nil {
+			return err
+		}
+		// Вложения пересланного сообщения не копируются — они читаются из сообщения, где хранятся.
+		// forwarded_from нужен только для показа: его оригинал мог быть уже удален.
+		if message.AttachmentsFrom != nil {
+			forwardedID, attachments = message.AttachmentsFrom, nil
+		}
+	}
+
+	// SenderName, переданный отправителем (например, входящим вебхуком), сохраняется как подпись сообщения
 	query := `
 		WITH inserted_msg AS (
-			INSERT INTO messages(chat_id, sender_id, content) 
-			VALUES ($1, $2, $3) 
-			RETURNING id, chat_id, sender_id, content, created_at
+			INSERT INTO messages(chat_id, sender_id, content, sender_display_name, sender_icon_url, attachments, type, system_action,
+				forwarded_message_id, forwarded_from, entities, format, source, link_preview, expires_at) 
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14,
+				-- Таймер чата действует только на обычные сообщения; служебные остаются в истории
+				CASE WHEN $7 <> 'system' THEN $15::timestamp + (SELECT message_ttl FROM chats WHERE id = $1) * interval '1 second' END) 
+			RETURNING *
 		)
-		SELECT m.id, m.chat_id, m.sender_id, u.username, m.content, m.created_at
-		FROM inserted_msg m
-		JOIN users u ON m.sender_id = u.id`
+		SELECT ` + messageColumns + `
+		FROM inserted_msg m` + messageJoins
 
-	return r.db.QueryRow(query, message.ChatID, message.SenderID, message.Content).Scan(
-		&message.ID,
-		&message.ChatID,
-		&message.SenderID,
-		&message.SenderName,
-		&message.Content,
-		&message.CreatedAt,
-	)
+	// Опрос сохраняется, а отложенное сообщение удаляется из очереди вместе с сообщением в одной транзакции
+	var q interface {
+		QueryRow(query string, args ...interface{}) *sql.Row
+	} = r.db
+	var tx *sql.Tx
+	if message.Poll != nil || message.Scheduled != nil {
+		if tx, err = r.db.Begin(); err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		q = tx
+	}
+
+	row := q.QueryRow(query, message.ChatID, message.SenderID, message.Content, message.SenderName, message.IconURL, attachments, message.Type, action,
+		forwardedID, forwarded, entities, message.Format, message.Source, preview, time.Now().UTC())
+	if err := scanMessage(row, message); err != nil {
+		return err
+	}
+	if tx == nil {
+		return nil
+	}
+	if message.Poll != nil {
+		if err := insertPoll(tx, message.ID, message.Poll); err != nil {
+			return err
+		}
+	}
+	if s := message.Scheduled; s != nil {
+		res, err := tx.Exec(`DELETE FROM scheduled_messages WHERE id = $1 AND attempts = $2 AND status = 'pending'`, s.ID, s.Attempt)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrScheduledClaimLost
+		}
+	}
+	return tx.Commit()
 }
 
-func (r *MessageRepository) GetMessagesByChatID(chatID uuid.UUID) ([]model.Message, error) {
+// GetMessagesByChatID возвращает историю чата глазами viewerID: опросы приходят с результатами.
+// Истекшие сообщения, которые еще не удалены, скрываются.
+func (r *MessageRepository) GetMessagesByChatID(chatID, viewerID uuid.UUID, now time.Time) ([]model.Message, error) {
 	query := `
-		SELECT m.id, m.chat_id, m.sender_id, u.username, m.content, m.created_at 
-		FROM messages m
-		JOIN users u ON m.sender_id = u.id
-		WHERE m.chat_id = $1 
+		SELECT ` + messageColumns + `
+		FROM messages m` + messageJoins + `
+		WHERE m.chat_id = $1 AND (m.expires_at IS NULL OR m.expires_at > $2)
 		ORDER BY m.created_at ASC`
-	rows, err := r.db.Query(query, chatID)
+	rows, err := r.db.Query(query, chatID, now)
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +141,123 @@ func (r *MessageRepository) GetMessagesByChatID(chatID uuid.UUID) ([]model.Messa
 	var messages []model.Message
 	for rows.Next() {
 		var m model.Message
-		if err := rows.Scan(&m.ID, &m.ChatID, &m.SenderID, &m.SenderName, &m.Content, &m.CreatedAt); err != nil {
+		if err := scanMessage(rows, &m); err != nil {
 			return nil, err
 		}
 		messages = append(messages, m)
 	}
-	return messages, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, r.attachPolls(messages, viewerID, now)
+}
+
+// ListForExport возвращает страницу истории чата после курсора (afterTime, afterID) в порядке
+// отправки; опросы — с результатами глазами viewerID
+func (r *MessageRepository) ListForExport(chatID, viewerID uuid.UUID, afterTime *time.Time, afterID *uuid.UUID, limit int, now time.Time) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m` + messageJoins + `
+		WHERE m.chat_id = $1 AND (m.expires_at IS NULL OR m.expires_at > $2)
+		  AND ($3::timestamp IS NULL OR (m.created_at, m.id) > ($3, $4::uuid))
+		ORDER BY m.created_at, m.id
+		LIMIT $5`
+	rows, err := r.db.Query(query, chatID, now, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.Message
+	for rows.Next() {
+		var m model.Message
+		if err := scanMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, r.attachPolls(messages, viewerID, now)
+}
+
+// ListBySender возвращает страницу сообщений автора во всех чатах после курсора (afterTime, afterID)
+// в порядке отправки; опросы — с результатами глазами автора
+func (r *MessageRepository) ListBySender(senderID uuid.UUID, afterTime *time.Time, afterID *uuid.UUID, limit int, now time.Time) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m` + messageJoins + `
+		WHERE m.sender_id = $1 AND (m.expires_at IS NULL OR m.expires_at > $2)
+		  AND ($3::timestamp IS NULL OR (m.created_at, m.id) > ($3, $4::uuid))
+		ORDER BY m.created_at, m.id
+		LIMIT $5`
+	rows, err := r.db.Query(query, senderID, now, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.Message
+	for rows.Next() {
+		var m model.Message
+		if err := scanMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, r.attachPolls(messages, senderID, now)
+}
+
+// CountChatMessages считает видимые сообщения чата
+func (r *MessageRepository) CountChatMessages(chatID uuid.UUID, now time.Time) (int, error) {
+	var n int
+	query := `SELECT count(*) FROM messages WHERE chat_id = $1 AND (expires_at IS NULL OR expires_at > $2)`
+	err := r.db.QueryRow(query, chatID, now).Scan(&n)
+	return n, err
+}
+
+func (r *MessageRepository) attachPolls(messages []model.Message, viewerID uuid.UUID, now time.Time) error {
+	var ids []uuid.UUID
+	for _, m := range messages {
+		if m.Type == model.MessagePoll {
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	polls, err := loadPolls(r.db, ids, viewerID, now)
+	if err != nil {
+		return err
+	}
+	for i := range messages {
+		if p, ok := polls[messages[i].ID]; ok {
+			p.ForViewer()
+			messages[i].Poll = p
+		}
+	}
+	return nil
+}
+
+// GetMessageByID возвращает сообщение или nil, если его нет
+func (r *MessageRepository) GetMessageByID(id uuid.UUID) (*model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m` + messageJoins + `
+		WHERE m.id = $1`
+	var m model.Message
+	err := scanMessage(r.db.QueryRow(query, id), &m)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
 }
 
 func (r *MessageRepository) MarkAsRead(chatID, userID uuid.UUID) error {
@@ -70,3 +270,280 @@ func (r *MessageRepository) MarkAsRead(chatID, userID uuid.UUID) error {
 	_, err := r.db.Exec(query, chatID, userID)
 	return err
 }
+
+func (r *MessageRepository) SetLinkPreview(messageID uuid.UUID, p *model.LinkPreview) error {
+	preview, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.Exec(`UPDATE messages SET link_preview = $2 WHERE id = $1`, messageID, preview)
+	return err
+}
+
+// DeleteExpired удаляет пачку сообщений с истекшим сроком жизни и возвращает их идентификаторы
+// по чатам
+func (r *MessageRepository) DeleteExpired(now time.Time, limit int) (map[uuid.UUID][]uuid.UUID, error) {
+	// SKIP LOCKED позволяет нескольким экземплярам сервера чистить сообщения параллельно
+	return r.deleteSelected(`
+		SELECT id FROM messages
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, now, limit)
+}
+
+// DeleteBySender удаляет пачку сообщений автора и возвращает их идентификаторы по чатам
+func (r *MessageRepository) DeleteBySender(senderID uuid.UUID, limit int) (map[uuid.UUID][]uuid.UUID, error) {
+	return r.deleteSelected(`
+		SELECT id FROM messages
+		WHERE sender_id = $1
+		ORDER BY created_at, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, senderID, limit)
+}
+
+// deleteSelected удаляет сообщения, которые выбирает запрос selectQuery. Пересланные копии
+// получают собственную копию вложений, раньше разделяемых с оригиналом.
+func (r *MessageRepository) deleteSelected(selectQuery string, args ...interface{}) (map[uuid.UUID][]uuid.UUID, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(selectQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE messages f SET attachments = o.attachments
+		FROM messages o
+		WHERE f.forwarded_message_id = o.id AND o.id = ANY($1) AND f.attachments IS NULL AND o.attachments IS NOT NULL`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	deleted, err := tx.Query(`DELETE FROM messages WHERE id = ANY($1) RETURNING id, chat_id`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	byChat := make(map[uuid.UUID][]uuid.UUID)
+	for deleted.Next() {
+		var id, chatID uuid.UUID
+		if err := deleted.Scan(&id, &chatID); err != nil {
+			deleted.Close()
+			return nil, err
+		}
+		byChat[chatID] = append(byChat[chatID], id)
+	}
+	deleted.Close()
+	if err := deleted.Err(); err != nil {
+		return nil, err
+	}
+	return byChat, tx.Commit()
+}
+
+// AddMentions отмечает, что в сообщении упомянуты пользователи userIDs
+func (r *MessageRepository) AddMentions(m *model.Message, userIDs []uuid.UUID) error {
+	query := `
+		INSERT INTO message_mentions(message_id, chat_id, user_id, created_at)
+		SELECT $1, $2, unnest($3::uuid[]), $4
+		ON CONFLICT DO NOTHING`
+	_, err := r.db.Exec(query, m.ID, m.ChatID, pq.Array(userIDs), m.CreatedAt)
+	return err
+}
+
+func (r *MessageRepository) MarkMentionsRead(chatID, userID uuid.UUID, now time.Time) error {
+	query := `UPDATE message_mentions SET read_at = $3 WHERE chat_id = $1 AND user_id = $2 AND read_at IS NULL`
+	_, err := r.db.Exec(query, chatID, userID, now)
+	return err
+}
+
+// GetMentions возвращает упоминания пользователя в чатах, где он состоит, от новых к старым
+func (r *MessageRepository) GetMentions(userID uuid.UUID, unreadOnly bool, afterTime *time.Time, afterID *uuid.UUID, limit int) ([]model.Mention, error) {
+	query := `
+		SELECT ` + messageColumns + `, mm.read_at IS NOT NULL
+		FROM message_mentions mm
+		JOIN chat_members cm ON cm.chat_id = mm.chat_id AND cm.user_id = mm.user_id
+		JOIN messages m ON m.id = mm.message_id` + messageJoins + `
+		WHERE mm.user_id = $1
+		  AND (NOT $2 OR mm.read_at IS NULL)
+		  AND ($3::timestamp IS NULL OR (m.created_at, m.id) < ($3, $4::uuid))
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $5`
+	rows, err := r.db.Query(query, userID, unreadOnly, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mentions []model.Mention
+	for rows.Next() {
+		var mention model.Mention
+		if err := scanMessage(rows, &mention.Message, &mention.Read); err != nil {
+			return nil, err
+		}
+		mentions = append(mentions, mention)
+	}
+	return mentions, rows.Err()
+}
+
+// scanMessage читает колонки messageColumns, за которыми следуют дополнительные колонки extra
+func scanMessage(row rowScanner, m *model.Message, extra ...interface{}) error {
+	var entities, attachments, preview, action, forwarded []byte
+	dest := append([]interface{}{&m.ID, &m.ChatID, &m.SenderID, &m.SenderName, &m.IconURL, &m.Content, &entities, &m.Format, &m.Source, &attachments, &preview, &m.Type, &action, &forwarded, &m.ReplyTo, &m.CreatedAt, &m.ExpiresAt, &m.ImportID, &m.AttachmentsFrom}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	m.Entities = nil
+	if entities != nil {
+		if err := json.Unmarshal(entities, &m.Entities); err != nil {
+			return err
+		}
+	}
+	m.Attachments = nil
+	if attachments != nil {
+		if err := json.Unmarshal(attachments, &m.Attachments); err != nil {
+			return err
+		}
+	}
+	m.LinkPreview = nil
+	if preview != nil {
+		if err := json.Unmarshal(preview, &m.LinkPreview); err != nil {
+			return err
+		}
+	}
+	m.Action = nil
+	if action != nil {
+		if err := json.Unmarshal(action, &m.Action); err != nil {
+			return err
+		}
+	}
+	m.Forwarded = nil
+	if forwarded != nil {
+		return json.Unmarshal(forwarded, &m.Forwarded)
+	}
+	return nil
+}
+
+func marshalAttachments(attachments []model.MessageAttachment) ([]byte, error) {
+	if len(attachments) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(attachments)
+}
+
+// SearchMessages ищет сообщения по тексту только в чатах, где пользователь является участником.
+// Результаты отсортированы от новых к старым.
+func (r *MessageRepository) SearchMessages(q model.MessageSearchQuery) ([]model.SearchHit, error) {
+	// Текст экранируется до ts_headline, чтобы в сниппете HTML был только от <mark>
+	query := `
+		SELECT m.id, m.created_at,
+			ts_headline('russian',
+				replace(replace(replace(m.content, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+				tsq, 'StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=10, MaxFragments=2')
+		FROM messages m
+		JOIN chat_members cm ON cm.chat_id = m.chat_id AND cm.user_id = $1
+		CROSS JOIN websearch_to_tsquery('russian', $2) tsq
+		WHERE m.search_vector @@ tsq AND m.type <> 'system'
+		  AND ($3::uuid IS NULL OR m.chat_id = $3)
+		  AND ($4::uuid IS NULL OR m.sender_id = $4)
+		  AND ($5::timestamp IS NULL OR m.created_at >= $5)
+		  AND ($6::timestamp IS NULL OR m.created_at < $6)
+		  AND ($7::timestamp IS NULL OR (m.created_at, m.id) < ($7, $8::uuid))
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $9`
+
+	rows, err := r.db.Query(query, q.UserID, q.Text, q.ChatID, q.SenderID, q.From, q.To, q.AfterTime, q.AfterID, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []model.SearchHit
+	for rows.Next() {
+		var hit model.SearchHit
+		if err := rows.Scan(&hit.MessageID, &hit.CreatedAt, &hit.Snippet); err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// GetMessagesByIDs загружает сообщения по идентификаторам; порядок не гарантируется
+func (r *MessageRepository) GetMessagesByIDs(ids []uuid.UUID) ([]model.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages m` + messageJoins + `
+		WHERE m.id = ANY($1)`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.Message
+	for rows.Next() {
+		var m model.Message
+		if err := scanMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// ListForIndexing отдает сообщения пачками в порядке (created_at, id) для перестроения поискового индекса
+func (r *MessageRepository) ListForIndexing(afterTime *time.Time, afterID *uuid.UUID, limit int) ([]model.SearchDocument, error) {
+	query := `
+		SELECT id, chat_id, sender_id, content, created_at
+		FROM messages
+		WHERE type <> 'system' AND ($1::timestamp IS NULL OR (created_at, id) > ($1, $2::uuid))
+		ORDER BY created_at, id
+		LIMIT $3`
+	rows, err := r.db.Query(query, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []model.SearchDocument
+	for rows.Next() {
+		var d model.SearchDocument
+		if err := rows.Scan(&d.ID, &d.ChatID, &d.SenderID, &d.Content, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (r *MessageRepository) CountMessages() (int64, error) {
+	var n int64
+	err := r.db.QueryRow(`SELECT count(*) FROM messages`).Scan(&n)
+	return n, err
+}
+
+// ReindexSearch перестраивает GIN-индекс полнотекстового поиска
+func (r *MessageRepository) ReindexSearch() error {
+	_, err := r.db.Exec(`REINDEX INDEX idx_messages_search_vector`)
+	return err
+}
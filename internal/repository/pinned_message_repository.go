@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PinnedMessageRepository struct {
+	db *sql.DB
+}
+
+func NewPinnedMessageRepository(db *sql.DB) *PinnedMessageRepository {
+	return &PinnedMessageRepository{db: db}
+}
+
+// Pin закрепляет сообщение последним в списке чата. Возвращает позицию или 0, если сообщение уже закреплено.
+func (r *PinnedMessageRepository) Pin(chatID, messageID, userID uuid.UUID, now time.Time) (int, error) {
+	query := `
+		INSERT INTO pinned_messages(chat_id, message_id, pinned_by, position, pinned_at)
+		SELECT $1, $2, $3, COALESCE(MAX(position), 0) + 1, $4 FROM pinned_messages WHERE chat_id = $1
+		ON CONFLICT (chat_id, message_id) DO NOTHING
+		RETURNING position`
+	var position int
+	err := r.db.QueryRow(query, chatID, messageID, userID, now).Scan(&position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return position, err
+}
+
+func (r *PinnedMessageRepository) Unpin(chatID, messageID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM pinned_messages WHERE chat_id = $1 AND message_id = $2`, chatID, messageID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PinnedMessageRepository) Count(chatID uuid.UUID) (int, error) {
+	var n int
+	err := r.db.QueryRow(`SELECT count(*) FROM pinned_messages WHERE chat_id = $1`, chatID).Scan(&n)
+	return n, err
+}
+
+// List возвращает закрепленные сообщения чата, последние закрепленные — первыми
+func (r *PinnedMessageRepository) List(chatID uuid.UUID) ([]model.PinnedMessage, error) {
+	query := `
+		SELECT ` + messageColumns + `, p.pinned_by, p.position, p.pinned_at
+		FROM pinned_messages p
+		JOIN messages m ON m.id = p.message_id` + messageJoins + `
+		WHERE p.chat_id = $1
+		ORDER BY p.position DESC, p.pinned_at DESC`
+	rows, err := r.db.Query(query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := []model.PinnedMessage{}
+	for rows.Next() {
+		var p model.PinnedMessage
+		if err := scanMessage(rows, &p.Message, &p.PinnedBy, &p.Position, &p.PinnedAt); err != nil {
+			return nil, err
+		}
+		pins = append(pins, p)
+	}
+	return pins, rows.Err()
+}
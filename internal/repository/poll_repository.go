@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"database/sql"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type PollRepository struct {
+	db *sql.DB
+}
+
+func NewPollRepository(db *sql.DB) *PollRepository {
+	return &PollRepository{db: db}
+}
+
+// insertPoll сохраняет опрос в той же транзакции, что и сообщение
+func insertPoll(tx *sql.Tx, messageID uuid.UUID, p *model.Poll) error {
+	query := `
+		INSERT INTO polls(message_id, question, multiple, anonymous, quiz, correct_option, closes_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if _, err := tx.Exec(query, messageID, p.Question, p.Multiple, p.Anonymous, p.Quiz, p.CorrectOption, p.ClosesAt); err != nil {
+		return err
+	}
+	for i, o := range p.Options {
+		if _, err := tx.Exec(`INSERT INTO poll_options(message_id, position, text) VALUES ($1, $2, $3)`, messageID, i, o.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Vote заменяет голос пользователя выбранными вариантами
+func (r *PollRepository) Vote(messageID, userID uuid.UUID, options []int, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM poll_votes WHERE message_id = $1 AND user_id = $2`, messageID, userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO poll_votes(message_id, option, user_id, voted_at) SELECT $1, unnest($2::int[]), $3, $4`
+	if _, err := tx.Exec(query, messageID, pq.Array(options), userID, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *PollRepository) Retract(messageID, userID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM poll_votes WHERE message_id = $1 AND user_id = $2`, messageID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *PollRepository) Close(messageID uuid.UUID, now time.Time) error {
+	_, err := r.db.Exec(`UPDATE polls SET closed_at = $2 WHERE message_id = $1 AND closed_at IS NULL`, messageID, now)
+	return err
+}
+
+// Results собирает опросы с результатами голосования и выбором viewerID.
+// Ответ викторины не скрывается — это делает Poll.ForViewer.
+func (r *PollRepository) Results(messageIDs []uuid.UUID, viewerID uuid.UUID, now time.Time) (map[uuid.UUID]*model.Poll, error) {
+	return loadPolls(r.db, messageIDs, viewerID, now)
+}
+
+func loadPolls(db *sql.DB, messageIDs []uuid.UUID, viewerID uuid.UUID, now time.Time) (map[uuid.UUID]*model.Poll, error) {
+	polls := make(map[uuid.UUID]*model.Poll)
+	if len(messageIDs) == 0 {
+		return polls, nil
+	}
+	ids := pq.Array(messageIDs)
+
+	rows, err := db.Query(`
+		SELECT message_id, question, multiple, anonymous, quiz, correct_option, closes_at,
+			closed_at IS NOT NULL OR COALESCE(closes_at <= $2, false)
+		FROM polls WHERE message_id = ANY($1)`, ids, now)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		p := &model.Poll{Options: []model.PollOption{}}
+		if err := rows.Scan(&id, &p.Question, &p.Multiple, &p.Anonymous, &p.Quiz, &p.CorrectOption, &p.ClosesAt, &p.Closed); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		polls[id] = p
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Варианты с числом голосов и проголосовавшими в порядке голосования
+	rows, err = db.Query(`
+		SELECT o.message_id, o.text, count(v.user_id),
+			COALESCE(array_agg(v.user_id ORDER BY v.voted_at) FILTER (WHERE v.user_id IS NOT NULL), '{}')
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.message_id = o.message_id AND v.option = o.position
+		WHERE o.message_id = ANY($1)
+		GROUP BY o.message_id, o.position, o.text
+		ORDER BY o.message_id, o.position`, ids)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id uuid.UUID
+		var o model.PollOption
+		var voters []string
+		if err := rows.Scan(&id, &o.Text, &o.Votes, pq.Array(&voters)); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p, ok := polls[id]
+		if !ok {
+			continue
+		}
+		if !p.Anonymous {
+			if o.Voters, err = parseUUIDs(voters); err != nil {
+				rows.Close()
+				return nil, err
+			}
+		}
+		p.Options = append(p.Options, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = db.Query(`
+		SELECT message_id, count(DISTINCT user_id), COALESCE(array_agg(option ORDER BY option) FILTER (WHERE user_id = $2), '{}')
+		FROM poll_votes WHERE message_id = ANY($1)
+		GROUP BY message_id`, ids, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uuid.UUID
+		var total int
+		var chosen []int64
+		if err := rows.Scan(&id, &total, pq.Array(&chosen)); err != nil {
+			return nil, err
+		}
+		if p, ok := polls[id]; ok {
+			p.TotalVoters = total
+			for _, c := range chosen {
+				p.Chosen = append(p.Chosen, int(c))
+			}
+		}
+	}
+	return polls, rows.Err()
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ScheduledMessageRepository struct {
+	db *sql.DB
+}
+
+func NewScheduledMessageRepository(db *sql.DB) *ScheduledMessageRepository {
+	return &ScheduledMessageRepository{db: db}
+}
+
+const scheduledMessageColumns = `id, chat_id, sender_id, content, format, send_at, status, COALESCE(last_error, ''), attempts, created_at, updated_at`
+
+func scanScheduledMessage(row rowScanner, m *model.ScheduledMessage) error {
+	return row.Scan(&m.ID, &m.ChatID, &m.SenderID, &m.Content, &m.Format, &m.SendAt, &m.Status, &m.Error, &m.Attempts, &m.CreatedAt, &m.UpdatedAt)
+}
+
+func (r *ScheduledMessageRepository) Create(m *model.ScheduledMessage) error {
+	query := `
+		INSERT INTO scheduled_messages(chat_id, sender_id, content, format, send_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $6)
+		RETURNING ` + scheduledMessageColumns
+	return scanScheduledMessage(r.db.QueryRow(query, m.ChatID, m.SenderID, m.Content, m.Format, m.SendAt, m.CreatedAt), m)
+}
+
+func (r *ScheduledMessageRepository) CountPending(senderID, chatID uuid.UUID) (int, error) {
+	var n int
+	query := `SELECT count(*) FROM scheduled_messages WHERE sender_id = $1 AND chat_id = $2 AND status = 'pending'`
+	err := r.db.QueryRow(query, senderID, chatID).Scan(&n)
+	return n, err
+}
+
+// List возвращает отложенные сообщения автора, ближайшие — первыми
+func (r *ScheduledMessageRepository) List(senderID uuid.UUID, chatID *uuid.UUID) ([]model.ScheduledMessage, error) {
+	query := `
+		SELECT ` + scheduledMessageColumns + `
+		FROM scheduled_messages
+		WHERE sender_id = $1 AND ($2::uuid IS NULL OR chat_id = $2)
+		ORDER BY send_at, created_at`
+	rows, err := r.db.Query(query, senderID, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []model.ScheduledMessage{}
+	for rows.Next() {
+		var m model.ScheduledMessage
+		if err := scanScheduledMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// Update меняет сообщение автора и заново ставит его в очередь. Сообщение, которое уже
+// отправляется, не меняется. Возвращает nil, если менять нечего.
+func (r *ScheduledMessageRepository) Update(id, senderID uuid.UUID, upd *model.ScheduledMessageUpdate, now time.Time) (*model.ScheduledMessage, error) {
+	query := `
+		UPDATE scheduled_messages SET
+			content = COALESCE($3, content),
+			format = COALESCE($4, format),
+			send_at = COALESCE($5, send_at),
+			status = 'pending', attempts = 0, last_error = NULL, claimed_at = NULL,
+			updated_at = $6
+		WHERE id = $1 AND sender_id = $2 AND (claimed_at IS NULL OR status = 'failed')
+		RETURNING ` + scheduledMessageColumns
+	var m model.ScheduledMessage
+	err := scanScheduledMessage(r.db.QueryRow(query, id, senderID, upd.Content, upd.Format, upd.SendAt, now), &m)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Cancel удаляет сообщение автора, если оно еще не отправляется
+func (r *ScheduledMessageRepository) Cancel(id, senderID uuid.UUID) (bool, error) {
+	res, err := r.db.Exec(`DELETE FROM scheduled_messages WHERE id = $1 AND sender_id = $2 AND (claimed_at IS NULL OR status = 'failed')`, id, senderID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClaimDue забирает на отправку сообщения, время которых пришло. SKIP LOCKED не дает двум
+// экземплярам сервера взять одно сообщение; claimed_at — аренда: если экземпляр упал,
+// не успев отправить, сообщение снова станет доступно после staleBefore. Сообщения
+// заблокированного отправителя остаются в очереди до снятия блокировки.
+func (r *ScheduledMessageRepository) ClaimDue(now, staleBefore time.Time, limit int) ([]model.ScheduledMessage, error) {
+	query := `
+		UPDATE scheduled_messages SET claimed_at = $1, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM scheduled_messages
+			WHERE status = 'pending' AND send_at <= $1 AND (claimed_at IS NULL OR claimed_at < $2)
+			  AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = sender_id AND u.suspended_at IS NOT NULL)
+			ORDER BY send_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + scheduledMessageColumns
+	rows, err := r.db.Query(query, now, staleBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []model.ScheduledMessage
+	for rows.Next() {
+		var m model.ScheduledMessage
+		if err := scanScheduledMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// RecordFailure сохраняет ошибку попытки attempt. Сообщение остается занятым до истечения аренды и
+// тогда будет отправлено повторно; если failed, попытки прекращаются. Устаревшая попытка ничего не меняет.
+func (r *ScheduledMessageRepository) RecordFailure(id uuid.UUID, attempt int, reason string, failed bool, now time.Time) error {
+	query := `
+		UPDATE scheduled_messages SET last_error = $2, updated_at = $4,
+			status = CASE WHEN $3 THEN 'failed' ELSE status END
+		WHERE id = $1 AND attempts = $5 AND status = 'pending'`
+	_, err := r.db.Exec(query, id, reason, failed, now, attempt)
+	return err
+}
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"errors"
 	"messenger/internal/model"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -17,41 +19,50 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
+const userColumns = `id, username, email, is_bot, display_name, bio, avatar_key, status_text, status_expires_at, time_zone, last_seen_at, privacy_last_seen, deleted_at IS NOT NULL, deletion_scheduled_at, role, suspended_at, suspend_reason, created_at`
+
 func (r *UserRepository) Create(u *model.User) error {
 	query := `INSERT INTO users(username, email, password) VALUES($1,$2,$3) RETURNING id;`
 	return r.db.QueryRow(query, u.Username, u.Email, u.Password).Scan(&u.ID)
 }
 
-func (r *UserRepository) GetByEmail(email string) (*model.User, error) {
-	u := new(model.User)
-	err := r.db.QueryRow("SELECT id, username, email, password FROM users WHERE email = $1", email).Scan(&u.ID, &u.Username, &u.Email, &u.Password)
-	if err != nil {
+// CreatePlaceholder создает автора импортированных сообщений. Войти под ним нельзя:
+// пароля нет, а адрес почты зарезервирован и никому не принадлежит.
+func (r *UserRepository) CreatePlaceholder(displayName string) (*model.User, error) {
+	id := uuid.New()
+	u := &model.User{
+		ID:          id,
+		Username:    "imported_" + strings.ReplaceAll(id.String(), "-", "")[:16],
+		Email:       id.String() + "@placeholder.invalid",
+		DisplayName: &displayName,
+	}
+	query := `INSERT INTO users(id, username, email, password, display_name, placeholder) VALUES ($1, $2, $3, '', $4, true) RETURNING created_at`
+	if err := r.db.QueryRow(query, u.ID, u.Username, u.Email, displayName).Scan(&u.CreatedAt); err != nil {
 		return nil, err
 	}
 	return u, nil
 }
 
-func (r *UserRepository) GetById(id uuid.UUID) (*model.User, error) {
+func (r *UserRepository) GetByEmail(email string) (*model.User, error) {
 	u := new(model.User)
-	err := r.db.QueryRow("SELECT id, username, email FROM users WHERE id = $1", id).Scan(&u.ID, &u.Username, &u.Email)
+	query := "SELECT id, username, email, password, is_bot, role, suspended_at, suspend_reason FROM users WHERE email = $1"
+	err := r.db.QueryRow(query, email).Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.IsBot, &u.Role, &u.SuspendedAt, &u.SuspendReason)
 	if err != nil {
 		return nil, err
 	}
 	return u, nil
 }
 
+func (r *UserRepository) GetById(id uuid.UUID) (*model.User, error) {
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
+}
+
 func (r *UserRepository) GetByUsername(username string) (*model.User, error) {
-	var u model.User
-	query := `SELECT id, username, email FROM users WHERE username = $1`
-	err := r.db.QueryRow(query, username).Scan(&u.ID, &u.Username, &u.Email)
-	if err != nil {
-		return nil, err
-	}
-	return &u, nil
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = $1`, username))
 }
 
 func (r *UserRepository) SearchByUsername(username string) ([]model.User, error) {
-	query := `SELECT id, username, email FROM users WHERE username ILIKE $1 LIMIT 10`
+	query := `SELECT ` + userColumns + ` FROM users WHERE (username ILIKE $1 OR display_name ILIKE $1) AND NOT placeholder AND deleted_at IS NULL LIMIT 10`
 	rows, err := r.db.Query(query, "%"+username+"%")
 	if err != nil {
 		return nil, err
@@ -60,11 +71,11 @@ func (r *UserRepository) SearchByUsername(username string) ([]model.User, error)
 
 	var users []model.User
 	for rows.Next() {
-		var u model.User
-		if err := rows.Scan(&u.ID, &u.Username, &u.Email); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
-		users = append(users, u)
+		users = append(users, *u)
 	}
 	return users, nil
 }
@@ -85,3 +96,153 @@ func (r *UserRepository) VerifyPassword(email, password string) (*model.User, er
 	user.Password = ""
 	return user, nil
 }
+
+// UpdateProfile применяет частичное изменение профиля; пустые строки сохраняются как NULL
+func (r *UserRepository) UpdateProfile(id uuid.UUID, upd *model.ProfileUpdate) (*model.User, error) {
+	query := `
+		UPDATE users SET
+			display_name = CASE WHEN $2::text IS NULL THEN display_name ELSE NULLIF($2, '') END,
+			bio = CASE WHEN $3::text IS NULL THEN bio ELSE NULLIF($3, '') END,
+			status_text = CASE WHEN $4::text IS NULL THEN status_text ELSE NULLIF($4, '') END,
+			status_expires_at = CASE WHEN $4::text IS NULL THEN status_expires_at WHEN $4 = '' THEN NULL ELSE $5 END,
+			time_zone = CASE WHEN $6::text IS NULL THEN time_zone ELSE NULLIF($6, '') END,
+			profile_updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + userColumns
+	return scanUser(r.db.QueryRow(query, id, upd.DisplayName, upd.Bio, upd.StatusText, upd.StatusExpiresAt, upd.TimeZone))
+}
+
+// SetAvatar сохраняет ключ нового аватара и возвращает ключ предыдущего, чтобы его можно было удалить
+func (r *UserRepository) SetAvatar(id uuid.UUID, key *string) (*string, error) {
+	query := `
+		UPDATE users u SET avatar_key = $2, profile_updated_at = CURRENT_TIMESTAMP
+		FROM (SELECT avatar_key FROM users WHERE id = $1 FOR UPDATE) old
+		WHERE u.id = $1
+		RETURNING old.avatar_key`
+	var oldKey *string
+	err := r.db.QueryRow(query, id, key).Scan(&oldKey)
+	return oldKey, err
+}
+
+func (r *UserRepository) SetLastSeen(id uuid.UUID, at time.Time) error {
+	_, err := r.db.Exec(`UPDATE users SET last_seen_at = $2 WHERE id = $1`, id, at)
+	return err
+}
+
+func (r *UserRepository) GetPrivacy(id uuid.UUID) (*model.PrivacySettings, error) {
+	var p model.PrivacySettings
+	query := `SELECT privacy_last_seen, privacy_private_chats FROM users WHERE id = $1`
+	err := r.db.QueryRow(query, id).Scan(&p.LastSeen, &p.PrivateChats)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *UserRepository) UpdatePrivacy(id uuid.UUID, p *model.PrivacySettings) error {
+	query := `UPDATE users SET privacy_last_seen = $2, privacy_private_chats = $3 WHERE id = $1`
+	_, err := r.db.Exec(query, id, p.LastSeen, p.PrivateChats)
+	return err
+}
+
+// ScheduleDeletion назначает удаление аккаунта на время at. Возвращает false, если аккаунт уже удален.
+func (r *UserRepository) ScheduleDeletion(id uuid.UUID, at time.Time) (bool, error) {
+	res, err := r.db.Exec(`UPDATE users SET deletion_scheduled_at = $2 WHERE id = $1 AND deleted_at IS NULL`, id, at)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// CancelDeletion отменяет удаление, если оно еще не началось. Возвращает false, если отменять нечего.
+func (r *UserRepository) CancelDeletion(id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users SET deletion_scheduled_at = NULL, deletion_claimed_at = NULL
+		WHERE id = $1 AND deleted_at IS NULL AND deletion_scheduled_at IS NOT NULL AND deletion_claimed_at IS NULL`
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetAccess возвращает роль и состояние аккаунта для проверки доступа
+func (r *UserRepository) GetAccess(id uuid.UUID) (*model.UserAccess, error) {
+	var a model.UserAccess
+	query := `SELECT role, suspended_at IS NOT NULL, deleted_at IS NOT NULL, sessions_valid_after FROM users WHERE id = $1`
+	if err := r.db.QueryRow(query, id).Scan(&a.Role, &a.Suspended, &a.Deleted, &a.SessionsValidAfter); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *UserRepository) SetRole(id uuid.UUID, role model.UserRole) error {
+	_, err := r.db.Exec(`UPDATE users SET role = $2 WHERE id = $1`, id, role)
+	return err
+}
+
+// SetRoleByEmail назначает роль по адресу почты. Возвращает false, если такого пользователя нет.
+func (r *UserRepository) SetRoleByEmail(email string, role model.UserRole) (bool, error) {
+	res, err := r.db.Exec(`UPDATE users SET role = $2 WHERE email = $1 AND NOT is_bot AND deleted_at IS NULL`, email, role)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Suspend блокирует аккаунт и отзывает все его сессии. Возвращает false, если аккаунт уже заблокирован или удален.
+func (r *UserRepository) Suspend(id, by uuid.UUID, reason *string, now time.Time) (bool, error) {
+	query := `
+		UPDATE users SET suspended_at = $3, suspended_by = $2, suspend_reason = $4, sessions_valid_after = $3
+		WHERE id = $1 AND suspended_at IS NULL AND deleted_at IS NULL`
+	res, err := r.db.Exec(query, id, by, now, reason)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Unsuspend снимает блокировку. Возвращает false, если аккаунт не был заблокирован.
+func (r *UserRepository) Unsuspend(id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE users SET suspended_at = NULL, suspended_by = NULL, suspend_reason = NULL
+		WHERE id = $1 AND suspended_at IS NOT NULL`
+	res, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetPassword меняет хеш пароля, отзывает сессии, выданные до now, и все персональные токены
+func (r *UserRepository) SetPassword(id uuid.UUID, hash string, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE users SET password = $2, sessions_valid_after = $3 WHERE id = $1`, id, hash, now); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE api_tokens SET revoked_at = $2 WHERE user_id = $1 AND revoked_at IS NULL`, id, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanUser(row rowScanner) (*model.User, error) {
+	var u model.User
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.IsBot, &u.DisplayName, &u.Bio, &u.AvatarKey,
+		&u.StatusText, &u.StatusExpiresAt, &u.TimeZone, &u.LastSeenAt, &u.LastSeenPrivacy, &u.IsDeleted, &u.DeletionScheduledAt,
+		&u.Role, &u.SuspendedAt, &u.SuspendReason, &u.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
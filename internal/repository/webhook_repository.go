@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"messenger/internal/model"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+const webhookColumns = `id, chat_id, created_by, url, secret, events, is_active, failure_count, disabled_at, created_at`
+
+func (r *WebhookRepository) Create(w *model.Webhook) error {
+	query := `
+		INSERT INTO webhooks(chat_id, created_by, url, secret, events)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, is_active, failure_count, created_at`
+	return r.db.QueryRow(query, w.ChatID, w.CreatedBy, w.URL, w.Secret, pq.Array(eventsToStrings(w.Events))).
+		Scan(&w.ID, &w.IsActive, &w.FailureCount, &w.CreatedAt)
+}
+
+// UpsertGlobal создает или обновляет глобальный вебхук с указанным адресом
+func (r *WebhookRepository) UpsertGlobal(url, secret string) error {
+	query := `
+		INSERT INTO webhooks(url, secret) VALUES ($1, $2)
+		ON CONFLICT (url) WHERE chat_id IS NULL
+		DO UPDATE SET secret = EXCLUDED.secret`
+	_, err := r.db.Exec(query, url, secret)
+	return err
+}
+
+// DeleteGlobalExcept удаляет глобальные вебхуки, которых больше нет в конфигурации
+func (r *WebhookRepository) DeleteGlobalExcept(urls []string) error {
+	if urls == nil {
+		urls = []string{} // pq.Array(nil) превратился бы в NULL и ничего не удалил
+	}
+	query := `DELETE FROM webhooks WHERE chat_id IS NULL AND NOT (url = ANY($1))`
+	_, err := r.db.Exec(query, pq.Array(urls))
+	return err
+}
+
+func (r *WebhookRepository) GetByID(id uuid.UUID) (*model.Webhook, error) {
+	w, err := scanWebhook(r.db.QueryRow(`SELECT `+webhookColumns+` FROM webhooks WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return w, err
+}
+
+func (r *WebhookRepository) ListByChat(chatID uuid.UUID) ([]model.Webhook, error) {
+	rows, err := r.db.Query(`SELECT `+webhookColumns+` FROM webhooks WHERE chat_id = $1 ORDER BY created_at`, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []model.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (r *WebhookRepository) Delete(id uuid.UUID) error {
+	_, err := r.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	return err
+}
+
+// Enable снова включает вебхук, отключенный из-за ошибок доставки
+func (r *WebhookRepository) Enable(id uuid.UUID) error {
+	query := `UPDATE webhooks SET is_active = true, failure_count = 0, disabled_at = NULL WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// EnqueueEvent ставит событие в очередь доставки всем активным вебхукам чата и глобальным
+// вебхукам, подписанным на это событие. Возвращает число созданных доставок.
+func (r *WebhookRepository) EnqueueEvent(chatID uuid.UUID, event model.WebhookEvent, payload []byte) (int64, error) {
+	query := `
+		INSERT INTO webhook_deliveries(webhook_id, event, payload)
+		SELECT id, $2::text, $3 FROM webhooks
+		WHERE is_active
+		  AND (chat_id = $1 OR chat_id IS NULL)
+		  AND (cardinality(events) = 0 OR $2::text = ANY(events))`
+	res, err := r.db.Exec(query, chatID, string(event), payload)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ClaimDue забирает доставки, время которых пришло. Строки, захваченные другой
+// репликой, пропускаются; захваченные доставки откладываются на lease, чтобы
+// при падении процесса они были повторены.
+func (r *WebhookRepository) ClaimDue(limit int, lease time.Duration) ([]model.WebhookDelivery, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE webhook_deliveries d
+			SET attempts = d.attempts + 1,
+			    next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+			WHERE d.id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING d.id, d.webhook_id, d.event, d.payload, d.attempts
+		)
+		SELECT c.id, c.webhook_id, c.event, c.payload, c.attempts, w.url, w.secret, w.chat_id IS NULL
+		FROM claimed c
+		JOIN webhooks w ON w.id = c.webhook_id`
+	rows, err := r.db.Query(query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret, &d.Global); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered фиксирует успешную доставку и сбрасывает счетчик ошибок вебхука
+func (r *WebhookRepository) MarkDelivered(d *model.WebhookDelivery, responseStatus int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', response_status = $2, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND attempts = $3 AND status = 'pending'`
+	res, err := tx.Exec(query, d.ID, responseStatus, d.Attempts)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// Аренда истекла и доставку взяла другая попытка — ее результат главнее
+		return err
+	}
+	if _, err = tx.Exec(`UPDATE webhooks SET failure_count = 0 WHERE id = $1`, d.WebhookID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkFailed фиксирует неудачную попытку. Если retryAt == nil, попытки исчерпаны.
+// Как и MarkDelivered, ничего не меняет, если доставку уже взяла следующая попытка.
+// Вебхук отключается, когда число неудач подряд достигает disableAfter.
+// Возвращает true, если вебхук был отключен этим вызовом.
+func (r *WebhookRepository) MarkFailed(d *model.WebhookDelivery, responseStatus *int, lastError string, retryAt *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	status := model.DeliveryPending
+	if retryAt == nil {
+		status = model.DeliveryFailed
+	}
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2, response_status = $3, last_error = $4, next_attempt_at = COALESCE($5, next_attempt_at)
+		WHERE id = $1 AND attempts = $6 AND status = 'pending'`
+	res, err := tx.Exec(query, d.ID, status, responseStatus, lastError, retryAt, d.Attempts)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	var disabled bool
+	webhookQuery := `
+		UPDATE webhooks
+		SET failure_count = failure_count + 1,
+		    is_active = failure_count + 1 < $2,
+		    disabled_at = CASE WHEN failure_count + 1 >= $2 THEN CURRENT_TIMESTAMP ELSE disabled_at END
+		WHERE id = $1
+		RETURNING is_active = false AND failure_count = $2`
+	if err = tx.QueryRow(webhookQuery, d.WebhookID, disableAfter).Scan(&disabled); err != nil {
+		return false, err
+	}
+
+	if disabled {
+		// Отключенному вебхуку больше нечего доставлять
+		cancelQuery := `
+			UPDATE webhook_deliveries SET status = 'failed', last_error = 'webhook disabled'
+			WHERE webhook_id = $1 AND status = 'pending'`
+		if _, err = tx.Exec(cancelQuery, d.WebhookID); err != nil {
+			return false, err
+		}
+	}
+	return disabled, tx.Commit()
+}
+
+func (r *WebhookRepository) ListDeliveries(webhookID uuid.UUID, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT id, webhook_id, event, status, attempts, next_attempt_at, response_status, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY id DESC
+		LIMIT $2`
+	rows, err := r.db.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.WebhookDelivery
+	for rows.Next() {
+		var d model.WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// DeleteDeliveredBefore очищает журнал от старых успешных доставок
+func (r *WebhookRepository) DeleteDeliveredBefore(before time.Time) error {
+	_, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE status = 'delivered' AND delivered_at < $1`, before)
+	return err
+}
+
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	var w model.Webhook
+	var events []string
+	err := row.Scan(&w.ID, &w.ChatID, &w.CreatedBy, &w.URL, &w.Secret, pq.Array(&events), &w.IsActive,
+		&w.FailureCount, &w.DisabledAt, &w.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	w.Events = []model.WebhookEvent{}
+	for _, e := range events {
+		w.Events = append(w.Events, model.WebhookEvent(e))
+	}
+	return &w, nil
+}
+
+func eventsToStrings(events []model.WebhookEvent) []string {
+	out := make([]string, len(events))
+	for i, e := range events {
+		out[i] = string(e)
+	}
+	return out
+}
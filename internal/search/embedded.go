@@ -0,0 +1,386 @@
+package search
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"log"
+	"messenger/internal/model"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	snapshotFile = "snapshot.gob"
+	walFile      = "wal.log"
+	lockFile     = "LOCK"
+
+	compactAfterOps = 50000 // записей в журнале до сворачивания в снимок
+)
+
+// ErrIndexLocked — каталог встроенного индекса уже открыт другим процессом, например сервером
+var ErrIndexLocked = errors.New("search index is in use by another process")
+
+// walRecord — одна операция журнала изменений
+type walRecord struct {
+	Op  string                `json:"op"` // put или del
+	Doc *model.SearchDocument `json:"doc,omitempty"`
+	ID  uuid.UUID             `json:"id,omitempty"`
+}
+
+// EmbeddedIndex — встроенный инвертированный индекс с поиском по префиксам и с
+// допуском опечаток. Документы хранятся на диске как снимок плюс журнал изменений
+// и целиком загружаются в память при открытии. Рассчитан на небольшие инсталляции;
+// один каталог может открыть только один процесс, это обеспечивает блокировка файла LOCK.
+type EmbeddedIndex struct {
+	dir  string
+	lock *os.File
+
+	mu       sync.RWMutex
+	docs     map[uuid.UUID]model.SearchDocument
+	postings map[string]map[uuid.UUID]struct{}
+	wal      *os.File
+	walOps   int
+}
+
+func OpenEmbeddedIndex(dir string) (*EmbeddedIndex, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &EmbeddedIndex{
+		dir:      dir,
+		lock:     lock,
+		docs:     make(map[uuid.UUID]model.SearchDocument),
+		postings: make(map[string]map[uuid.UUID]struct{}),
+	}
+	if err := i.open(); err != nil {
+		lock.Close()
+		return nil, err
+	}
+	return i, nil
+}
+
+func (i *EmbeddedIndex) open() error {
+	if err := i.loadSnapshot(); err != nil {
+		return err
+	}
+	if err := i.replayWAL(); err != nil {
+		return err
+	}
+	wal, err := os.OpenFile(filepath.Join(i.dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	i.wal = wal
+	return nil
+}
+
+func (i *EmbeddedIndex) Index(docs ...model.SearchDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	records := make([]walRecord, len(docs))
+	for n := range docs {
+		i.put(docs[n])
+		records[n] = walRecord{Op: "put", Doc: &docs[n]}
+	}
+	return i.appendWAL(records)
+}
+
+func (i *EmbeddedIndex) Delete(ids ...uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	records := make([]walRecord, len(ids))
+	for n, id := range ids {
+		i.remove(id)
+		records[n] = walRecord{Op: "del", ID: id}
+	}
+	return i.appendWAL(records)
+}
+
+func (i *EmbeddedIndex) Search(q model.MessageSearchQuery) ([]model.SearchHit, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	queryTerms := uniqueTerms(q.Text)
+	if len(queryTerms) == 0 {
+		return nil, nil
+	}
+
+	allowed := make(map[uuid.UUID]bool, len(q.ChatIDs))
+	for _, chatID := range q.ChatIDs {
+		if q.ChatID == nil || *q.ChatID == chatID {
+			allowed[chatID] = true
+		}
+	}
+
+	// Каждое слово запроса должно найтись в документе хотя бы в одной из своих форм
+	matched := make(map[string]bool)
+	var candidates map[uuid.UUID]struct{}
+	for _, qt := range queryTerms {
+		found := make(map[uuid.UUID]struct{})
+		for term, ids := range i.postings {
+			if !termMatches(qt, term) {
+				continue
+			}
+			matched[term] = true
+			for id := range ids {
+				if candidates == nil {
+					found[id] = struct{}{}
+				} else if _, ok := candidates[id]; ok {
+					found[id] = struct{}{}
+				}
+			}
+		}
+		candidates = found
+		if len(candidates) == 0 {
+			return nil, nil
+		}
+	}
+
+	var docs []model.SearchDocument
+	for id := range candidates {
+		d := i.docs[id]
+		if !allowed[d.ChatID] || !matchesFilters(d, q) {
+			continue
+		}
+		docs = append(docs, d)
+	}
+
+	sort.Slice(docs, func(a, b int) bool { return before(docs[b], docs[a].CreatedAt, docs[a].ID) })
+	if q.Limit > 0 && len(docs) > q.Limit {
+		docs = docs[:q.Limit]
+	}
+
+	hits := make([]model.SearchHit, len(docs))
+	for n, d := range docs {
+		hits[n] = model.SearchHit{MessageID: d.ID, CreatedAt: d.CreatedAt, Snippet: snippet(d.Content, matched)}
+	}
+	return hits, nil
+}
+
+// Reset очищает индекс перед полным перестроением
+func (i *EmbeddedIndex) Reset() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.docs = make(map[uuid.UUID]model.SearchDocument)
+	i.postings = make(map[string]map[uuid.UUID]struct{})
+	if err := os.Remove(filepath.Join(i.dir, snapshotFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return i.truncateWAL()
+}
+
+// Close сворачивает журнал в снимок, чтобы следующее открытие было быстрым
+func (i *EmbeddedIndex) Close() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	err := i.compact()
+	if closeErr := i.wal.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := i.lock.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (i *EmbeddedIndex) put(d model.SearchDocument) {
+	i.remove(d.ID)
+	i.docs[d.ID] = d
+	for _, t := range tokenize(d.Content) {
+		ids, ok := i.postings[t.term]
+		if !ok {
+			ids = make(map[uuid.UUID]struct{})
+			i.postings[t.term] = ids
+		}
+		ids[d.ID] = struct{}{}
+	}
+}
+
+func (i *EmbeddedIndex) remove(id uuid.UUID) {
+	old, ok := i.docs[id]
+	if !ok {
+		return
+	}
+	for _, t := range tokenize(old.Content) {
+		if ids, ok := i.postings[t.term]; ok {
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(i.postings, t.term)
+			}
+		}
+	}
+	delete(i.docs, id)
+}
+
+func (i *EmbeddedIndex) appendWAL(records []walRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for n := range records {
+		if err := enc.Encode(&records[n]); err != nil {
+			return err
+		}
+	}
+	if _, err := i.wal.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if err := i.wal.Sync(); err != nil {
+		return err
+	}
+
+	i.walOps += len(records)
+	if i.walOps >= compactAfterOps {
+		return i.compact()
+	}
+	return nil
+}
+
+// compact записывает все документы в новый снимок и очищает журнал
+func (i *EmbeddedIndex) compact() error {
+	docs := make([]model.SearchDocument, 0, len(i.docs))
+	for _, d := range i.docs {
+		docs = append(docs, d)
+	}
+
+	tmp := filepath.Join(i.dir, snapshotFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := gob.NewEncoder(w).Encode(docs); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(i.dir, snapshotFile)); err != nil {
+		return err
+	}
+	return i.truncateWAL()
+}
+
+func (i *EmbeddedIndex) truncateWAL() error {
+	i.walOps = 0
+	if i.wal == nil {
+		return nil
+	}
+	return i.wal.Truncate(0)
+}
+
+func (i *EmbeddedIndex) loadSnapshot() error {
+	f, err := os.Open(filepath.Join(i.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var docs []model.SearchDocument
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&docs); err != nil {
+		return err
+	}
+	for _, d := range docs {
+		i.put(d)
+	}
+	return nil
+}
+
+func (i *EmbeddedIndex) replayWAL() error {
+	f, err := os.Open(filepath.Join(i.dir, walFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var rec walRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// Недописанная последняя запись после аварийного завершения
+			log.Printf("search index: skipping corrupted journal record: %v", err)
+			continue
+		}
+		switch {
+		case rec.Op == "put" && rec.Doc != nil:
+			i.put(*rec.Doc)
+		case rec.Op == "del":
+			i.remove(rec.ID)
+		}
+		i.walOps++
+	}
+	return scanner.Err()
+}
+
+func uniqueTerms(text string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	for _, t := range tokenize(text) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+	return terms
+}
+
+func matchesFilters(d model.SearchDocument, q model.MessageSearchQuery) bool {
+	if q.SenderID != nil && d.SenderID != *q.SenderID {
+		return false
+	}
+	if q.From != nil && d.CreatedAt.Before(*q.From) {
+		return false
+	}
+	if q.To != nil && !d.CreatedAt.Before(*q.To) {
+		return false
+	}
+	if q.AfterTime != nil && q.AfterID != nil && !before(d, *q.AfterTime, *q.AfterID) {
+		return false
+	}
+	return true
+}
+
+// before сообщает, идет ли документ раньше позиции (createdAt, id) — тот же порядок, что и в Postgres
+func before(d model.SearchDocument, createdAt time.Time, id uuid.UUID) bool {
+	if !d.CreatedAt.Equal(createdAt) {
+		return d.CreatedAt.Before(createdAt)
+	}
+	return bytes.Compare(d.ID[:], id[:]) < 0
+}
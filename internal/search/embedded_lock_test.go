@@ -0,0 +1,29 @@
+//go:build unix
+
+package search
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEmbeddedIndexLocksDir(t *testing.T) {
+	dir := t.TempDir()
+	first, err := OpenEmbeddedIndex(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenEmbeddedIndex(dir); !errors.Is(err, ErrIndexLocked) {
+		t.Fatalf("second open: err = %v, want ErrIndexLocked", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenEmbeddedIndex(dir)
+	if err != nil {
+		t.Fatalf("open after close: %v", err)
+	}
+	second.Close()
+}
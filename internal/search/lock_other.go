@@ -0,0 +1,13 @@
+//go:build !unix
+
+package search
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// lockDir на системах без flock только создает файл блокировки: защиты от второго процесса нет
+func lockDir(dir string) (*os.File, error) {
+	return os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o600)
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package search
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// lockDir захватывает каталог индекса на время работы процесса. Блокировка снимается
+// при закрытии файла или завершении процесса, поэтому после падения ее не нужно чистить.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%w: %s", ErrIndexLocked, dir)
+		}
+		return nil, err
+	}
+	return f, nil
+}
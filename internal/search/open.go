@@ -0,0 +1,32 @@
+package search
+
+import (
+	"fmt"
+	"messenger/internal/config"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Index — общий интерфейс реализаций поискового индекса
+type Index interface {
+	Index(docs ...model.SearchDocument) error
+	Delete(ids ...uuid.UUID) error
+	Search(q model.MessageSearchQuery) ([]model.SearchHit, error)
+	// Reset очищает индекс перед полным перестроением
+	Reset() error
+	Close() error
+}
+
+// Open создает индекс, выбранный в конфигурации
+func Open(cfg config.SearchConfig, repo *repository.MessageRepository) (Index, error) {
+	switch cfg.Backend {
+	case config.SearchBackendPostgres:
+		return NewPostgresIndex(repo), nil
+	case config.SearchBackendEmbedded:
+		return OpenEmbeddedIndex(cfg.IndexDir)
+	default:
+		return nil, fmt.Errorf("unknown search backend %q", cfg.Backend)
+	}
+}
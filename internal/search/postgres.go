@@ -0,0 +1,39 @@
+package search
+
+import (
+	"messenger/internal/model"
+	"messenger/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// PostgresIndex ищет по tsvector-колонке messages.search_vector. Колонка
+// генерируется базой, поэтому индексировать и удалять документы не нужно.
+type PostgresIndex struct {
+	repo *repository.MessageRepository
+}
+
+func NewPostgresIndex(repo *repository.MessageRepository) *PostgresIndex {
+	return &PostgresIndex{repo: repo}
+}
+
+func (i *PostgresIndex) Index(docs ...model.SearchDocument) error {
+	return nil
+}
+
+func (i *PostgresIndex) Delete(ids ...uuid.UUID) error {
+	return nil
+}
+
+func (i *PostgresIndex) Search(q model.MessageSearchQuery) ([]model.SearchHit, error) {
+	return i.repo.SearchMessages(q)
+}
+
+// Reset перестраивает GIN-индекс; содержимое индекса база вычисляет сама
+func (i *PostgresIndex) Reset() error {
+	return i.repo.ReindexSearch()
+}
+
+func (i *PostgresIndex) Close() error {
+	return nil
+}
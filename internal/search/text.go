@@ -0,0 +1,142 @@
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const snippetRadius = 10 // слов вокруг первого совпадения
+
+type token struct {
+	term       string
+	start, end int // байтовые смещения в исходном тексте
+}
+
+// tokenize разбивает текст на слова в нижнем регистре; ё приравнивается к е
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			tokens = append(tokens, token{term: normalize(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: normalize(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func normalize(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
+
+// maxTypos — сколько опечаток допускается в слове запроса такой длины
+func maxTypos(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// termMatches сравнивает слово запроса со словом из словаря индекса: совпадение по
+// префиксу (покрывает окончания) или в пределах допустимого числа опечаток
+func termMatches(queryTerm, indexTerm string) bool {
+	if queryTerm == indexTerm {
+		return true
+	}
+	if utf8.RuneCountInString(queryTerm) >= 3 && strings.HasPrefix(indexTerm, queryTerm) {
+		return true
+	}
+	typos := maxTypos(queryTerm)
+	return typos > 0 && levenshtein([]rune(queryTerm), []rune(indexTerm), typos) <= typos
+}
+
+// levenshtein считает расстояние редактирования, прекращая счет, когда оно превысило limit
+func levenshtein(a, b []rune, limit int) int {
+	if d := len(a) - len(b); d > limit || -d > limit {
+		return limit + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// snippet вырезает фрагмент вокруг первого совпадения и выделяет совпавшие слова в <mark>.
+// Текст экранируется, как и в Postgres-реализации.
+func snippet(content string, matched map[string]bool) string {
+	tokens := tokenize(content)
+	if len(tokens) == 0 {
+		return html.EscapeString(content)
+	}
+
+	first := -1
+	for i, t := range tokens {
+		if matched[t.term] {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		first = 0
+	}
+
+	from, to := max(first-snippetRadius, 0), min(first+snippetRadius, len(tokens)-1)
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("… ")
+	}
+	pos := tokens[from].start
+	if from == 0 {
+		pos = 0
+	}
+	for _, t := range tokens[from : to+1] {
+		b.WriteString(html.EscapeString(content[pos:t.start]))
+		if matched[t.term] {
+			b.WriteString("<mark>" + html.EscapeString(content[t.start:t.end]) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(content[t.start:t.end]))
+		}
+		pos = t.end
+	}
+	if to < len(tokens)-1 {
+		b.WriteString(" …")
+	} else {
+		b.WriteString(html.EscapeString(content[pos:]))
+	}
+	return b.String()
+}
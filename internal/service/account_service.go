@@ -0,0 +1,424 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"messenger/internal/accountexport"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	accountInterval          = 2 * time.Second
+	accountExportPageSize    = 500
+	accountClaimLease        = 2 * time.Minute // аренда продлевается после каждой страницы сообщений
+	maxAccountExportAttempts = 3
+	accountExportRetention   = 7 * 24 * time.Hour
+	accountDeleteBatchSize   = 500
+
+	// DeletedAccountName — имя, под которым удаленный аккаунт остается в чатах собеседников
+	DeletedAccountName = "Удаленный аккаунт"
+)
+
+// AccountPolicy задает правила удаления аккаунтов
+type AccountPolicy struct {
+	DeletionGrace  time.Duration // за это время пользователь может отменить удаление
+	DeleteMessages bool          // удалять сообщения пользователя; иначе они остаются от удаленного аккаунта
+}
+
+// AccountService выгружает данные аккаунта по запросу пользователя и удаляет аккаунты.
+// Удаление откладывается на DeletionGrace, после чего личные данные стираются, пользователь
+// выходит из групп, а личные чаты остаются у собеседников только для чтения.
+type AccountService struct {
+	repo     *repository.AccountRepository
+	users    *repository.UserRepository
+	profiles *UserService
+	chatRepo *repository.ChatRepository
+	contacts *repository.ContactRepository
+	tokens   *repository.APITokenRepository
+	bots     *repository.BotRepository
+	folders  *repository.ChatFolderRepository
+	messages *repository.MessageRepository
+	indexer  *SearchIndexer
+	webhooks *WebhookService
+	blobs    BlobStore
+	hub      *websocket.Hub
+	policy   AccountPolicy
+}
+
+func NewAccountService(
+	repo *repository.AccountRepository,
+	users *repository.UserRepository,
+	profiles *UserService,
+	chatRepo *repository.ChatRepository,
+	contacts *repository.ContactRepository,
+	tokens *repository.APITokenRepository,
+	bots *repository.BotRepository,
+	folders *repository.ChatFolderRepository,
+	messages *repository.MessageRepository,
+	indexer *SearchIndexer,
+	webhooks *WebhookService,
+	blobs BlobStore,
+	hub *websocket.Hub,
+	policy AccountPolicy,
+) *AccountService {
+	return &AccountService{
+		repo:     repo,
+		users:    users,
+		profiles: profiles,
+		chatRepo: chatRepo,
+		contacts: contacts,
+		tokens:   tokens,
+		bots:     bots,
+		folders:  folders,
+		messages: messages,
+		indexer:  indexer,
+		webhooks: webhooks,
+		blobs:    blobs,
+		hub:      hub,
+		policy:   policy,
+	}
+}
+
+// RequestExport ставит выгрузку данных аккаунта в очередь. Если выгрузка уже идет, возвращается она.
+func (s *AccountService) RequestExport(userID uuid.UUID) (*model.AccountExport, error) {
+	e := &model.AccountExport{UserID: userID, CreatedAt: time.Now().UTC()}
+	created, err := s.repo.CreateExport(e)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		if e, err = s.repo.FindActiveExport(userID); err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, errors.New("не удалось поставить выгрузку в очередь, попробуйте еще раз")
+		}
+	}
+	return e, nil
+}
+
+// GetExport возвращает выгрузку аккаунта; чужие выгрузки не видны
+func (s *AccountService) GetExport(id, userID uuid.UUID) (*model.AccountExport, error) {
+	e, err := s.repo.GetExport(id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.UserID != userID {
+		return nil, ErrExportNotFound
+	}
+	withAccountDownloadURL(e)
+	return e, nil
+}
+
+// OpenExport открывает готовый архив выгрузки аккаунта
+func (s *AccountService) OpenExport(id, userID uuid.UUID) (io.ReadSeekCloser, *model.AccountExport, error) {
+	e, err := s.GetExport(id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Status != model.ExportDone || e.BlobKey == nil {
+		return nil, nil, errors.New("архив еще не готов")
+	}
+	archive, err := s.blobs.Open(*e.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return archive, e, nil
+}
+
+func withAccountDownloadURL(e *model.AccountExport) {
+	if e.Status == model.ExportDone {
+		e.DownloadURL = fmt.Sprintf("/api/users/me/exports/%s/download", e.ID)
+	}
+}
+
+// ScheduleDeletion назначает удаление аккаунта через DeletionGrace. Пароль запрашивается
+// повторно, чтобы аккаунт нельзя было удалить с оставленного без присмотра устройства.
+func (s *AccountService) ScheduleDeletion(userID uuid.UUID, password string) (*model.AccountDeletion, error) {
+	user, err := s.users.GetById(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.IsBot {
+		return nil, errors.New("бота удаляет его владелец")
+	}
+	if _, err := s.users.VerifyPassword(user.Email, password); err != nil {
+		return nil, errors.New("неверный пароль")
+	}
+
+	d := &model.AccountDeletion{ScheduledAt: time.Now().UTC().Add(s.policy.DeletionGrace), Messages: model.DeletedMessagesAnonymize}
+	if s.policy.DeleteMessages {
+		d.Messages = model.DeletedMessagesDelete
+	}
+	// Повторный запрос не сдвигает уже назначенное удаление
+	if user.DeletionScheduledAt != nil {
+		d.ScheduledAt = *user.DeletionScheduledAt
+		return d, nil
+	}
+	scheduled, err := s.users.ScheduleDeletion(userID, d.ScheduledAt)
+	if err != nil {
+		return nil, err
+	}
+	if !scheduled {
+		return nil, ErrUserNotFound
+	}
+	return d, nil
+}
+
+// CancelDeletion отменяет запланированное удаление аккаунта
+func (s *AccountService) CancelDeletion(userID uuid.UUID) error {
+	cancelled, err := s.users.CancelDeletion(userID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return errors.New("удаление аккаунта не запланировано или уже началось")
+	}
+	return nil
+}
+
+// Run выполняет выгрузки аккаунтов, удаляет аккаунты, срок удаления которых наступил, и
+// архивы с истекшим сроком хранения. Задачу, экземпляр сервера которой упал, после
+// истечения аренды подхватывает другой.
+func (s *AccountService) Run() {
+	ticker := time.NewTicker(accountInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.removeExpired()
+		for {
+			now := time.Now().UTC()
+			e, err := s.repo.ClaimNextExport(now, now.Add(-accountClaimLease))
+			if err != nil {
+				log.Printf("error claiming account export: %v", err)
+				break
+			}
+			if e == nil {
+				break
+			}
+			if e.Attempts > maxAccountExportAttempts {
+				s.failExport(e, errors.New("выгрузка несколько раз прерывалась"))
+				continue
+			}
+			if err := s.export(e); err != nil {
+				log.Printf("error exporting account %s (export %s): %v", e.UserID, e.ID, err)
+				s.failExport(e, err)
+			}
+		}
+		for {
+			now := time.Now().UTC()
+			userID, err := s.repo.ClaimDueDeletion(now, now.Add(-accountClaimLease))
+			if err != nil {
+				log.Printf("error claiming account deletion: %v", err)
+				break
+			}
+			if userID == uuid.Nil {
+				break
+			}
+			// Неудачное удаление повторится после истечения аренды
+			if err := s.erase(userID); err != nil {
+				log.Printf("error deleting account %s: %v", userID, err)
+				break
+			}
+		}
+	}
+}
+
+// export собирает данные аккаунта и постранично дописывает сообщения пользователя
+func (s *AccountService) export(e *model.AccountExport) error {
+	now := time.Now().UTC()
+	profile, err := s.loadProfile(e.UserID, now)
+	if err != nil {
+		return err
+	}
+	chats, err := s.chatRepo.GetMemberships(e.UserID)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "account-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	archive, err := accountexport.New(tmp, profile, chats)
+	if err != nil {
+		return err
+	}
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	for {
+		page, err := s.messages.ListBySender(e.UserID, afterTime, afterID, accountExportPageSize, now)
+		if err != nil {
+			return err
+		}
+		if err := archive.Add(page); err != nil {
+			return err
+		}
+		if err := s.repo.ExportProgress(e.ID, archive.Count(), time.Now().UTC()); err != nil {
+			return err
+		}
+		if len(page) < accountExportPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		afterTime, afterID = &last.CreatedAt, &last.ID
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("account-exports/%s/account-export.zip", e.ID)
+	if err := s.blobs.Put(key, tmp); err != nil {
+		return err
+	}
+
+	finished := time.Now().UTC()
+	expires := finished.Add(accountExportRetention)
+	if err := s.repo.CompleteExport(e.ID, key, finished, expires); err != nil {
+		return err
+	}
+	e.Status, e.Exported = model.ExportDone, archive.Count()
+	e.FinishedAt, e.ExpiresAt, e.BlobKey = &finished, &expires, &key
+	withAccountDownloadURL(e)
+	s.hub.SendToUser(e.UserID, websocket.Message{Type: "account_export_ready", Content: e})
+	return nil
+}
+
+func (s *AccountService) loadProfile(userID uuid.UUID, now time.Time) (*accountexport.Profile, error) {
+	user, err := s.users.GetById(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.profiles.prepareProfile(user, userID)
+	p := &accountexport.Profile{User: *user, ExportedAt: now}
+	if p.Privacy, err = s.users.GetPrivacy(userID); err != nil {
+		return nil, err
+	}
+	if p.Tokens, err = s.tokens.ListByUser(userID); err != nil {
+		return nil, err
+	}
+	if p.Bots, err = s.bots.ListByOwner(userID); err != nil {
+		return nil, err
+	}
+	if p.Contacts, err = s.contacts.ListContacts(userID); err != nil {
+		return nil, err
+	}
+	if p.Blocked, err = s.contacts.ListBlocked(userID); err != nil {
+		return nil, err
+	}
+	if p.Folders, err = s.folders.List(userID); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *AccountService) failExport(e *model.AccountExport, reason error) {
+	if err := s.repo.FailExport(e.ID, reason.Error(), time.Now().UTC()); err != nil {
+		log.Printf("error recording failure of account export %s: %v", e.ID, err)
+	}
+	s.hub.SendToUser(e.UserID, websocket.Message{
+		Type: "account_export_failed",
+		Content: map[string]interface{}{
+			"id":    e.ID,
+			"error": reason.Error(),
+		},
+	})
+}
+
+// erase удаляет аккаунт: по политике удаляет его сообщения, стирает личные данные и
+// сообщает собеседникам об изменениях
+func (s *AccountService) erase(userID uuid.UUID) error {
+	if s.policy.DeleteMessages {
+		for {
+			byChat, err := s.messages.DeleteBySender(userID, accountDeleteBatchSize)
+			if err != nil {
+				return err
+			}
+			if publishDeleted(s.chatRepo, s.indexer, s.webhooks, s.hub, byChat) < accountDeleteBatchSize {
+				break
+			}
+			if err := s.repo.RenewDeletion(userID, time.Now().UTC()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Собеседников нужно найти до того, как пользователь выйдет из групп
+	peers, err := s.chatRepo.GetChatPeerIDs(userID)
+	if err != nil {
+		return err
+	}
+	erased, err := s.repo.Erase(userID, DeletedAccountName, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if erased == nil {
+		return nil
+	}
+	log.Printf("account %s deleted", userID)
+
+	s.hub.DisconnectUser(userID)
+	for _, key := range erased.BlobKeys {
+		if err := s.blobs.Delete(key); err != nil {
+			log.Printf("error removing file %s of deleted account %s: %v", key, userID, err)
+		}
+	}
+
+	user, err := s.users.GetById(userID)
+	if err != nil {
+		return err
+	}
+	s.profiles.prepareProfile(user, uuid.Nil)
+	for _, peerID := range peers {
+		s.hub.SendToUser(peerID, websocket.Message{Type: "profile_updated", Content: user})
+	}
+	for chatID, left := range erased.LeftChats {
+		members, err := s.chatRepo.GetChatMembers(chatID)
+		if err != nil {
+			log.Printf("error loading members of chat %s: %v", chatID, err)
+			continue
+		}
+		s.hub.ChatMembersChanged(members)
+		for _, leftID := range left {
+			event := map[string]interface{}{
+				"chat_id": chatID,
+				"user_id": leftID,
+			}
+			for _, memberID := range members {
+				s.hub.SendToUser(memberID, websocket.Message{Type: "member_left", Content: event})
+			}
+			s.webhooks.Publish(chatID, model.EventMemberLeft, event)
+		}
+	}
+	s.hub.ChatMembersChanged(peers)
+	return nil
+}
+
+func (s *AccountService) removeExpired() {
+	keys, err := s.repo.DeleteExpiredExports(time.Now().UTC())
+	if err != nil {
+		log.Printf("error removing expired account exports: %v", err)
+		return
+	}
+	for _, key := range keys {
+		if err := s.blobs.Delete(key); err != nil {
+			log.Printf("error removing account export archive %s: %v", key, err)
+		}
+	}
+}
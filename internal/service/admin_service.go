@@ -0,0 +1,281 @@
+package service
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	adminDefaultPageSize = 50
+	adminMaxPageSize     = 200
+	maxSuspendReasonLen  = 500
+)
+
+var (
+	ErrInsufficientRole = errors.New("недостаточно прав")
+	ErrChatNotFound     = errors.New("чат не найден")
+)
+
+// AdminService — действия администрации: поиск и блокировка пользователей, сброс паролей,
+// назначение ролей и просмотр сведений о чатах и сервере. Модератор управляет только
+// обычными пользователями, администратор — еще и модераторами; над собой действия запрещены.
+type AdminService struct {
+	repo      *repository.AdminRepository
+	users     *repository.UserRepository
+	profiles  *UserService
+	chatRepo  *repository.ChatRepository
+	messages  *repository.MessageRepository
+	hub       *websocket.Hub
+	startedAt time.Time
+}
+
+func NewAdminService(
+	repo *repository.AdminRepository,
+	users *repository.UserRepository,
+	profiles *UserService,
+	chatRepo *repository.ChatRepository,
+	messages *repository.MessageRepository,
+	hub *websocket.Hub,
+) *AdminService {
+	return &AdminService{
+		repo:      repo,
+		users:     users,
+		profiles:  profiles,
+		chatRepo:  chatRepo,
+		messages:  messages,
+		hub:       hub,
+		startedAt: time.Now().UTC(),
+	}
+}
+
+func (s *AdminService) ListUsers(f model.UserListFilter) (*model.UserList, error) {
+	if f.Role != "" && !f.Role.IsValid() {
+		return nil, fmt.Errorf("неизвестная роль: %s", f.Role)
+	}
+	switch f.State {
+	case "", model.AccountActive, model.AccountSuspended, model.AccountDeleted:
+	default:
+		return nil, fmt.Errorf("неизвестное состояние аккаунта: %s", f.State)
+	}
+	if f.Limit <= 0 || f.Limit > adminMaxPageSize {
+		f.Limit = adminDefaultPageSize
+	}
+	if f.Offset < 0 {
+		f.Offset = 0
+	}
+	f.Query = strings.TrimSpace(f.Query)
+
+	list, err := s.repo.ListUsers(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range list.Users {
+		s.prepareUser(&list.Users[i])
+	}
+	return list, nil
+}
+
+func (s *AdminService) GetUser(userID uuid.UUID) (*model.User, error) {
+	user, err := s.getUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.prepareUser(user)
+	return user, nil
+}
+
+// GetUserChats возвращает чаты пользователя без их содержимого
+func (s *AdminService) GetUserChats(userID uuid.UUID) ([]model.Membership, error) {
+	if _, err := s.getUser(userID); err != nil {
+		return nil, err
+	}
+	chats, err := s.chatRepo.GetMemberships(userID)
+	if err != nil {
+		return nil, err
+	}
+	if chats == nil {
+		chats = []model.Membership{}
+	}
+	return chats, nil
+}
+
+// Suspend блокирует аккаунт: вход и все запросы отклоняются, подключение по WebSocket закрывается
+func (s *AdminService) Suspend(actorID, userID uuid.UUID, reason string) (*model.User, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxSuspendReasonLen {
+		return nil, fmt.Errorf("причина блокировки не должна быть длиннее %d символов", maxSuspendReasonLen)
+	}
+	if _, err := s.checkTarget(actorID, userID); err != nil {
+		return nil, err
+	}
+
+	var r *string
+	if reason != "" {
+		r = &reason
+	}
+	ok, err := s.users.Suspend(userID, actorID, r, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("аккаунт уже заблокирован")
+	}
+	s.hub.DisconnectUser(userID)
+	log.Printf("admin: %s suspended user %s", actorID, userID)
+	return s.GetUser(userID)
+}
+
+func (s *AdminService) Unsuspend(actorID, userID uuid.UUID) (*model.User, error) {
+	if _, err := s.checkTarget(actorID, userID); err != nil {
+		return nil, err
+	}
+	ok, err := s.users.Unsuspend(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("аккаунт не заблокирован")
+	}
+	log.Printf("admin: %s unsuspended user %s", actorID, userID)
+	return s.GetUser(userID)
+}
+
+// ResetPassword задает пользователю случайный временный пароль и отзывает его сессии и токены API.
+// Пароль возвращается один раз, его нужно передать пользователю.
+func (s *AdminService) ResetPassword(actorID, userID uuid.UUID) (string, error) {
+	actor, err := s.checkTarget(actorID, userID)
+	if err != nil {
+		return "", err
+	}
+	if !actor.AtLeast(model.UserRoleAdmin) {
+		return "", ErrInsufficientRole
+	}
+
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	password := base64.RawURLEncoding.EncodeToString(raw)
+	hashed, err := hash(password)
+	if err != nil {
+		return "", err
+	}
+	if err := s.users.SetPassword(userID, hashed, time.Now().UTC()); err != nil {
+		return "", err
+	}
+	s.hub.DisconnectUser(userID)
+	log.Printf("admin: %s reset password of user %s", actorID, userID)
+	return password, nil
+}
+
+// SetRole меняет глобальную роль. Назначать можно только роли ниже своей.
+func (s *AdminService) SetRole(actorID, userID uuid.UUID, role model.UserRole) (*model.User, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("неизвестная роль: %s", role)
+	}
+	actor, err := s.checkTarget(actorID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.AtLeast(model.UserRoleAdmin) || !actor.Outranks(role) {
+		return nil, ErrInsufficientRole
+	}
+	if err := s.users.SetRole(userID, role); err != nil {
+		return nil, err
+	}
+	log.Printf("admin: %s set role of user %s to %s", actorID, userID, role)
+	return s.GetUser(userID)
+}
+
+// GetChat возвращает сведения о чате и его участниках. Сообщения администрации не показываются.
+func (s *AdminService) GetChat(chatID uuid.UUID) (*model.ChatOverview, error) {
+	chat, err := s.chatRepo.GetByID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, ErrChatNotFound
+	}
+
+	overview := &model.ChatOverview{Chat: *chat}
+	if overview.Members, err = s.chatRepo.GetMemberProfiles(chatID); err != nil {
+		return nil, err
+	}
+	if overview.Messages, err = s.messages.CountChatMessages(chatID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	if overview.LastMessageAt, err = s.repo.LastMessageAt(chatID); err != nil {
+		return nil, err
+	}
+	return overview, nil
+}
+
+func (s *AdminService) Stats() (*model.ServerStats, error) {
+	now := time.Now().UTC()
+	stats, err := s.repo.Stats(now)
+	if err != nil {
+		return nil, err
+	}
+	if stats.Messages, err = s.messages.CountMessages(); err != nil {
+		return nil, err
+	}
+	stats.Online, stats.Away = s.hub.OnlineStats()
+	stats.StartedAt = s.startedAt
+	stats.GeneratedAt = now
+	return stats, nil
+}
+
+// checkTarget проверяет, что действующий пользователь может управлять аккаунтом userID,
+// и возвращает роль действующего пользователя
+func (s *AdminService) checkTarget(actorID, userID uuid.UUID) (model.UserRole, error) {
+	if actorID == userID {
+		return "", errors.New("нельзя выполнить это действие над своим аккаунтом")
+	}
+	actor, err := s.users.GetAccess(actorID)
+	if err != nil {
+		return "", err
+	}
+	target, err := s.getUser(userID)
+	if err != nil {
+		return "", err
+	}
+	if target.IsDeleted {
+		return "", ErrUserNotFound
+	}
+	if !actor.Role.Outranks(target.Role) {
+		return "", ErrInsufficientRole
+	}
+	return actor.Role, nil
+}
+
+func (s *AdminService) getUser(userID uuid.UUID) (*model.User, error) {
+	user, err := s.users.GetById(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// prepareUser показывает профиль так, как его видит владелец, а также текущий статус
+// подключения и последний визит без учета настроек приватности
+func (s *AdminService) prepareUser(user *model.User) {
+	lastSeen := user.LastSeenAt
+	s.profiles.prepareProfile(user, user.ID)
+	user.Status = s.hub.UserStatus(user.ID)
+	user.LastSeenAt = lastSeen
+}
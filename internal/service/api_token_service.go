@@ -0,0 +1,124 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APITokenPrefix отличает персональные токены от JWT в заголовке Authorization
+const APITokenPrefix = "msg_pat_"
+
+type APITokenService struct {
+	repo *repository.APITokenRepository
+}
+
+func NewAPITokenService(repo *repository.APITokenRepository) *APITokenService {
+	return &APITokenService{repo: repo}
+}
+
+// CreateToken выпускает токен и возвращает его открытое значение — оно показывается только один раз
+func (s *APITokenService) CreateToken(userID uuid.UUID, name string, scopes []model.Scope, expiresAt *time.Time) (*model.APIToken, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", errors.New("название токена должно содержать от 1 до 100 символов")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("нужно указать хотя бы одну область доступа")
+	}
+	for _, scope := range scopes {
+		if !isKnownScope(scope) {
+			return nil, "", fmt.Errorf("неизвестная область доступа: %s", scope)
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return nil, "", errors.New("срок действия токена должен быть в будущем")
+	}
+
+	plaintext, err := newSecretToken(APITokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token := &model.APIToken{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plaintext[:len(APITokenPrefix)+4],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.Create(token, hashToken(plaintext)); err != nil {
+		return nil, "", err
+	}
+	return token, plaintext, nil
+}
+
+func (s *APITokenService) ListTokens(userID uuid.UUID) ([]model.APIToken, error) {
+	return s.repo.ListByUser(userID)
+}
+
+func (s *APITokenService) RevokeToken(id, userID uuid.UUID) error {
+	ok, err := s.repo.Revoke(id, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("токен не найден или уже отозван")
+	}
+	return nil
+}
+
+// Authenticate проверяет открытое значение токена и отмечает его использование
+func (s *APITokenService) Authenticate(plaintext string) (*model.APIToken, error) {
+	token, err := s.repo.GetByHash(hashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, errors.New("токен не найден")
+	}
+	if token.RevokedAt != nil {
+		return nil, errors.New("токен отозван")
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("срок действия токена истек")
+	}
+
+	if err := s.repo.TouchLastUsed(token.ID); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// newSecretToken генерирует случайный токен с префиксом, по которому видно его назначение
+func newSecretToken(prefix string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken — в базе хранятся только SHA-256 от секретных токенов
+func hashToken(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func isKnownScope(scope model.Scope) bool {
+	for _, s := range model.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/netguard"
+	"messenger/internal/repository"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BotTokenPrefix = "msg_bot_"
+
+	maxUpdatesLimit   = 100
+	maxPollingTimeout = 50 * time.Second
+	webhookTimeout    = 10 * time.Second
+)
+
+var botCommandRegex = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+type BotService struct {
+	repo     *repository.BotRepository
+	userRepo *repository.UserRepository
+	chatRepo *repository.ChatRepository
+	client   *http.Client // только публичные адреса: вебхук бота задает его владелец
+
+	// Каналы ожидания long polling: закрываются, когда у бота появляется обновление
+	mu      sync.Mutex
+	waiters map[uuid.UUID]chan struct{}
+}
+
+func NewBotService(repo *repository.BotRepository, userRepo *repository.UserRepository, chatRepo *repository.ChatRepository) *BotService {
+	return &BotService{
+		repo:     repo,
+		userRepo: userRepo,
+		chatRepo: chatRepo,
+		client:   netguard.NewClient(netguard.Config{Timeout: webhookTimeout}),
+		waiters:  make(map[uuid.UUID]chan struct{}),
+	}
+}
+
+// CreateBot заводит бота от имени владельца и возвращает его токен — он показывается только один раз
+func (s *BotService) CreateBot(ownerID uuid.UUID, username string) (*model.Bot, string, error) {
+	if len(username) < 3 || len(username) > 50 {
+		return nil, "", errors.New("имя бота должно содержать от 3 до 50 символов")
+	}
+
+	owner, err := s.userRepo.GetById(ownerID)
+	if err != nil {
+		return nil, "", err
+	}
+	if owner.IsBot {
+		return nil, "", errors.New("бот не может создавать других ботов")
+	}
+
+	existingUser, _ := s.userRepo.GetByUsername(username)
+	if existingUser != nil {
+		return nil, "", errors.New("пользователь с таким именем пользователя уже существует")
+	}
+
+	token, err := newSecretToken(BotTokenPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	bot := &model.Bot{OwnerID: ownerID, Username: username}
+	// email обязателен для users, поэтому боту выдается служебный адрес
+	email := username + "@bots.messenger.local"
+	if err := s.repo.Create(bot, email, hashToken(token)); err != nil {
+		return nil, "", err
+	}
+	return bot, token, nil
+}
+
+func (s *BotService) ListBots(ownerID uuid.UUID) ([]model.Bot, error) {
+	return s.repo.ListByOwner(ownerID)
+}
+
+// RegenerateToken выпускает новый токен; старый перестает работать сразу
+func (s *BotService) RegenerateToken(botID, ownerID uuid.UUID) (string, error) {
+	if _, err := s.getOwnedBot(botID, ownerID); err != nil {
+		return "", err
+	}
+
+	token, err := newSecretToken(BotTokenPrefix)
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.UpdateToken(botID, hashToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *BotService) DeleteBot(botID, ownerID uuid.UUID) error {
+	if _, err := s.getOwnedBot(botID, ownerID); err != nil {
+		return err
+	}
+	return s.repo.Delete(botID)
+}
+
+func (s *BotService) Authenticate(token string) (*model.Bot, error) {
+	bot, err := s.repo.GetByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if bot == nil {
+		return nil, errors.New("неверный токен бота")
+	}
+	return bot, nil
+}
+
+func (s *BotService) SetCommands(botID uuid.UUID, commands []model.BotCommand) error {
+	seen := make(map[string]bool)
+	for i := range commands {
+		commands[i].Command = strings.TrimPrefix(strings.ToLower(commands[i].Command), "/")
+		if !botCommandRegex.MatchString(commands[i].Command) {
+			return fmt.Errorf("некорректная команда: %s", commands[i].Command)
+		}
+		if len(commands[i].Description) > 256 {
+			return fmt.Errorf("описание команды %s длиннее 256 символов", commands[i].Command)
+		}
+		if seen[commands[i].Command] {
+			return fmt.Errorf("команда %s указана дважды", commands[i].Command)
+		}
+		seen[commands[i].Command] = true
+	}
+	return s.repo.SetCommands(botID, commands)
+}
+
+func (s *BotService) GetCommands(botID uuid.UUID) ([]model.BotCommand, error) {
+	return s.repo.GetCommands(botID)
+}
+
+// SetWebhook включает доставку обновлений POST-запросами на url; пустой url отключает вебхук
+func (s *BotService) SetWebhook(botID uuid.UUID, webhookURL, secret string) error {
+	if webhookURL == "" {
+		return s.repo.SetWebhook(botID, nil, nil)
+	}
+
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return err
+	}
+
+	var secretPtr *string
+	if secret != "" {
+		secretPtr = &secret
+	}
+	return s.repo.SetWebhook(botID, &webhookURL, secretPtr)
+}
+
+// GetUpdates возвращает обновления начиная с offset, подтверждая все предыдущие.
+// Если обновлений нет, ждет их до timeout (long polling).
+func (s *BotService) GetUpdates(ctx context.Context, botID uuid.UUID, offset int64, limit int, timeout time.Duration) ([]model.BotUpdate, error) {
+	if limit <= 0 || limit > maxUpdatesLimit {
+		limit = maxUpdatesLimit
+	}
+	if timeout > maxPollingTimeout {
+		timeout = maxPollingTimeout
+	}
+
+	// Подписываемся до запроса, чтобы не пропустить обновление между запросом и ожиданием
+	wait := s.subscribe(botID)
+	updates, err := s.repo.GetUpdates(botID, offset, limit)
+	if err != nil || len(updates) > 0 || timeout <= 0 {
+		return updates, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-wait:
+	case <-timer.C:
+		return updates, nil
+	case <-ctx.Done():
+		return updates, nil
+	}
+	return s.repo.GetUpdates(botID, offset, limit)
+}
+
+// DispatchMessage передает ботам новое сообщение: слэш-команды — ботам чата,
+// которые их зарегистрировали, остальные сообщения — только ботам в личных чатах.
+// Команда, которую никто не зарегистрировал, доходит до бота личного чата как обычное сообщение.
+func (s *BotService) DispatchMessage(message *model.Message) error {
+	if command, botUsername, args, ok := parseCommand(message.Content); ok {
+		botIDs, err := s.repo.FindCommandBots(message.ChatID, command, botUsername)
+		if err != nil {
+			return err
+		}
+		dispatched := false
+		for _, botID := range botIDs {
+			if botID == message.SenderID {
+				continue
+			}
+			s.enqueue(botID, &model.BotUpdate{Type: model.BotUpdateCommand, Message: message, Command: command, Args: args})
+			dispatched = true
+		}
+		if dispatched {
+			return nil
+		}
+	}
+
+	botIDs, err := s.repo.GetChatBots(message.ChatID)
+	if err != nil || len(botIDs) == 0 {
+		return err
+	}
+	chat, err := s.chatRepo.GetByID(message.ChatID)
+	if err != nil || chat == nil || chat.Type != model.TypePrivate {
+		return err
+	}
+	for _, botID := range botIDs {
+		if botID != message.SenderID {
+			s.enqueue(botID, &model.BotUpdate{Type: model.BotUpdateMessage, Message: message})
+		}
+	}
+	return nil
+}
+
+func (s *BotService) enqueue(botID uuid.UUID, update *model.BotUpdate) {
+	if err := s.repo.AddUpdate(botID, update); err != nil {
+		log.Printf("error queuing update for bot %s: %v", botID, err)
+		return
+	}
+	s.notify(botID)
+	go s.deliverWebhook(botID, update)
+}
+
+// deliverWebhook отправляет обновление на вебхук бота. При успехе обновление
+// удаляется из очереди, иначе остается доступным через getUpdates.
+func (s *BotService) deliverWebhook(botID uuid.UUID, update *model.BotUpdate) {
+	webhookURL, secret, err := s.repo.GetWebhook(botID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("error loading webhook for bot %s: %v", botID, err)
+		}
+		return
+	}
+	if webhookURL == nil {
+		return
+	}
+
+	body, err := json.Marshal(update)
+	if err != nil {
+		log.Printf("error marshaling bot update: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("error creating webhook request for bot %s: %v", botID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != nil {
+		req.Header.Set("X-Bot-Api-Secret-Token", *secret)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		log.Printf("error delivering webhook for bot %s: %v", botID, err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("webhook for bot %s responded with %d", botID, resp.StatusCode)
+		return
+	}
+	if err := s.repo.DeleteUpdate(update.ID); err != nil {
+		log.Printf("error deleting delivered update %d: %v", update.ID, err)
+	}
+}
+
+func (s *BotService) subscribe(botID uuid.UUID) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.waiters[botID]
+	if !ok {
+		ch = make(chan struct{})
+		s.waiters[botID] = ch
+	}
+	return ch
+}
+
+func (s *BotService) notify(botID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ch, ok := s.waiters[botID]; ok {
+		close(ch)
+		delete(s.waiters, botID)
+	}
+}
+
+func (s *BotService) getOwnedBot(botID, ownerID uuid.UUID) (*model.Bot, error) {
+	bot, err := s.repo.GetByID(botID)
+	if err != nil {
+		return nil, err
+	}
+	if bot == nil || bot.OwnerID != ownerID {
+		return nil, errors.New("бот не найден")
+	}
+	return bot, nil
+}
+
+// parseCommand разбирает сообщения вида "/command@botname аргументы"
+func parseCommand(content string) (command, botUsername, args string, ok bool) {
+	if !strings.HasPrefix(content, "/") {
+		return "", "", "", false
+	}
+
+	head, rest := strings.TrimPrefix(content, "/"), ""
+	if i := strings.IndexAny(head, " \t\n"); i >= 0 {
+		head, rest = head[:i], head[i+1:]
+	}
+	command, botUsername, _ = strings.Cut(head, "@")
+	command = strings.ToLower(command)
+	if !botCommandRegex.MatchString(command) {
+		return "", "", "", false
+	}
+	return command, botUsername, strings.TrimSpace(rest), true
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"messenger/internal/chatexport"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	exportInterval         = 2 * time.Second
+	exportPageSize         = 500
+	exportClaimLease       = 2 * time.Minute // аренда продлевается после каждой страницы
+	maxExportAttempts      = 3
+	exportRetention        = 7 * 24 * time.Hour
+	exportProgressInterval = time.Second // не чаще отправляем chat_export_progress
+)
+
+var ErrExportNotFound = errors.New("выгрузка не найдена")
+
+type ChatExportService struct {
+	repo     *repository.ChatExportRepository
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	blobs    BlobStore
+	hub      *websocket.Hub
+}
+
+func NewChatExportService(repo *repository.ChatExportRepository, messages *repository.MessageRepository, chatRepo *repository.ChatRepository, blobs BlobStore, hub *websocket.Hub) *ChatExportService {
+	return &ChatExportService{
+		repo:     repo,
+		messages: messages,
+		chatRepo: chatRepo,
+		blobs:    blobs,
+		hub:      hub,
+	}
+}
+
+// RequestExport ставит выгрузку чата в очередь. Если выгрузка этого чата уже идет, возвращается она.
+func (s *ChatExportService) RequestExport(chatID, userID uuid.UUID) (*model.ChatExport, error) {
+	isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+
+	e := &model.ChatExport{ChatID: chatID, RequestedBy: userID, CreatedAt: time.Now().UTC()}
+	created, err := s.repo.Create(e)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		if e, err = s.repo.FindActive(chatID, userID); err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, errors.New("не удалось поставить выгрузку в очередь, попробуйте еще раз")
+		}
+	}
+	return e, nil
+}
+
+// GetExport возвращает выгрузку; чужие выгрузки не видны
+func (s *ChatExportService) GetExport(id, userID uuid.UUID) (*model.ChatExport, error) {
+	e, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.RequestedBy != userID {
+		return nil, ErrExportNotFound
+	}
+	withDownloadURL(e)
+	return e, nil
+}
+
+// OpenArchive открывает готовый архив выгрузки
+func (s *ChatExportService) OpenArchive(id, userID uuid.UUID) (io.ReadSeekCloser, *model.ChatExport, error) {
+	e, err := s.GetExport(id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Status != model.ExportDone || e.BlobKey == nil {
+		return nil, nil, errors.New("архив еще не готов")
+	}
+	archive, err := s.blobs.Open(*e.BlobKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return archive, e, nil
+}
+
+func withDownloadURL(e *model.ChatExport) {
+	if e.Status == model.ExportDone {
+		e.DownloadURL = fmt.Sprintf("/api/exports/%s/download", e.ID)
+	}
+}
+
+// Run выполняет выгрузки из очереди по одной и удаляет архивы с истекшим сроком хранения.
+// Выгрузку, экземпляр сервера которой упал, после истечения аренды начинает заново другой.
+func (s *ChatExportService) Run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.removeExpired()
+		for {
+			now := time.Now().UTC()
+			e, err := s.repo.ClaimNext(now, now.Add(-exportClaimLease))
+			if err != nil {
+				log.Printf("error claiming chat export: %v", err)
+				break
+			}
+			if e == nil {
+				break
+			}
+			if e.Attempts > maxExportAttempts {
+				s.fail(e, errors.New("выгрузка несколько раз прерывалась"))
+				continue
+			}
+			if err := s.export(e); err != nil {
+				log.Printf("error exporting chat %s (export %s): %v", e.ChatID, e.ID, err)
+				s.fail(e, err)
+			}
+		}
+	}
+}
+
+// export постранично пишет историю чата в архив и сохраняет его в хранилище файлов
+func (s *ChatExportService) export(e *model.ChatExport) error {
+	chat, err := s.chatRepo.GetByID(e.ChatID)
+	if err != nil {
+		return err
+	}
+	if chat == nil {
+		return errors.New("чат удален")
+	}
+	// Пока выгрузка ждала очереди, пользователь мог покинуть чат
+	isMember, err := s.chatRepo.IsChatMember(e.ChatID, e.RequestedBy)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errors.New("вы больше не участник этого чата")
+	}
+	members, err := s.chatRepo.GetMemberProfiles(e.ChatID)
+	if err != nil {
+		return err
+	}
+	if chat.Name == "" {
+		chat.Name = privateChatTitle(members)
+	}
+
+	now := time.Now().UTC()
+	total, err := s.messages.CountChatMessages(e.ChatID, now)
+	if err != nil {
+		return err
+	}
+	archive, err := chatexport.New(chatexport.Header{Chat: *chat, Members: members, ExportedAt: now})
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	var notified time.Time
+	for {
+		page, err := s.messages.ListForExport(e.ChatID, e.RequestedBy, afterTime, afterID, exportPageSize, now)
+		if err != nil {
+			return err
+		}
+		if err := archive.Add(page); err != nil {
+			return err
+		}
+		// Сообщения, пришедшие во время выгрузки, тоже попадают в архив
+		total = max(total, archive.Count())
+		if err := s.repo.Progress(e.ID, archive.Count(), total, time.Now().UTC()); err != nil {
+			return err
+		}
+		if time.Since(notified) >= exportProgressInterval {
+			notified = time.Now()
+			s.notifyProgress(e, archive.Count(), total)
+		}
+		if len(page) < exportPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		afterTime, afterID = &last.CreatedAt, &last.ID
+	}
+
+	tmp, err := os.CreateTemp("", "chat-export-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if err := archive.WriteZip(tmp); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("exports/%s/chat-export.zip", e.ID)
+	if err := s.blobs.Put(key, tmp); err != nil {
+		return err
+	}
+
+	finished := time.Now().UTC()
+	expires := finished.Add(exportRetention)
+	if err := s.repo.Complete(e.ID, key, finished, expires); err != nil {
+		return err
+	}
+	e.Status, e.Exported, e.Total = model.ExportDone, archive.Count(), total
+	e.FinishedAt, e.ExpiresAt, e.BlobKey = &finished, &expires, &key
+	withDownloadURL(e)
+	s.hub.SendToUser(e.RequestedBy, websocket.Message{Type: "chat_export_ready", Content: e})
+	return nil
+}
+
+// privateChatTitle называет личный чат по его участникам
+func privateChatTitle(members []model.MemberProfile) string {
+	names := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.DisplayName != nil && *m.DisplayName != "" {
+			names = append(names, *m.DisplayName)
+		} else {
+			names = append(names, m.Username)
+		}
+	}
+	return strings.Join(names, " и ")
+}
+
+func (s *ChatExportService) notifyProgress(e *model.ChatExport, exported, total int) {
+	s.hub.SendToUser(e.RequestedBy, websocket.Message{
+		Type: "chat_export_progress",
+		Content: map[string]interface{}{
+			"id":       e.ID,
+			"chat_id":  e.ChatID,
+			"exported": exported,
+			"total":    total,
+		},
+	})
+}
+
+func (s *ChatExportService) fail(e *model.ChatExport, reason error) {
+	if err := s.repo.Fail(e.ID, reason.Error(), time.Now().UTC()); err != nil {
+		log.Printf("error recording failure of chat export %s: %v", e.ID, err)
+	}
+	s.hub.SendToUser(e.RequestedBy, websocket.Message{
+		Type: "chat_export_failed",
+		Content: map[string]interface{}{
+			"id":      e.ID,
+			"chat_id": e.ChatID,
+			"error":   reason.Error(),
+		},
+	})
+}
+
+func (s *ChatExportService) removeExpired() {
+	keys, err := s.repo.DeleteExpired(time.Now().UTC())
+	if err != nil {
+		log.Printf("error removing expired chat exports: %v", err)
+		return
+	}
+	for _, key := range keys {
+		if err := s.blobs.Delete(key); err != nil {
+			log.Printf("error removing chat export archive %s: %v", key, err)
+		}
+	}
+}
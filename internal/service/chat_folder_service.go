@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxChatFolders       = 10
+	maxFolderNameLength  = 32
+	maxFolderChatsInRule = 200
+)
+
+var ErrChatFolderNotFound = errors.New("папка не найдена")
+
+type ChatFolderService struct {
+	repo *repository.ChatFolderRepository
+}
+
+func NewChatFolderService(repo *repository.ChatFolderRepository) *ChatFolderService {
+	return &ChatFolderService{repo: repo}
+}
+
+func (s *ChatFolderService) CreateFolder(userID uuid.UUID, f *model.ChatFolder) error {
+	if err := validateChatFolder(f); err != nil {
+		return err
+	}
+	n, err := s.repo.Count(userID)
+	if err != nil {
+		return err
+	}
+	if n >= maxChatFolders {
+		return fmt.Errorf("можно создать не более %d папок", maxChatFolders)
+	}
+	return s.repo.Create(userID, f)
+}
+
+func (s *ChatFolderService) UpdateFolder(userID uuid.UUID, f *model.ChatFolder) error {
+	if err := validateChatFolder(f); err != nil {
+		return err
+	}
+	found, err := s.repo.Update(userID, f)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrChatFolderNotFound
+	}
+	return nil
+}
+
+func (s *ChatFolderService) DeleteFolder(userID, folderID uuid.UUID) error {
+	found, err := s.repo.Delete(userID, folderID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrChatFolderNotFound
+	}
+	return nil
+}
+
+func (s *ChatFolderService) ListFolders(userID uuid.UUID) ([]model.ChatFolder, error) {
+	return s.repo.List(userID)
+}
+
+func validateChatFolder(f *model.ChatFolder) error {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || utf8.RuneCountInString(f.Name) > maxFolderNameLength {
+		return fmt.Errorf("название папки должно содержать от 1 до %d символов", maxFolderNameLength)
+	}
+	if len(f.IncludeChatIDs) == 0 && len(f.IncludeTypes) == 0 {
+		return errors.New("в папку должны входить выбранные чаты или типы чатов")
+	}
+	if len(f.IncludeChatIDs) > maxFolderChatsInRule || len(f.ExcludeChatIDs) > maxFolderChatsInRule {
+		return fmt.Errorf("в правиле папки может быть не более %d чатов", maxFolderChatsInRule)
+	}
+	for _, t := range f.IncludeTypes {
+		if t != model.TypePrivate && t != model.TypeGroup {
+			return fmt.Errorf("неизвестный тип чата: %s", t)
+		}
+	}
+	if f.IncludeChatIDs == nil {
+		f.IncludeChatIDs = []uuid.UUID{}
+	}
+	if f.ExcludeChatIDs == nil {
+		f.ExcludeChatIDs = []uuid.UUID{}
+	}
+	if f.IncludeTypes == nil {
+		f.IncludeTypes = []model.TypeChat{}
+	}
+	if f.ExcludeArchived == nil {
+		excludeArchived := true
+		f.ExcludeArchived = &excludeArchived
+	}
+	return nil
+}
@@ -0,0 +1,406 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"messenger/internal/chatimport"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxImportSize          = 100 << 20
+	maxImportNameLength    = 100
+	maxImportUserMap       = 1000
+	maxImportReportItems   = 1000
+	importInterval         = 2 * time.Second
+	importBatchSize        = 200
+	importClaimLease       = 2 * time.Minute // аренда продлевается после каждой пачки
+	maxImportAttempts      = 3
+	importProgressInterval = time.Second
+)
+
+var (
+	ErrImportNotFound      = errors.New("импорт не найден")
+	ErrImportClaimNotFound = errors.New("предложение не найдено")
+)
+
+// permanentError — ошибка, после которой повторять импорт бесполезно (например, битая выгрузка)
+type permanentError struct{ error }
+
+type ChatImportService struct {
+	repo     *repository.ChatImportRepository
+	users    *repository.UserRepository
+	contacts *ContactService
+	blobs    BlobStore
+	indexer  *SearchIndexer
+	hub      *websocket.Hub
+}
+
+func NewChatImportService(repo *repository.ChatImportRepository, users *repository.UserRepository, contacts *ContactService, blobs BlobStore, indexer *SearchIndexer, hub *websocket.Hub) *ChatImportService {
+	return &ChatImportService{
+		repo:     repo,
+		users:    users,
+		contacts: contacts,
+		blobs:    blobs,
+		indexer:  indexer,
+		hub:      hub,
+	}
+}
+
+// StartImport сохраняет загруженную выгрузку и ставит импорт в очередь. Чат создается,
+// когда импорт начнет выполняться; о прогрессе клиент узнает по WebSocket.
+func (s *ChatImportService) StartImport(i *model.ChatImport, upload io.Reader) error {
+	if !chatimport.Source(i.Source).IsValid() {
+		return errors.New("источник импорта должен быть telegram или slack")
+	}
+	i.Name = strings.TrimSpace(i.Name)
+	if utf8.RuneCountInString(i.Name) > maxImportNameLength {
+		return fmt.Errorf("название чата не должно быть длиннее %d символов", maxImportNameLength)
+	}
+	if len(i.UserMap) > maxImportUserMap {
+		return fmt.Errorf("можно сопоставить не более %d авторов", maxImportUserMap)
+	}
+
+	i.ID = uuid.New()
+	i.BlobKey = fmt.Sprintf("imports/%s/source", i.ID)
+	i.CreatedAt = time.Now().UTC()
+	if err := s.blobs.Put(i.BlobKey, io.LimitReader(upload, MaxImportSize)); err != nil {
+		return err
+	}
+	if err := s.repo.Create(i); err != nil {
+		if err := s.blobs.Delete(i.BlobKey); err != nil {
+			log.Printf("error removing upload of import %s: %v", i.ID, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetImport возвращает импорт с отчетом; чужие импорты не видны
+func (s *ChatImportService) GetImport(id, userID uuid.UUID) (*model.ChatImport, error) {
+	i, err := s.repo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if i == nil || i.RequestedBy != userID {
+		return nil, ErrImportNotFound
+	}
+	return i, nil
+}
+
+// Run выполняет импорты из очереди по одному. Импорт, экземпляр сервера которого упал или
+// получил временную ошибку, после истечения аренды продолжается с последней записанной пачки.
+func (s *ChatImportService) Run() {
+	ticker := time.NewTicker(importInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for {
+			now := time.Now().UTC()
+			i, err := s.repo.ClaimNext(now, now.Add(-importClaimLease))
+			if err != nil {
+				log.Printf("error claiming chat import: %v", err)
+				break
+			}
+			if i == nil {
+				break
+			}
+			if i.Attempts > maxImportAttempts {
+				s.fail(i, errors.New("импорт несколько раз прерывался"))
+				continue
+			}
+			if err := s.process(i); err != nil {
+				log.Printf("error importing chat (import %s, attempt %d): %v", i.ID, i.Attempts, err)
+				var permanent permanentError
+				if errors.As(err, &permanent) || i.Attempts >= maxImportAttempts {
+					s.fail(i, err)
+				}
+			}
+		}
+	}
+}
+
+func (s *ChatImportService) process(i *model.ChatImport) error {
+	source, err := s.blobs.Open(i.BlobKey)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(source, MaxImportSize))
+	source.Close()
+	if err != nil {
+		return err
+	}
+	export, err := chatimport.Parse(chatimport.Source(i.Source), data, i.Channel)
+	if err != nil {
+		return permanentError{err}
+	}
+	i.Total = len(export.Messages)
+
+	authors, err := s.resolveAuthors(i, export.Users)
+	if err != nil {
+		return err
+	}
+	if i.ChatID == nil {
+		if err := s.createChat(i, export); err != nil {
+			return err
+		}
+	}
+
+	imported, err := s.repo.GetMessages(i.ID)
+	if err != nil {
+		return err
+	}
+	reported := len(i.Report)
+	var notified time.Time
+	for i.Processed < len(export.Messages) {
+		end := min(i.Processed+importBatchSize, len(export.Messages))
+		var batch []model.ImportedMessage
+		var report []model.ImportReportItem
+		note := func(item, reason string) {
+			if reported < maxImportReportItems {
+				report = append(report, model.ImportReportItem{Item: item, Reason: reason})
+				reported++
+			}
+		}
+
+		for _, m := range export.Messages[i.Processed:end] {
+			if m.Skip == "" {
+				if _, dup := imported[m.ID]; dup {
+					m.Skip = "сообщение повторяется в выгрузке"
+				}
+			}
+			if m.Skip != "" {
+				i.Skipped++
+				note(m.ID, m.Skip)
+				continue
+			}
+			if m.Lost != "" {
+				note(m.ID, m.Lost)
+			}
+
+			// ID выдается заранее, чтобы на сообщение можно было ответить в той же пачке
+			id := uuid.New()
+			var replyTo *uuid.UUID
+			if target, ok := imported[m.ReplyTo]; ok && m.ReplyTo != "" {
+				replyTo = &target
+			}
+			imported[m.ID] = id
+			batch = append(batch, model.ImportedMessage{
+				ID:        id,
+				ForeignID: m.ID,
+				SenderID:  authors[m.AuthorID].UserID,
+				Content:   m.Text,
+				Entities:  m.Entities,
+				ReplyTo:   replyTo,
+				CreatedAt: m.SentAt,
+			})
+		}
+
+		i.Processed, i.Imported = end, i.Imported+len(batch)
+		if err := s.repo.SaveBatch(i, batch, report, time.Now().UTC()); err != nil {
+			return err
+		}
+		i.Report = append(i.Report, report...)
+		for _, m := range batch {
+			s.indexer.MessageCreated(&model.Message{ID: m.ID, ChatID: *i.ChatID, SenderID: m.SenderID, Content: m.Content, CreatedAt: m.CreatedAt})
+		}
+		if time.Since(notified) >= importProgressInterval {
+			notified = time.Now()
+			s.hub.SendToUser(i.RequestedBy, websocket.Message{
+				Type: "chat_import_progress",
+				Content: map[string]interface{}{
+					"id":        i.ID,
+					"chat_id":   i.ChatID,
+					"processed": i.Processed,
+					"total":     i.Total,
+				},
+			})
+		}
+	}
+
+	now := time.Now().UTC()
+	claimants, err := s.repo.Complete(i.ID, *i.ChatID, now)
+	if err != nil {
+		return err
+	}
+	i.Status, i.FinishedAt = model.ImportDone, &now
+	s.removeUpload(i)
+	s.hub.SendToUser(i.RequestedBy, websocket.Message{Type: "chat_import_finished", Content: i})
+	for _, userID := range claimants {
+		s.hub.SendToUser(userID, websocket.Message{
+			Type:    "chat_import_claim",
+			Content: map[string]interface{}{"import_id": i.ID, "chat_id": i.ChatID},
+		})
+	}
+	return nil
+}
+
+// resolveAuthors создает для каждого автора выгрузки заглушку: писать от имени чужого аккаунта
+// без его согласия нельзя. Только сам импортирующий сопоставляется себе сразу. Пользователь,
+// найденный по явному списку, email или логину, становится кандидатом: после импорта ему
+// предлагается подтвердить авторство. Сопоставление сохраняется, поэтому при продолжении
+// импорта заглушки не создаются повторно.
+func (s *ChatImportService) resolveAuthors(i *model.ChatImport, authors []chatimport.User) (map[string]repository.ImportUser, error) {
+	mapped, err := s.repo.GetUsers(i.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range authors {
+		if _, ok := mapped[a.ID]; ok {
+			continue
+		}
+		candidate, err := s.findAuthor(i, a)
+		if err != nil {
+			return nil, err
+		}
+		var u repository.ImportUser
+		if candidate != nil && candidate.ID == i.RequestedBy {
+			u.UserID = i.RequestedBy
+		} else {
+			name := a.Name
+			if utf8.RuneCountInString(name) > maxDisplayNameLength {
+				name = string([]rune(name)[:maxDisplayNameLength])
+			}
+			placeholder, err := s.users.CreatePlaceholder(name)
+			if err != nil {
+				return nil, err
+			}
+			u.UserID, u.Placeholder = placeholder.ID, true
+			if candidate != nil {
+				u.CandidateID = &candidate.ID
+			}
+		}
+		if err := s.repo.SaveUser(i.ID, a.ID, u); err != nil {
+			return nil, err
+		}
+		mapped[a.ID] = u
+	}
+	return mapped, nil
+}
+
+// findAuthor ищет пользователя, которым может оказаться автор выгрузки. Отчет импорта об этом
+// ничего не сообщает, чтобы по нему нельзя было узнать, какие адреса и логины заняты.
+// Пользователь, ограничивший общение с импортирующим, предложения не получает.
+func (s *ChatImportService) findAuthor(i *model.ChatImport, a chatimport.User) (*model.User, error) {
+	for _, ref := range []string{i.UserMap[a.ID], a.Email, a.Username} {
+		if ref == "" {
+			continue
+		}
+		user, err := s.findUser(ref)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil || user.IsBot {
+			continue
+		}
+		if user.ID != i.RequestedBy {
+			if err := s.contacts.CheckCanAddToGroup(i.RequestedBy, []uuid.UUID{user.ID}); err != nil {
+				if errors.Is(err, ErrUserBlocked) {
+					return nil, nil
+				}
+				return nil, err
+			}
+		}
+		return user, nil
+	}
+	return nil, nil
+}
+
+// findUser ищет пользователя по email или логину
+func (s *ChatImportService) findUser(ref string) (*model.User, error) {
+	var user *model.User
+	var err error
+	if strings.Contains(ref, "@") {
+		user, err = s.users.GetByEmail(ref)
+	} else {
+		user, err = s.users.GetByUsername(strings.TrimPrefix(ref, "@"))
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return user, err
+}
+
+// createChat создает групповой чат импорта. Сначала в нем только импортирующий; остальные
+// пользователи добавляются, когда подтверждают авторство.
+func (s *ChatImportService) createChat(i *model.ChatImport, export *chatimport.Export) error {
+	members := []uuid.UUID{i.RequestedBy}
+
+	name := i.Name
+	if name == "" {
+		name = strings.TrimSpace(export.Name)
+		if utf8.RuneCountInString(name) > maxImportNameLength {
+			name = string([]rune(name)[:maxImportNameLength])
+		}
+	}
+	chat, err := s.repo.CreateChat(i.ID, name, i.RequestedBy, members)
+	if err != nil {
+		return err
+	}
+	i.ChatID = &chat.ID
+	s.hub.ChatMembersChanged(members)
+	return nil
+}
+
+// ListClaims возвращает предложения подтвердить авторство импортированных сообщений
+func (s *ChatImportService) ListClaims(userID uuid.UUID) ([]model.ImportClaim, error) {
+	return s.repo.ListClaims(userID)
+}
+
+// AcceptClaim передает пользователю сообщения заглушки и добавляет его в чат импорта
+func (s *ChatImportService) AcceptClaim(id, userID uuid.UUID) (*model.ImportClaim, error) {
+	claim, messages, err := s.repo.AcceptClaim(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if claim == nil {
+		return nil, ErrImportClaimNotFound
+	}
+	for i := range messages {
+		s.indexer.MessageCreated(&messages[i])
+	}
+	s.hub.ChatMembersChanged([]uuid.UUID{userID})
+	return claim, nil
+}
+
+// DeclineClaim отклоняет предложение; сообщения остаются у заглушки
+func (s *ChatImportService) DeclineClaim(id, userID uuid.UUID) error {
+	ok, err := s.repo.DeclineClaim(id, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrImportClaimNotFound
+	}
+	return nil
+}
+
+func (s *ChatImportService) fail(i *model.ChatImport, reason error) {
+	if err := s.repo.Fail(i.ID, reason.Error(), time.Now().UTC()); err != nil {
+		log.Printf("error recording failure of chat import %s: %v", i.ID, err)
+	}
+	s.removeUpload(i)
+	s.hub.SendToUser(i.RequestedBy, websocket.Message{
+		Type: "chat_import_failed",
+		Content: map[string]interface{}{
+			"id":      i.ID,
+			"chat_id": i.ChatID,
+			"error":   reason.Error(),
+		},
+	})
+}
+
+func (s *ChatImportService) removeUpload(i *model.ChatImport) {
+	if err := s.blobs.Delete(i.BlobKey); err != nil {
+		log.Printf("error removing upload of import %s: %v", i.ID, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"messenger/internal/model"
 	"messenger/internal/repository"
@@ -12,28 +13,53 @@ import (
 	"github.com/google/uuid"
 )
 
+const maxPinnedChats = 5
+
 type ChatService struct {
 	repo     *repository.ChatRepository
 	userRepo *repository.UserRepository
+	webhooks *WebhookService
+	presence *PresenceService
+	contacts *ContactService
 	hub      *websocket.Hub
 }
 
-func NewChatService(repo *repository.ChatRepository, userRepo *repository.UserRepository, hub *websocket.Hub) *ChatService {
-	return &ChatService{repo: repo, userRepo: userRepo, hub: hub}
+func NewChatService(repo *repository.ChatRepository, userRepo *repository.UserRepository, webhooks *WebhookService, presence *PresenceService, contacts *ContactService, hub *websocket.Hub) *ChatService {
+	return &ChatService{repo: repo, userRepo: userRepo, webhooks: webhooks, presence: presence, contacts: contacts, hub: hub}
 }
 
 func (s *ChatService) CreatePrivateChat(userId0 uuid.UUID, userId1 uuid.UUID) (*model.Chat, error) {
 
 	for _, id := range []uuid.UUID{userId0, userId1} {
-		_, err := s.userRepo.GetById(id)
+		user, err := s.userRepo.GetById(id)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				return nil, fmt.Errorf("пользователь с ID %s не найден", id)
 			}
 			return nil, err
 		}
+		if user.IsDeleted {
+			return nil, fmt.Errorf("пользователь с ID %s не найден", id)
+		}
+	}
+
+	// userId0 — инициатор чата; чат с самим собой отклонит репозиторий
+	if userId0 != userId1 {
+		existing, err := s.repo.ExistPrivateChatByUsers(userId0, userId1, model.TypePrivate)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.contacts.CheckCanStartPrivateChat(userId0, userId1, existing != nil); err != nil {
+			return nil, err
+		}
 	}
-	return s.repo.CreatePrivateChat(userId0, userId1)
+
+	chat, err := s.repo.CreatePrivateChat(userId0, userId1)
+	if err != nil {
+		return nil, err
+	}
+	s.hub.ChatMembersChanged([]uuid.UUID{userId0, userId1})
+	return chat, nil
 }
 
 func (s *ChatService) CreateGroupChatByUsernames(name string, usernames []string, creatorID uuid.UUID) (*model.Chat, error) {
@@ -45,7 +71,7 @@ func (s *ChatService) CreateGroupChatByUsernames(name string, usernames []string
 
 	for _, username := range usernames {
 		user, err := s.userRepo.GetByUsername(username)
-		if err != nil {
+		if err != nil || user.IsDeleted {
 			return nil, fmt.Errorf("пользователь %s не найден", username)
 		}
 
@@ -56,19 +82,85 @@ func (s *ChatService) CreateGroupChatByUsernames(name string, usernames []string
 		}
 	}
 
-	return s.repo.CreateGroupChat(name, userIDs)
+	if err := s.contacts.CheckCanAddToGroup(creatorID, userIDs); err != nil {
+		return nil, err
+	}
+
+	chat, err := s.repo.CreateGroupChat(name, creatorID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	s.hub.ChatMembersChanged(userIDs)
+	return chat, nil
 }
 
-func (s *ChatService) GetUserChats(userID uuid.UUID) ([]model.ChatListItem, error) {
-	chats, err := s.repo.GetUserChats(userID)
+// AddBotToGroup добавляет бота в групповой чат; добавлять ботов может любой участник группы
+func (s *ChatService) AddBotToGroup(chatID, actorID uuid.UUID, botUsername string) error {
+	chat, err := s.repo.GetByID(chatID)
+	if err != nil {
+		return err
+	}
+	if chat == nil {
+		return errors.New("чат не существует")
+	}
+	if chat.Type != model.TypeGroup {
+		return errors.New("ботов можно добавлять только в групповые чаты")
+	}
+
+	isMember, err := s.repo.IsChatMember(chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+
+	bot, err := s.userRepo.GetByUsername(botUsername)
+	if err != nil || !bot.IsBot {
+		return fmt.Errorf("бот %s не найден", botUsername)
+	}
+
+	if err := s.repo.AddMember(chatID, bot.ID); err != nil {
+		return err
+	}
+
+	event := map[string]interface{}{
+		"chat_id":  chatID,
+		"user_id":  bot.ID,
+		"added_by": actorID,
+	}
+	members, err := s.repo.GetChatMembers(chatID)
+	if err != nil {
+		return err
+	}
+	s.hub.ChatMembersChanged(members)
+	for _, memberID := range members {
+		s.hub.SendToUser(memberID, websocket.Message{
+			Type:    "member_joined",
+			Content: event,
+		})
+	}
+	s.webhooks.Publish(chatID, model.EventMemberJoined, event)
+	return nil
+}
+
+func (s *ChatService) GetUserChats(userID uuid.UUID, filter model.ChatListFilter) ([]model.ChatListItem, error) {
+	chats, err := s.repo.GetUserChats(userID, filter, time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
 
 	for i := range chats {
-		if chats[i].InterlocutorID != nil {
-			chats[i].IsOnline = s.hub.IsUserOnline(*chats[i].InterlocutorID)
+		chat := &chats[i]
+		if chat.InterlocutorID == nil || chat.InterlocutorPrivacy == nil {
+			continue
 		}
+		status, lastSeenAt, err := s.presence.Visible(userID, *chat.InterlocutorID, *chat.InterlocutorPrivacy, chat.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+		chat.Status, chat.LastSeenAt = status, lastSeenAt
+		chat.IsOnline = status == websocket.StatusOnline || status == websocket.StatusAway
 	}
 
 	if len(chats) == 0 || chats == nil {
@@ -77,3 +169,70 @@ func (s *ChatService) GetUserChats(userID uuid.UUID) ([]model.ChatListItem, erro
 
 	return chats, nil
 }
+
+// UpdateSettings меняет личные настройки чата: заглушение, закрепление, архив и отметку "непрочитано"
+func (s *ChatService) UpdateSettings(chatID, userID uuid.UUID, upd *model.ChatSettingsUpdate) error {
+	if upd.MutedUntil != nil {
+		// Колонка без часового пояса, поэтому время хранится в UTC
+		t := upd.MutedUntil.UTC()
+		upd.MutedUntil = &t
+	}
+	if upd.Pinned != nil && *upd.Pinned {
+		pinned, err := s.repo.GetPinnedChatIDs(userID)
+		if err != nil {
+			return err
+		}
+		if len(pinned) >= maxPinnedChats && !containsUUID(pinned, chatID) {
+			return fmt.Errorf("можно закрепить не более %d чатов", maxPinnedChats)
+		}
+	}
+
+	updated, err := s.repo.UpdateSettings(chatID, userID, upd, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+	return nil
+}
+
+// ReorderPins задает порядок закрепленных чатов; передать нужно все закрепленные чаты
+func (s *ChatService) ReorderPins(userID uuid.UUID, chatIDs []uuid.UUID) error {
+	pinned, err := s.repo.GetPinnedChatIDs(userID)
+	if err != nil {
+		return err
+	}
+	if len(pinned) != len(chatIDs) {
+		return errors.New("нужно передать все закрепленные чаты")
+	}
+	seen := make(map[uuid.UUID]bool, len(chatIDs))
+	for _, id := range chatIDs {
+		if seen[id] || !containsUUID(pinned, id) {
+			return errors.New("нужно передать все закрепленные чаты")
+		}
+		seen[id] = true
+	}
+	return s.repo.ReorderPins(userID, chatIDs)
+}
+
+// SetNoForwards включает или снимает запрет пересылки сообщений из чата; доступно администраторам
+func (s *ChatService) SetNoForwards(chatID, actorID uuid.UUID, noForwards bool) error {
+	isAdmin, err := s.repo.IsChatAdmin(chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errors.New("доступ запрещен: менять ограничения чата могут только администраторы")
+	}
+	return s.repo.SetNoForwards(chatID, noForwards)
+}
+
+func containsUUID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
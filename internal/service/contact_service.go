@@ -0,0 +1,167 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const maxContactNameLength = 64
+
+var (
+	ErrContactNotFound = errors.New("контакт не найден")
+	ErrUserBlocked     = errors.New("действие недоступно: пользователь ограничил общение с вами")
+	ErrAccountDeleted  = errors.New("действие недоступно: аккаунт собеседника удален")
+)
+
+type ContactService struct {
+	repo     *repository.ContactRepository
+	userRepo *repository.UserRepository
+}
+
+func NewContactService(repo *repository.ContactRepository, userRepo *repository.UserRepository) *ContactService {
+	return &ContactService{repo: repo, userRepo: userRepo}
+}
+
+// AddContact сохраняет пользователя в контакты; повторный вызов меняет имя контакта
+func (s *ContactService) AddContact(ownerID, contactID uuid.UUID, customName string) error {
+	if err := s.checkOtherUser(ownerID, contactID); err != nil {
+		return err
+	}
+
+	customName = strings.TrimSpace(customName)
+	if utf8.RuneCountInString(customName) > maxContactNameLength {
+		return fmt.Errorf("имя контакта не должно быть длиннее %d символов", maxContactNameLength)
+	}
+	var name *string
+	if customName != "" {
+		name = &customName
+	}
+	return s.repo.AddContact(ownerID, contactID, name)
+}
+
+func (s *ContactService) RemoveContact(ownerID, contactID uuid.UUID) error {
+	removed, err := s.repo.RemoveContact(ownerID, contactID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrContactNotFound
+	}
+	return nil
+}
+
+func (s *ContactService) ListContacts(ownerID uuid.UUID) ([]model.Contact, error) {
+	return s.repo.ListContacts(ownerID)
+}
+
+func (s *ContactService) Block(blockerID, blockedID uuid.UUID) error {
+	if err := s.checkOtherUser(blockerID, blockedID); err != nil {
+		return err
+	}
+	return s.repo.Block(blockerID, blockedID)
+}
+
+func (s *ContactService) Unblock(blockerID, blockedID uuid.UUID) error {
+	removed, err := s.repo.Unblock(blockerID, blockedID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return errors.New("пользователь не заблокирован")
+	}
+	return nil
+}
+
+func (s *ContactService) ListBlocked(blockerID uuid.UUID) ([]model.BlockedUser, error) {
+	return s.repo.ListBlocked(blockerID)
+}
+
+// CheckCanStartPrivateChat проверяет блокировки и настройку private_chats собеседника.
+// Настройка приватности касается только новых чатов, блокировка — любых.
+func (s *ContactService) CheckCanStartPrivateChat(initiatorID, targetID uuid.UUID, chatExists bool) error {
+	for _, pair := range [][2]uuid.UUID{{targetID, initiatorID}, {initiatorID, targetID}} {
+		blocked, err := s.repo.IsBlocked(pair[0], pair[1])
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrUserBlocked
+		}
+	}
+	if chatExists {
+		return nil
+	}
+
+	privacy, err := s.userRepo.GetPrivacy(targetID)
+	if err != nil {
+		return err
+	}
+	switch privacy.PrivateChats {
+	case model.VisibilityEveryone:
+		return nil
+	case model.VisibilityContacts:
+		isContact, err := s.repo.IsContact(targetID, initiatorID)
+		if err != nil || isContact {
+			return err
+		}
+		return errors.New("пользователь принимает личные сообщения только от своих контактов")
+	default:
+		return errors.New("пользователь не принимает новые личные чаты")
+	}
+}
+
+// CheckCanMessage запрещает отправку в личный чат, если один из собеседников заблокировал другого
+func (s *ContactService) CheckCanMessage(chatID, senderID uuid.UUID) error {
+	blocked, err := s.repo.IsPrivateChatBlocked(chatID, senderID)
+	if err != nil {
+		return err
+	}
+	if blocked {
+		return ErrUserBlocked
+	}
+	// Личный чат с удаленным аккаунтом остается у собеседника только для чтения
+	deleted, err := s.repo.IsPrivateChatWithDeleted(chatID)
+	if err != nil {
+		return err
+	}
+	if deleted {
+		return ErrAccountDeleted
+	}
+	return nil
+}
+
+// CheckCanAddToGroup запрещает добавлять в группу тех, кто заблокировал actorID
+func (s *ContactService) CheckCanAddToGroup(actorID uuid.UUID, userIDs []uuid.UUID) error {
+	blockers, err := s.repo.GetBlockersAmong(actorID, userIDs)
+	if err != nil {
+		return err
+	}
+	if len(blockers) > 0 {
+		return ErrUserBlocked
+	}
+	return nil
+}
+
+func (s *ContactService) checkOtherUser(actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return errors.New("нельзя выполнить это действие с самим собой")
+	}
+	user, err := s.userRepo.GetById(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if user.IsDeleted {
+		return ErrUserNotFound
+	}
+	return nil
+}
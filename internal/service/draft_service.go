@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const maxDraftLength = 10000
+
+type DraftService struct {
+	repo     *repository.DraftRepository
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	hub      *websocket.Hub
+}
+
+func NewDraftService(repo *repository.DraftRepository, messages *repository.MessageRepository, chatRepo *repository.ChatRepository, hub *websocket.Hub) *DraftService {
+	return &DraftService{
+		repo:     repo,
+		messages: messages,
+		chatRepo: chatRepo,
+		hub:      hub,
+	}
+}
+
+// GetDraft возвращает черновик пользователя в чате или nil, если его нет
+func (s *DraftService) GetDraft(chatID, userID uuid.UUID) (*model.Draft, error) {
+	if err := s.checkMember(chatID, userID); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(userID, chatID)
+}
+
+// SaveDraft сохраняет черновик и рассылает его остальным подключениям пользователя событием
+// draft_updated; connectionID — подключение, из которого пришло изменение, uuid.Nil, если неизвестно.
+// Черновик без текста и ответа удаляется — тогда возвращается nil.
+func (s *DraftService) SaveDraft(userID, connectionID uuid.UUID, draft *model.Draft) (*model.Draft, error) {
+	if err := s.checkMember(draft.ChatID, userID); err != nil {
+		return nil, err
+	}
+	if utf8.RuneCountInString(draft.Text) > maxDraftLength {
+		return nil, fmt.Errorf("черновик длиннее %d символов", maxDraftLength)
+	}
+	if draft.ReplyToMessageID != nil {
+		message, err := s.messages.GetMessageByID(*draft.ReplyToMessageID)
+		if err != nil {
+			return nil, err
+		}
+		if message == nil || message.ChatID != draft.ChatID || message.Type == model.MessageSystem {
+			return nil, ErrMessageNotFound
+		}
+	}
+
+	if strings.TrimSpace(draft.Text) == "" && draft.ReplyToMessageID == nil {
+		removed, err := s.repo.Delete(userID, draft.ChatID)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			s.notify(userID, connectionID, draft.ChatID, nil)
+		}
+		return nil, nil
+	}
+
+	draft.UpdatedAt = time.Now().UTC()
+	if err := s.repo.Save(userID, draft); err != nil {
+		return nil, err
+	}
+	s.notify(userID, connectionID, draft.ChatID, draft)
+	return draft, nil
+}
+
+// ClearOnSend удаляет черновик после отправки сообщения в чат. Черновик, измененный
+// позже sentAt, остается: его уже набирают заново.
+func (s *DraftService) ClearOnSend(chatID, userID uuid.UUID, sentAt time.Time) {
+	removed, err := s.repo.DeleteIfOlder(userID, chatID, sentAt)
+	if err != nil {
+		log.Printf("error clearing draft of %s in chat %s: %v", userID, chatID, err)
+		return
+	}
+	if removed {
+		s.notify(userID, uuid.Nil, chatID, nil)
+	}
+}
+
+func (s *DraftService) checkMember(chatID, userID uuid.UUID) error {
+	isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+	return nil
+}
+
+// notify отправляет черновик подключениям пользователя, кроме exceptConnection; draft == nil
+// означает, что черновик удален. По updated_at устройство отбрасывает устаревшие изменения.
+func (s *DraftService) notify(userID, exceptConnection, chatID uuid.UUID, draft *model.Draft) {
+	s.hub.SendToUserExcept(userID, exceptConnection, websocket.Message{
+		Type: "draft_updated",
+		Content: map[string]interface{}{
+			"chat_id": chatID,
+			"draft":   draft,
+		},
+	})
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"errors"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	IncomingWebhookPrefix = "msg_hook_"
+
+	incomingWebhookRate        = 1.0 // сообщений в секунду на вебхук
+	incomingWebhookBurst       = 20
+	maxIncomingTextLength      = 4000
+	maxIncomingAttachments     = 10
+	maxIncomingDisplayNameSize = 100
+)
+
+var (
+	ErrIncomingWebhookNotFound = errors.New("вебхук не найден")
+	ErrRateLimited             = errors.New("слишком много запросов, повторите позже")
+)
+
+type IncomingWebhookService struct {
+	repo     *repository.IncomingWebhookRepository
+	chatRepo *repository.ChatRepository
+	messages *MessageService
+	limiter  *rateLimiter
+}
+
+func NewIncomingWebhookService(repo *repository.IncomingWebhookRepository, chatRepo *repository.ChatRepository, messages *MessageService) *IncomingWebhookService {
+	return &IncomingWebhookService{
+		repo:     repo,
+		chatRepo: chatRepo,
+		messages: messages,
+		limiter:  newRateLimiter(incomingWebhookRate, incomingWebhookBurst),
+	}
+}
+
+// CreateWebhook выпускает секретный адрес для публикации в групповой чат.
+// Токен возвращается открытым только один раз.
+func (s *IncomingWebhookService) CreateWebhook(chatID, actorID uuid.UUID, name string) (*model.IncomingWebhook, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, "", errors.New("название вебхука должно содержать от 1 до 100 символов")
+	}
+
+	chat, err := s.chatRepo.GetByID(chatID)
+	if err != nil {
+		return nil, "", err
+	}
+	if chat == nil {
+		return nil, "", errors.New("чат не существует")
+	}
+	// В личном чате служебный отправитель стал бы третьим участником
+	if chat.Type != model.TypeGroup {
+		return nil, "", errors.New("входящие вебхуки доступны только в групповых чатах")
+	}
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return nil, "", err
+	}
+
+	token, err := newSecretToken(IncomingWebhookPrefix)
+	if err != nil {
+		return nil, "", err
+	}
+
+	senderKey := strings.ReplaceAll(uuid.NewString(), "-", "")
+	w := &model.IncomingWebhook{ChatID: chatID, Name: name, CreatedBy: &actorID}
+	err = s.repo.Create(w, "hook_"+senderKey, senderKey+"@hooks.messenger.local", hashToken(token))
+	if err != nil {
+		return nil, "", err
+	}
+	return w, token, nil
+}
+
+func (s *IncomingWebhookService) ListWebhooks(chatID, actorID uuid.UUID) ([]model.IncomingWebhook, error) {
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByChat(chatID)
+}
+
+func (s *IncomingWebhookService) RevokeWebhook(chatID, webhookID, actorID uuid.UUID) error {
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return err
+	}
+	w, err := s.repo.GetByID(webhookID)
+	if err != nil {
+		return err
+	}
+	if w == nil || w.ChatID != chatID {
+		return ErrIncomingWebhookNotFound
+	}
+	return s.repo.Revoke(w)
+}
+
+// Post публикует сообщение по секретному токену вебхука от имени его служебного отправителя
+func (s *IncomingWebhookService) Post(token string, payload model.IncomingWebhookPayload) (*model.Message, error) {
+	if !strings.HasPrefix(token, IncomingWebhookPrefix) {
+		return nil, ErrIncomingWebhookNotFound
+	}
+	w, err := s.repo.GetByTokenHash(hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if w == nil || w.RevokedAt != nil {
+		return nil, ErrIncomingWebhookNotFound
+	}
+
+	if !s.limiter.Allow(w.ID) {
+		return nil, ErrRateLimited
+	}
+
+	if err := validateIncomingPayload(&payload); err != nil {
+		return nil, err
+	}
+
+	message := &model.Message{
+		ChatID:      w.ChatID,
+		SenderID:    w.SenderID,
+		SenderName:  payload.Username,
+		Content:     payload.Text,
+		Attachments: payload.Attachments,
+	}
+	if message.SenderName == "" {
+		message.SenderName = w.Name
+	}
+	if payload.IconURL != "" {
+		message.IconURL = &payload.IconURL
+	}
+
+	if err := s.messages.SendMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (s *IncomingWebhookService) requireAdmin(chatID, userID uuid.UUID) error {
+	isAdmin, err := s.chatRepo.IsChatAdmin(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errors.New("доступ запрещен: требуются права администратора чата")
+	}
+	return nil
+}
+
+func validateIncomingPayload(p *model.IncomingWebhookPayload) error {
+	p.Text = strings.TrimSpace(p.Text)
+	p.Username = strings.TrimSpace(p.Username)
+
+	if p.Text == "" && len(p.Attachments) == 0 {
+		return errors.New("сообщение должно содержать текст или вложения")
+	}
+	if len([]rune(p.Text)) > maxIncomingTextLength {
+		return errors.New("текст сообщения слишком длинный")
+	}
+	if len([]rune(p.Username)) > maxIncomingDisplayNameSize {
+		return errors.New("имя отправителя слишком длинное")
+	}
+	if p.IconURL != "" && !isHTTPURL(p.IconURL) {
+		return errors.New("некорректный адрес иконки")
+	}
+	if len(p.Attachments) > maxIncomingAttachments {
+		return errors.New("слишком много вложений")
+	}
+	for _, a := range p.Attachments {
+		if (a.URL != "" && !isHTTPURL(a.URL)) || (a.ImageURL != "" && !isHTTPURL(a.ImageURL)) {
+			return errors.New("некорректный адрес во вложении")
+		}
+	}
+	return nil
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
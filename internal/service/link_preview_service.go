@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"log"
+	"messenger/internal/markup"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	previewQueueSize   = 1000
+	previewWorkers     = 4
+	previewFetchLimit  = 10 * time.Second
+	previewCacheTTL    = 24 * time.Hour
+	previewFailureTTL  = time.Hour
+	previewTrailingSet = ".,;:!?)]}»\"'"
+)
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'` + "`" + `]+`)
+
+// LinkFetcher загружает превью страницы. Реализация — linkpreview.Fetcher
+type LinkFetcher interface {
+	Fetch(ctx context.Context, url string) (*model.LinkPreview, error)
+}
+
+// LinkPreviewService после отправки сообщения в фоне строит превью его первой ссылки,
+// сохраняет в сообщение и рассылает участникам событие message_updated
+type LinkPreviewService struct {
+	fetcher  LinkFetcher
+	cache    *repository.LinkPreviewRepository
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	webhooks *WebhookService
+	hub      *websocket.Hub
+	queue    chan previewJob
+}
+
+type previewJob struct {
+	message model.Message
+	url     string
+}
+
+func NewLinkPreviewService(fetcher LinkFetcher, cache *repository.LinkPreviewRepository, messages *repository.MessageRepository, chatRepo *repository.ChatRepository, webhooks *WebhookService, hub *websocket.Hub) *LinkPreviewService {
+	return &LinkPreviewService{
+		fetcher:  fetcher,
+		cache:    cache,
+		messages: messages,
+		chatRepo: chatRepo,
+		webhooks: webhooks,
+		hub:      hub,
+		queue:    make(chan previewJob, previewQueueSize),
+	}
+}
+
+// MessageCreated ставит сообщение со ссылкой в очередь; отправку сообщения не задерживает
+func (s *LinkPreviewService) MessageCreated(m *model.Message) {
+	url := firstLink(m)
+	if url == "" {
+		return
+	}
+	select {
+	case s.queue <- previewJob{message: *m, url: url}:
+	default:
+		log.Printf("link preview queue is full, skipping message %s", m.ID)
+	}
+}
+
+// Run обрабатывает очередь несколькими воркерами: загрузка страницы может занять секунды
+func (s *LinkPreviewService) Run() {
+	var wg sync.WaitGroup
+	for i := 0; i < previewWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range s.queue {
+				s.process(job)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *LinkPreviewService) process(job previewJob) {
+	preview, err := s.preview(job.url)
+	if err != nil {
+		log.Printf("error building link preview for message %s: %v", job.message.ID, err)
+		return
+	}
+	if preview == nil {
+		return
+	}
+	if err := s.messages.SetLinkPreview(job.message.ID, preview); err != nil {
+		log.Printf("error saving link preview for message %s: %v", job.message.ID, err)
+		return
+	}
+
+	m := job.message
+	m.LinkPreview = preview
+	members, err := s.chatRepo.GetChatMembers(m.ChatID)
+	if err != nil {
+		log.Printf("error loading members of chat %s: %v", m.ChatID, err)
+		return
+	}
+	for _, userID := range members {
+		s.hub.SendToUser(userID, websocket.Message{
+			Type:    "message_updated",
+			Content: &m,
+		})
+	}
+	s.webhooks.Publish(m.ChatID, model.EventMessageUpdated, &m)
+}
+
+// preview берет превью из кэша или загружает страницу. nil без ошибки — превью нет.
+// Неудачные загрузки тоже кэшируются, чтобы не обращаться к недоступному сайту на каждое сообщение.
+func (s *LinkPreviewService) preview(url string) (*model.LinkPreview, error) {
+	now := time.Now().UTC()
+	cached, found, err := s.cache.Get(url, now.Add(-previewCacheTTL), now.Add(-previewFailureTTL))
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), previewFetchLimit)
+	defer cancel()
+	preview, err := s.fetcher.Fetch(ctx, url)
+	if err != nil {
+		log.Printf("link preview for %s is unavailable: %v", url, err)
+		preview = nil
+	}
+	if err := s.cache.Save(url, preview, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return preview, nil
+}
+
+// firstLink возвращает первую ссылку сообщения: из разметки или из текста вне блоков кода
+func firstLink(m *model.Message) string {
+	for _, e := range m.Entities {
+		if e.Type == model.EntityTextLink && (strings.HasPrefix(e.URL, "http://") || strings.HasPrefix(e.URL, "https://")) {
+			return e.URL
+		}
+	}
+	for _, loc := range urlPattern.FindAllStringIndex(m.Content, -1) {
+		if insideCode(m.Entities, markup.UTF16Len(m.Content[:loc[0]])) {
+			continue
+		}
+		if url, ok := markup.SafeURL(strings.TrimRight(m.Content[loc[0]:loc[1]], previewTrailingSet)); ok {
+			return url
+		}
+	}
+	return ""
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"messenger/internal/markup"
+	"messenger/internal/model"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const mentionAll = "all"
+
+// mentionToken — найденное в тексте @имя; start и end — байтовые смещения вместе с @
+type mentionToken struct {
+	name       string
+	start, end int
+}
+
+// findMentions ищет @имя в начале текста или после символа, который не может быть частью
+// слова, — так адреса почты не считаются упоминаниями. Точки и дефисы в конце имени
+// относятся к тексту, а не к логину.
+func findMentions(text string) []mentionToken {
+	var tokens []mentionToken
+	prev := ' '
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+		if r != '@' || isMentionRune(prev) {
+			prev = r
+			continue
+		}
+
+		end := i
+		for end < len(text) {
+			next, n := utf8.DecodeRuneInString(text[end:])
+			if !isMentionRune(next) && next != '.' && next != '-' {
+				break
+			}
+			end += n
+		}
+		end = i + len(strings.TrimRight(text[i:end], ".-"))
+		if end == i {
+			prev = r
+			continue
+		}
+		tokens = append(tokens, mentionToken{name: strings.ToLower(text[i:end]), start: i - size, end: end})
+		prev, _ = utf8.DecodeLastRuneInString(text[:end])
+		i = end
+	}
+	return tokens
+}
+
+func isMentionRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// resolveMentions превращает @имя в сущности сообщения. Упоминанием считается только логин
+// участника чата и @all вне блоков кода; остальное остается обычным текстом.
+func (s *MessageService) resolveMentions(message *model.Message) error {
+	tokens := findMentions(message.Content)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var names []string
+	for _, t := range tokens {
+		if t.name != mentionAll {
+			names = append(names, t.name)
+		}
+	}
+	members := map[string]uuid.UUID{}
+	if len(names) > 0 {
+		var err error
+		if members, err = s.chatRepo.GetMemberIDsByUsernames(message.ChatID, names); err != nil {
+			return err
+		}
+	}
+
+	// Смещения считаются последовательно, чтобы не пересчитывать префикс для каждого упоминания
+	offset, pos := 0, 0
+	var mentions []model.MessageEntity
+	for _, t := range tokens {
+		entity := model.MessageEntity{Type: model.EntityMention}
+		if t.name == mentionAll {
+			entity.Type = model.EntityMentionAll
+		} else if id, ok := members[t.name]; ok {
+			entity.UserID = &id
+		} else {
+			continue
+		}
+		offset += markup.UTF16Len(message.Content[pos:t.start])
+		entity.Offset = offset
+		entity.Length = markup.UTF16Len(message.Content[t.start:t.end])
+		offset += entity.Length
+		pos = t.end
+		if !insideCode(message.Entities, entity.Offset) {
+			mentions = append(mentions, entity)
+		}
+	}
+	if len(mentions) > 0 {
+		message.Entities = append(message.Entities, mentions...)
+		markup.SortEntities(message.Entities)
+	}
+	return nil
+}
+
+func insideCode(entities []model.MessageEntity, offset int) bool {
+	for _, e := range entities {
+		if (e.Type == model.EntityCode || e.Type == model.EntityPre) && offset >= e.Offset && offset < e.Offset+e.Length {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionedUserIDs возвращает, кого уведомить об упоминании: отправитель в список не входит
+func mentionedUserIDs(message *model.Message, members []uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, e := range message.Entities {
+		switch e.Type {
+		case model.EntityMentionAll:
+			ids = append(ids, members...)
+		case model.EntityMention:
+			ids = append(ids, *e.UserID)
+		}
+	}
+	mentioned := uniqueUUIDs(ids)
+	for i, id := range mentioned {
+		if id == message.SenderID {
+			return append(mentioned[:i], mentioned[i+1:]...)
+		}
+	}
+	return mentioned
+}
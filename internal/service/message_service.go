@@ -1,10 +1,18 @@
 package service
 
 import (
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/markup"
 	"messenger/internal/model"
 	"messenger/internal/repository"
+	"messenger/internal/search"
 	"messenger/internal/service/websocket"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -12,13 +20,27 @@ import (
 type MessageService struct {
 	repo     *repository.MessageRepository
 	chatRepo *repository.ChatRepository
+	bots     *BotService
+	webhooks *WebhookService
+	contacts *ContactService
+	index    search.Index
+	indexer  *SearchIndexer
+	previews *LinkPreviewService
 	hub      *websocket.Hub
 }
 
-func NewMessageService(repo *repository.MessageRepository, chatRepo *repository.ChatRepository, hub *websocket.Hub) *MessageService {
+var ErrNotChatMember = errors.New("доступ запрещен: вы не являетесь участником этого чата")
+
+func NewMessageService(repo *repository.MessageRepository, chatRepo *repository.ChatRepository, bots *BotService, webhooks *WebhookService, contacts *ContactService, index search.Index, indexer *SearchIndexer, previews *LinkPreviewService, hub *websocket.Hub) *MessageService {
 	return &MessageService{
 		repo:     repo,
 		chatRepo: chatRepo,
+		bots:     bots,
+		webhooks: webhooks,
+		contacts: contacts,
+		index:    index,
+		indexer:  indexer,
+		previews: previews,
 		hub:      hub,
 	}
 }
@@ -30,13 +52,53 @@ func (s *MessageService) SendMessage(message *model.Message) error {
 		return err
 	}
 	if !isMember {
-		return errors.New("доступ запрещен: вы не являетесь участником этого чата")
+		return ErrNotChatMember
+	}
+	if err := s.contacts.CheckCanMessage(message.ChatID, message.SenderID); err != nil {
+		return err
+	}
+	// В опросе Content — вопрос, он не размечается и не содержит упоминаний
+	if message.Type != model.MessagePoll {
+		if err := formatMessage(message); err != nil {
+			return err
+		}
+		if err := s.resolveMentions(message); err != nil {
+			return err
+		}
 	}
+	return s.deliver(message)
+}
 
-	err = s.repo.SendMessage(message)
-	if err != nil {
+// formatMessage разбирает Content в формате Format: в Content остается текст без разметки,
+// разметка переходит в Entities, а исходник — в Source
+func formatMessage(message *model.Message) error {
+	if message.Format == "" {
+		message.Format = model.FormatPlain
+	}
+	if !message.Format.IsValid() {
+		return errors.New("неизвестный формат текста")
+	}
+	parsed := markup.Parse(message.Content, message.Format)
+	message.Content, message.Entities, message.Source = parsed.Text, parsed.Entities, parsed.Source
+	if strings.TrimSpace(message.Content) == "" && message.Format != model.FormatPlain {
+		return errors.New("сообщение не содержит текста")
+	}
+	return nil
+}
+
+// deliver сохраняет сообщение и рассылает его; права отправителя уже проверены
+func (s *MessageService) deliver(message *model.Message) error {
+	if err := s.repo.SendMessage(message); err != nil {
 		return err
 	}
+	s.indexer.MessageCreated(message)
+	if message.Poll != nil {
+		// Рассылается всем участникам, поэтому ответ викторины скрыт
+		message.Poll = message.Poll.Shared()
+	}
+	if err := s.chatRepo.UnarchiveOnNewMessage(message.ChatID, time.Now().UTC()); err != nil {
+		log.Printf("error unarchiving chat %s: %v", message.ChatID, err)
+	}
 
 	// Уведомляем участников чата
 	members, err := s.chatRepo.GetChatMembers(message.ChatID)
@@ -49,25 +111,218 @@ func (s *MessageService) SendMessage(message *model.Message) error {
 			Content: message,
 		})
 	}
+	// Пересланные сообщения сохраняют текст и превью, но повторно никого не уведомляют
+	if message.Forwarded == nil {
+		s.notifyMentioned(message, members)
+		s.previews.MessageCreated(message)
+	}
+	s.webhooks.Publish(message.ChatID, model.EventNewMessage, message)
+
+	// Слэш-команды и сообщения в личных чатах передаются ботам; опросы — нет
+	if message.Type != model.MessageText {
+		return nil
+	}
+	if err := s.bots.DispatchMessage(message); err != nil {
+		log.Printf("error dispatching message %s to bots: %v", message.ID, err)
+	}
 	return nil
 }
 
-func (s *MessageService) GetMessagesByChatID(chatID uuid.UUID) ([]model.Message, error) {
+const (
+	maxForwardMessages = 100
+	maxForwardTargets  = 10
+)
+
+// ForwardMessages пересылает сообщения в каждый из чатов targetIDs от имени userID. Пользователь должен
+// состоять и в исходных, и в целевых чатах; из чатов с запретом пересылки сообщения не пересылаются.
+// В целевых чатах сообщения идут в порядке их отправки в оригинале.
+func (s *MessageService) ForwardMessages(userID uuid.UUID, messageIDs, targetIDs []uuid.UUID) ([]model.Message, error) {
+	messageIDs, targetIDs = uniqueUUIDs(messageIDs), uniqueUUIDs(targetIDs)
+	if len(messageIDs) == 0 || len(targetIDs) == 0 {
+		return nil, errors.New("нужно указать сообщения и чаты для пересылки")
+	}
+	if len(messageIDs) > maxForwardMessages {
+		return nil, fmt.Errorf("за раз можно переслать не более %d сообщений", maxForwardMessages)
+	}
+	if len(targetIDs) > maxForwardTargets {
+		return nil, fmt.Errorf("за раз можно переслать не более чем в %d чатов", maxForwardTargets)
+	}
+
+	originals, err := s.repo.GetMessagesByIDs(messageIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(originals) != len(messageIDs) {
+		return nil, ErrMessageNotFound
+	}
+	sort.Slice(originals, func(i, j int) bool {
+		if !originals[i].CreatedAt.Equal(originals[j].CreatedAt) {
+			return originals[i].CreatedAt.Before(originals[j].CreatedAt)
+		}
+		return originals[i].ID.String() < originals[j].ID.String()
+	})
+
+	checked := make(map[uuid.UUID]bool)
+	for _, m := range originals {
+		if m.Type == model.MessageSystem {
+			return nil, errors.New("системные сообщения нельзя переслать")
+		}
+		if m.Type == model.MessagePoll {
+			return nil, errors.New("опросы нельзя переслать")
+		}
+		if checked[m.ChatID] {
+			continue
+		}
+		if err := s.checkCanForwardFrom(m.ChatID, userID); err != nil {
+			return nil, err
+		}
+		checked[m.ChatID] = true
+	}
+
+	// Все целевые чаты проверяются заранее, чтобы не переслать сообщения только в часть из них
+	for _, chatID := range targetIDs {
+		isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isMember {
+			return nil, errors.New("доступ запрещен: вы не являетесь участником целевого чата")
+		}
+		if err := s.contacts.CheckCanMessage(chatID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	forwarded := make([]model.Message, 0, len(originals)*len(targetIDs))
+	for _, chatID := range targetIDs {
+		for _, original := range originals {
+			m := model.Message{
+				ChatID:      chatID,
+				SenderID:    userID,
+				Content:     original.Content,
+				Entities:    original.Entities,
+				Format:      original.Format,
+				Source:      original.Source,
+				LinkPreview: original.LinkPreview,
+				Forwarded:   forwardedFrom(&original),
+				// Вложения читаются из сообщения, где хранятся, а не из первого оригинала
+				AttachmentsFrom: original.AttachmentsFrom,
+			}
+			if err := s.deliver(&m); err != nil {
+				return forwarded, err
+			}
+			forwarded = append(forwarded, m)
+		}
+	}
+	return forwarded, nil
+}
+
+func (s *MessageService) checkCanForwardFrom(chatID, userID uuid.UUID) error {
+	isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		// Не раскрываем, что сообщение существует
+		return ErrMessageNotFound
+	}
+	chat, err := s.chatRepo.GetByID(chatID)
+	if err != nil {
+		return err
+	}
+	if chat == nil {
+		return ErrMessageNotFound
+	}
+	if chat.NoForwards {
+		return errors.New("пересылка сообщений из этого чата запрещена")
+	}
+	return nil
+}
+
+// forwardedFrom указывает на самый первый оригинал, если сообщение уже было переслано
+func forwardedFrom(m *model.Message) *model.ForwardedFrom {
+	if m.Forwarded != nil {
+		from := *m.Forwarded
+		return &from
+	}
+	return &model.ForwardedFrom{
+		MessageID:  m.ID,
+		ChatID:     m.ChatID,
+		SenderID:   m.SenderID,
+		SenderName: m.SenderName,
+		CreatedAt:  m.CreatedAt,
+	}
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// SendSystemMessage записывает в чат служебное сообщение о действии actorID и рассылает его участникам.
+// Проверка прав на само действие остается на вызывающем; в поиск и ботам такие сообщения не попадают.
+func (s *MessageService) SendSystemMessage(chatID, actorID uuid.UUID, content string, action *model.SystemAction) (*model.Message, error) {
+	message := &model.Message{
+		ChatID:   chatID,
+		SenderID: actorID,
+		Content:  content,
+		Type:     model.MessageSystem,
+		Action:   action,
+	}
+	if err := s.repo.SendMessage(message); err != nil {
+		return nil, err
+	}
+
+	members, err := s.chatRepo.GetChatMembers(chatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range members {
+		s.hub.SendToUser(userID, websocket.Message{
+			Type:    "new_message",
+			Content: message,
+		})
+	}
+	s.webhooks.Publish(chatID, model.EventNewMessage, message)
+	return message, nil
+}
+
+// GetMessagesByChatID возвращает историю чата; результаты опросов — глазами viewerID
+func (s *MessageService) GetMessagesByChatID(chatID, viewerID uuid.UUID) ([]model.Message, error) {
 	exists, err := s.chatRepo.Exists(chatID)
 	if err != nil {
 		return nil, err
 	}
 	if !exists {
-		return nil, errors.New("чат не существует")
+		return nil, ErrChatNotFound
+	}
+	if err := s.checkMember(chatID, viewerID); err != nil {
+		return nil, err
 	}
 
-	return s.repo.GetMessagesByChatID(chatID)
+	return s.repo.GetMessagesByChatID(chatID, viewerID, time.Now().UTC())
 }
 
 func (s *MessageService) MarkChatAsRead(chatID, userID uuid.UUID) error {
+	if err := s.checkMember(chatID, userID); err != nil {
+		return err
+	}
 	if err := s.repo.MarkAsRead(chatID, userID); err != nil {
 		return err
 	}
+	if err := s.chatRepo.ClearMarkedUnread(chatID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.MarkMentionsRead(chatID, userID, time.Now().UTC()); err != nil {
+		return err
+	}
 
 	// Уведомляем участников чата, что сообщения прочитаны
 	members, _ := s.chatRepo.GetChatMembers(chatID)
@@ -82,5 +337,168 @@ func (s *MessageService) MarkChatAsRead(chatID, userID uuid.UUID) error {
 			})
 		}
 	}
+	s.webhooks.Publish(chatID, model.EventMessagesRead, map[string]interface{}{
+		"chat_id":   chatID,
+		"reader_id": userID,
+	})
+	return nil
+}
+
+func (s *MessageService) checkMember(chatID, userID uuid.UUID) error {
+	isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrNotChatMember
+	}
 	return nil
 }
+
+// notifyMentioned сохраняет упоминания и отправляет упомянутым событие mentioned. Оно приходит
+// и в заглушенных чатах: клиент показывает уведомление независимо от настроек чата.
+func (s *MessageService) notifyMentioned(message *model.Message, members []uuid.UUID) {
+	mentioned := mentionedUserIDs(message, members)
+	if len(mentioned) == 0 {
+		return
+	}
+	if err := s.repo.AddMentions(message, mentioned); err != nil {
+		log.Printf("error saving mentions of message %s: %v", message.ID, err)
+		return
+	}
+	for _, userID := range mentioned {
+		s.hub.SendToUser(userID, websocket.Message{
+			Type: "mentioned",
+			Content: map[string]interface{}{
+				"chat_id": message.ChatID,
+				"message": message,
+			},
+		})
+	}
+}
+
+// GetMentions возвращает упоминания пользователя; cursor — значение next_cursor предыдущей страницы
+func (s *MessageService) GetMentions(userID uuid.UUID, unreadOnly bool, limit int, cursor string) (*model.MentionsPage, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	var afterTime *time.Time
+	var afterID *uuid.UUID
+	if cursor != "" {
+		t, id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		afterTime, afterID = &t, &id
+	}
+
+	mentions, err := s.repo.GetMentions(userID, unreadOnly, afterTime, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &model.MentionsPage{Results: mentions}
+	if page.Results == nil {
+		page.Results = []model.Mention{}
+	}
+	if len(mentions) == limit {
+		last := mentions[len(mentions)-1].Message
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
+
+// SearchMessages ищет по тексту в чатах пользователя; cursor — значение next_cursor предыдущей страницы
+func (s *MessageService) SearchMessages(q model.MessageSearchQuery, cursor string) (*model.MessageSearchPage, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	if len([]rune(q.Text)) < 2 {
+		return nil, errors.New("поисковый запрос должен содержать не менее 2 символов")
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+	if cursor != "" {
+		afterTime, afterID, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		q.AfterTime, q.AfterID = &afterTime, &afterID
+	}
+
+	chatIDs, err := s.chatRepo.GetUserChatIDs(q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	q.ChatIDs = chatIDs
+
+	hits, err := s.index.Search(q)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &model.MessageSearchPage{Results: []model.MessageSearchResult{}}
+	if len(hits) == 0 {
+		return page, nil
+	}
+	if len(hits) == q.Limit {
+		last := hits[len(hits)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.MessageID)
+	}
+
+	// Индекс возвращает только идентификаторы — сами сообщения берутся из базы
+	ids := make([]uuid.UUID, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.MessageID
+	}
+	messages, err := s.repo.GetMessagesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]model.Message, len(messages))
+	for _, m := range messages {
+		byID[m.ID] = m
+	}
+	for _, hit := range hits {
+		// Сообщение могло быть удалено, а индекс еще не обновлен
+		if m, ok := byID[hit.MessageID]; ok {
+			page.Results = append(page.Results, model.MessageSearchResult{Message: m, Snippet: hit.Snippet})
+		}
+	}
+	return page, nil
+}
+
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.Format(time.RFC3339Nano) + "|" + id.String()))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	invalid := errors.New("некорректный курсор")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, invalid
+	}
+	timePart, idPart, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, invalid
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, timePart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, invalid
+	}
+	id, err := uuid.Parse(idPart)
+	if err != nil {
+		return time.Time{}, uuid.Nil, invalid
+	}
+	return createdAt, id, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TTLDay  = 24 * 60 * 60
+	TTLWeek = 7 * TTLDay
+
+	minMessageTTL      = 60
+	maxMessageTTL      = 365 * TTLDay
+	ttlSweepInterval   = 10 * time.Second
+	ttlSweepBatchSize  = 500
+	ttlSweepMaxBatches = 20 // за один проход, чтобы не занимать базу надолго
+)
+
+// MessageTTLService управляет таймером автоудаления сообщений в чатах и удаляет
+// сообщения, срок жизни которых истек
+type MessageTTLService struct {
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	sender   *MessageService
+	indexer  *SearchIndexer
+	webhooks *WebhookService
+	hub      *websocket.Hub
+}
+
+func NewMessageTTLService(messages *repository.MessageRepository, chatRepo *repository.ChatRepository, sender *MessageService, indexer *SearchIndexer, webhooks *WebhookService, hub *websocket.Hub) *MessageTTLService {
+	return &MessageTTLService{
+		messages: messages,
+		chatRepo: chatRepo,
+		sender:   sender,
+		indexer:  indexer,
+		webhooks: webhooks,
+		hub:      hub,
+	}
+}
+
+// SetTTL меняет таймер чата; 0 выключает автоудаление. Таймер действует на сообщения,
+// отправленные после изменения. В чат записывается системное сообщение.
+func (s *MessageTTLService) SetTTL(chatID, actorID uuid.UUID, seconds int) error {
+	if seconds != 0 && (seconds < minMessageTTL || seconds > maxMessageTTL) {
+		return fmt.Errorf("таймер автоудаления должен быть от %d секунд до %d дней", minMessageTTL, maxMessageTTL/TTLDay)
+	}
+	isAdmin, err := s.chatRepo.IsChatAdmin(chatID, actorID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errors.New("доступ запрещен: менять таймер автоудаления могут только администраторы чата")
+	}
+
+	var ttl *int
+	if seconds > 0 {
+		ttl = &seconds
+	}
+	if err := s.chatRepo.SetMessageTTL(chatID, ttl); err != nil {
+		return err
+	}
+
+	_, err = s.sender.SendSystemMessage(chatID, actorID, ttlChangeText(seconds), &model.SystemAction{
+		Type:    model.ActionMessageTTLChanged,
+		ActorID: actorID,
+		TTL:     &seconds,
+	})
+	return err
+}
+
+func ttlChangeText(seconds int) string {
+	switch {
+	case seconds == 0:
+		return "отключил(а) автоудаление сообщений"
+	case seconds%TTLWeek == 0:
+		return fmt.Sprintf("установил(а) автоудаление сообщений через %d нед.", seconds/TTLWeek)
+	case seconds%TTLDay == 0:
+		return fmt.Sprintf("установил(а) автоудаление сообщений через %d дн.", seconds/TTLDay)
+	default:
+		return "установил(а) автоудаление сообщений через " + (time.Duration(seconds) * time.Second).String()
+	}
+}
+
+// Run периодически удаляет истекшие сообщения пачками
+func (s *MessageTTLService) Run() {
+	ticker := time.NewTicker(ttlSweepInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for i := 0; i < ttlSweepMaxBatches; i++ {
+			n, err := s.sweep()
+			if err != nil {
+				log.Printf("error deleting expired messages: %v", err)
+				break
+			}
+			if n < ttlSweepBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (s *MessageTTLService) sweep() (int, error) {
+	byChat, err := s.messages.DeleteExpired(time.Now().UTC(), ttlSweepBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	return publishDeleted(s.chatRepo, s.indexer, s.webhooks, s.hub, byChat), nil
+}
+
+// publishDeleted убирает удаленные сообщения из поискового индекса и сообщает о них участникам
+// чатов и вебхукам. Возвращает число удаленных сообщений.
+func publishDeleted(chatRepo *repository.ChatRepository, indexer *SearchIndexer, webhooks *WebhookService, hub *websocket.Hub, byChat map[uuid.UUID][]uuid.UUID) int {
+	n := 0
+	for chatID, ids := range byChat {
+		n += len(ids)
+		for _, id := range ids {
+			indexer.MessageDeleted(id)
+		}
+
+		event := map[string]interface{}{
+			"chat_id":     chatID,
+			"message_ids": ids,
+		}
+		webhooks.Publish(chatID, model.EventMessageDeleted, event)
+
+		members, err := chatRepo.GetChatMembers(chatID)
+		if err != nil {
+			log.Printf("error loading members of chat %s: %v", chatID, err)
+			continue
+		}
+		for _, userID := range members {
+			hub.SendToUser(userID, websocket.Message{Type: "message_deleted", Content: event})
+		}
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const maxPinnedMessages = 100
+
+var (
+	ErrMessageNotFound = errors.New("сообщение не найдено")
+	ErrNotPinned       = errors.New("сообщение не закреплено")
+)
+
+type PinService struct {
+	repo     *repository.PinnedMessageRepository
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	sender   *MessageService
+	webhooks *WebhookService
+	hub      *websocket.Hub
+}
+
+func NewPinService(repo *repository.PinnedMessageRepository, messages *repository.MessageRepository, chatRepo *repository.ChatRepository, sender *MessageService, webhooks *WebhookService, hub *websocket.Hub) *PinService {
+	return &PinService{
+		repo:     repo,
+		messages: messages,
+		chatRepo: chatRepo,
+		sender:   sender,
+		webhooks: webhooks,
+		hub:      hub,
+	}
+}
+
+// PinMessage закрепляет сообщение в чате и оставляет системное сообщение о том, кто и что закрепил.
+// В группах закреплять могут администраторы, в личных чатах — оба участника.
+func (s *PinService) PinMessage(chatID, messageID, actorID uuid.UUID) (*model.PinnedMessage, error) {
+	if err := s.checkCanPin(chatID, actorID); err != nil {
+		return nil, err
+	}
+
+	message, err := s.messages.GetMessageByID(messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message == nil || message.ChatID != chatID {
+		return nil, ErrMessageNotFound
+	}
+	if message.Type == model.MessageSystem {
+		return nil, errors.New("системные сообщения нельзя закрепить")
+	}
+
+	n, err := s.repo.Count(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if n >= maxPinnedMessages {
+		return nil, fmt.Errorf("в чате можно закрепить не более %d сообщений", maxPinnedMessages)
+	}
+
+	now := time.Now().UTC()
+	position, err := s.repo.Pin(chatID, messageID, actorID, now)
+	if err != nil {
+		return nil, err
+	}
+	if position == 0 {
+		return nil, errors.New("сообщение уже закреплено")
+	}
+	pin := &model.PinnedMessage{Message: *message, PinnedBy: &actorID, Position: position, PinnedAt: now}
+
+	if _, err := s.sender.SendSystemMessage(chatID, actorID, "закрепил(а) сообщение", &model.SystemAction{
+		Type:      model.ActionMessagePinned,
+		ActorID:   actorID,
+		MessageID: &messageID,
+	}); err != nil {
+		return nil, err
+	}
+
+	s.notify(chatID, model.EventMessagePinned, map[string]interface{}{
+		"chat_id":    chatID,
+		"message_id": messageID,
+		"pinned_by":  actorID,
+		"position":   position,
+	})
+	return pin, nil
+}
+
+func (s *PinService) UnpinMessage(chatID, messageID, actorID uuid.UUID) error {
+	if err := s.checkCanPin(chatID, actorID); err != nil {
+		return err
+	}
+
+	removed, err := s.repo.Unpin(chatID, messageID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotPinned
+	}
+
+	s.notify(chatID, model.EventMessageUnpinned, map[string]interface{}{
+		"chat_id":     chatID,
+		"message_id":  messageID,
+		"unpinned_by": actorID,
+	})
+	return nil
+}
+
+func (s *PinService) ListPins(chatID, userID uuid.UUID) ([]model.PinnedMessage, error) {
+	isMember, err := s.chatRepo.IsChatMember(chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isMember {
+		return nil, errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+	return s.repo.List(chatID)
+}
+
+func (s *PinService) checkCanPin(chatID, userID uuid.UUID) error {
+	isAdmin, err := s.chatRepo.IsChatAdmin(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errors.New("доступ запрещен: закреплять сообщения могут только администраторы чата")
+	}
+	return nil
+}
+
+func (s *PinService) notify(chatID uuid.UUID, event model.WebhookEvent, content interface{}) {
+	members, err := s.chatRepo.GetChatMembers(chatID)
+	if err != nil {
+		log.Printf("error loading members of chat %s: %v", chatID, err)
+		return
+	}
+	for _, memberID := range members {
+		s.hub.SendToUser(memberID, websocket.Message{Type: string(event), Content: content})
+	}
+	s.webhooks.Publish(chatID, event, content)
+}
@@ -0,0 +1,270 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 10
+	maxPollQuestionLen  = 300
+	maxPollOptionLen    = 100
+	maxPollOpenDuration = 30 * 24 * time.Hour
+)
+
+var (
+	ErrPollNotFound = errors.New("опрос не найден")
+	ErrPollClosed   = errors.New("опрос закрыт")
+)
+
+type PollService struct {
+	repo     *repository.PollRepository
+	messages *repository.MessageRepository
+	chatRepo *repository.ChatRepository
+	sender   *MessageService
+	hub      *websocket.Hub
+}
+
+func NewPollService(repo *repository.PollRepository, messages *repository.MessageRepository, chatRepo *repository.ChatRepository, sender *MessageService, hub *websocket.Hub) *PollService {
+	return &PollService{
+		repo:     repo,
+		messages: messages,
+		chatRepo: chatRepo,
+		sender:   sender,
+		hub:      hub,
+	}
+}
+
+// CreatePoll отправляет в групповой чат сообщение с опросом. Вопрос дублируется в Content,
+// чтобы опрос находился поиском и был виден в списке чатов.
+func (s *PollService) CreatePoll(chatID, senderID uuid.UUID, poll *model.Poll) (*model.Message, error) {
+	chat, err := s.chatRepo.GetByID(chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, errors.New("чат не существует")
+	}
+	if chat.Type != model.TypeGroup {
+		return nil, errors.New("опросы доступны только в групповых чатах")
+	}
+	if err := validatePoll(poll, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+
+	message := &model.Message{
+		ChatID:   chatID,
+		SenderID: senderID,
+		Content:  poll.Question,
+		Type:     model.MessagePoll,
+		Poll:     poll,
+	}
+	if err := s.sender.SendMessage(message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func validatePoll(p *model.Poll, now time.Time) error {
+	p.Question = strings.TrimSpace(p.Question)
+	if p.Question == "" {
+		return errors.New("вопрос опроса не может быть пустым")
+	}
+	if utf8.RuneCountInString(p.Question) > maxPollQuestionLen {
+		return fmt.Errorf("вопрос опроса длиннее %d символов", maxPollQuestionLen)
+	}
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("в опросе должно быть от %d до %d вариантов", minPollOptions, maxPollOptions)
+	}
+	seen := make(map[string]bool, len(p.Options))
+	for i := range p.Options {
+		text := strings.TrimSpace(p.Options[i].Text)
+		if text == "" {
+			return errors.New("вариант ответа не может быть пустым")
+		}
+		if utf8.RuneCountInString(text) > maxPollOptionLen {
+			return fmt.Errorf("вариант ответа длиннее %d символов", maxPollOptionLen)
+		}
+		if seen[text] {
+			return errors.New("варианты ответа повторяются")
+		}
+		seen[text] = true
+		p.Options[i] = model.PollOption{Text: text}
+	}
+
+	if p.Quiz {
+		if p.Multiple {
+			return errors.New("в викторине можно выбрать только один вариант")
+		}
+		if p.CorrectOption == nil || *p.CorrectOption < 0 || *p.CorrectOption >= len(p.Options) {
+			return errors.New("в викторине нужно указать правильный вариант")
+		}
+	} else {
+		p.CorrectOption = nil
+	}
+
+	if p.ClosesAt != nil {
+		closesAt := p.ClosesAt.UTC()
+		if !closesAt.After(now) {
+			return errors.New("время закрытия опроса должно быть в будущем")
+		}
+		if closesAt.Sub(now) > maxPollOpenDuration {
+			return errors.New("опрос можно открыть не более чем на 30 дней")
+		}
+		p.ClosesAt = &closesAt
+	}
+	p.Closed, p.TotalVoters, p.Chosen = false, 0, nil
+	return nil
+}
+
+// Vote заменяет голос пользователя вариантами options. В викторине ответ дается один раз.
+func (s *PollService) Vote(messageID, userID uuid.UUID, options []int) (*model.Poll, error) {
+	message, poll, err := s.load(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Closed {
+		return nil, ErrPollClosed
+	}
+	if poll.Quiz && len(poll.Chosen) > 0 {
+		return nil, errors.New("ответ в викторине нельзя изменить")
+	}
+	if len(options) == 0 {
+		return nil, errors.New("нужно выбрать вариант ответа")
+	}
+	if len(options) > 1 && !poll.Multiple {
+		return nil, errors.New("в этом опросе можно выбрать только один вариант")
+	}
+	chosen := make(map[int]bool, len(options))
+	for _, o := range options {
+		if o < 0 || o >= len(poll.Options) {
+			return nil, errors.New("такого варианта ответа нет")
+		}
+		if chosen[o] {
+			return nil, errors.New("варианты ответа повторяются")
+		}
+		chosen[o] = true
+	}
+
+	if err := s.repo.Vote(messageID, userID, options, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s.publish(message, userID)
+}
+
+func (s *PollService) Retract(messageID, userID uuid.UUID) (*model.Poll, error) {
+	message, poll, err := s.load(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Closed {
+		return nil, ErrPollClosed
+	}
+	if poll.Quiz {
+		return nil, errors.New("ответ в викторине нельзя отозвать")
+	}
+
+	removed, err := s.repo.Retract(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !removed {
+		return nil, errors.New("вы не голосовали в этом опросе")
+	}
+	return s.publish(message, userID)
+}
+
+// Close досрочно закрывает опрос. Закрыть его может автор или администратор чата.
+func (s *PollService) Close(messageID, userID uuid.UUID) (*model.Poll, error) {
+	message, poll, err := s.load(messageID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if poll.Closed {
+		return nil, ErrPollClosed
+	}
+	if message.SenderID != userID {
+		isAdmin, err := s.chatRepo.IsChatAdmin(message.ChatID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !isAdmin {
+			return nil, errors.New("доступ запрещен: закрыть опрос может автор или администратор чата")
+		}
+	}
+
+	if err := s.repo.Close(messageID, time.Now().UTC()); err != nil {
+		return nil, err
+	}
+	return s.publish(message, userID)
+}
+
+// load возвращает сообщение с опросом и результаты глазами userID, проверяя членство в чате
+func (s *PollService) load(messageID, userID uuid.UUID) (*model.Message, *model.Poll, error) {
+	message, err := s.messages.GetMessageByID(messageID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if message == nil || message.Type != model.MessagePoll {
+		return nil, nil, ErrPollNotFound
+	}
+	isMember, err := s.chatRepo.IsChatMember(message.ChatID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !isMember {
+		return nil, nil, errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+
+	polls, err := s.repo.Results([]uuid.UUID{messageID}, userID, time.Now().UTC())
+	if err != nil {
+		return nil, nil, err
+	}
+	poll, ok := polls[messageID]
+	if !ok {
+		return nil, nil, ErrPollNotFound
+	}
+	return message, poll, nil
+}
+
+// publish рассылает участникам обновленные результаты и возвращает их глазами userID
+func (s *PollService) publish(message *model.Message, userID uuid.UUID) (*model.Poll, error) {
+	polls, err := s.repo.Results([]uuid.UUID{message.ID}, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	poll, ok := polls[message.ID]
+	if !ok {
+		return nil, ErrPollNotFound
+	}
+
+	members, err := s.chatRepo.GetChatMembers(message.ChatID)
+	if err != nil {
+		log.Printf("error loading members of chat %s: %v", message.ChatID, err)
+	} else {
+		event := websocket.Message{
+			Type: "poll_updated",
+			Content: map[string]interface{}{
+				"chat_id":    message.ChatID,
+				"message_id": message.ID,
+				"poll":       poll.Shared(),
+			},
+		}
+		for _, memberID := range members {
+			s.hub.SendToUser(memberID, event)
+		}
+	}
+
+	poll.ForViewer()
+	return poll, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PresenceService хранит время последнего визита и применяет настройки приватности
+// к онлайн-статусу: и в ответах REST, и в событиях user_status
+type PresenceService struct {
+	userRepo    *repository.UserRepository
+	chatRepo    *repository.ChatRepository
+	contactRepo *repository.ContactRepository
+	hub         *websocket.Hub
+}
+
+func NewPresenceService(userRepo *repository.UserRepository, chatRepo *repository.ChatRepository, contactRepo *repository.ContactRepository, hub *websocket.Hub) *PresenceService {
+	return &PresenceService{userRepo: userRepo, chatRepo: chatRepo, contactRepo: contactRepo, hub: hub}
+}
+
+// PresenceAudience реализует websocket.PresenceTracker. Заблокированные пользователи
+// не видят статус никогда, контакты — это те, кого пользователь сам сохранил в контакты.
+func (s *PresenceService) PresenceAudience(userID uuid.UUID) (func(uuid.UUID) bool, error) {
+	privacy, err := s.userRepo.GetPrivacy(userID)
+	if err != nil {
+		return nil, err
+	}
+	if privacy.LastSeen == model.VisibilityNobody {
+		return func(uuid.UUID) bool { return false }, nil
+	}
+
+	blocked, err := s.idSet(s.contactRepo.GetBlockedIDs(userID))
+	if err != nil {
+		return nil, err
+	}
+	if privacy.LastSeen == model.VisibilityEveryone {
+		return func(viewerID uuid.UUID) bool { return !blocked[viewerID] }, nil
+	}
+
+	contacts, err := s.idSet(s.contactRepo.GetContactIDs(userID))
+	if err != nil {
+		return nil, err
+	}
+	return func(viewerID uuid.UUID) bool { return contacts[viewerID] && !blocked[viewerID] }, nil
+}
+
+// PresencePeers реализует websocket.PresenceTracker: статусы собеседников по чатам приходят автоматически
+func (s *PresenceService) PresencePeers(userID uuid.UUID) ([]uuid.UUID, error) {
+	return s.chatRepo.GetChatPeerIDs(userID)
+}
+
+// UserDisconnected реализует websocket.PresenceTracker
+func (s *PresenceService) UserDisconnected(userID uuid.UUID, at time.Time) {
+	if err := s.userRepo.SetLastSeen(userID, at); err != nil {
+		log.Printf("error saving last seen of %s: %v", userID, err)
+	}
+}
+
+// CanSee проверяет, может ли viewerID видеть статус пользователя с настройкой privacy
+func (s *PresenceService) CanSee(viewerID, userID uuid.UUID, privacy model.Visibility) (bool, error) {
+	if viewerID == userID {
+		return true, nil
+	}
+	if privacy == model.VisibilityNobody {
+		return false, nil
+	}
+	blocked, err := s.contactRepo.IsBlocked(userID, viewerID)
+	if err != nil || blocked {
+		return false, err
+	}
+	if privacy == model.VisibilityContacts {
+		return s.contactRepo.IsContact(userID, viewerID)
+	}
+	return true, nil
+}
+
+// Visible возвращает статус и время последнего визита так, как их должен увидеть viewerID
+func (s *PresenceService) Visible(viewerID, userID uuid.UUID, privacy model.Visibility, lastSeenAt *time.Time) (string, *time.Time, error) {
+	ok, err := s.CanSee(viewerID, userID, privacy)
+	if err != nil || !ok {
+		return "", nil, err
+	}
+	status := s.hub.UserStatus(userID)
+	if status != websocket.StatusOffline {
+		return status, nil, nil
+	}
+	return status, lastSeenAt, nil
+}
+
+func (s *PresenceService) idSet(ids []uuid.UUID, err error) (map[uuid.UUID]bool, error) {
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxScheduledPerChat  = 100
+	maxScheduleAhead     = 365 * 24 * time.Hour
+	schedulerInterval    = time.Second
+	schedulerBatchSize   = 100
+	scheduledClaimLease  = time.Minute
+	maxScheduledAttempts = 5
+)
+
+var ErrScheduledMessageNotFound = errors.New("отложенное сообщение не найдено или уже отправляется")
+
+type ScheduledMessageService struct {
+	repo     *repository.ScheduledMessageRepository
+	chatRepo *repository.ChatRepository
+	messages *MessageService
+	hub      *websocket.Hub
+}
+
+func NewScheduledMessageService(repo *repository.ScheduledMessageRepository, chatRepo *repository.ChatRepository, messages *MessageService, hub *websocket.Hub) *ScheduledMessageService {
+	return &ScheduledMessageService{
+		repo:     repo,
+		chatRepo: chatRepo,
+		messages: messages,
+		hub:      hub,
+	}
+}
+
+// Schedule откладывает отправку сообщения до m.SendAt
+func (s *ScheduledMessageService) Schedule(m *model.ScheduledMessage) error {
+	now := time.Now().UTC()
+	if m.Format == "" {
+		m.Format = model.FormatPlain
+	}
+	if err := validateScheduled(m.Content, m.Format, m.SendAt, now); err != nil {
+		return err
+	}
+	m.SendAt = m.SendAt.UTC()
+
+	isMember, err := s.chatRepo.IsChatMember(m.ChatID, m.SenderID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return errors.New("доступ запрещен: вы не являетесь участником этого чата")
+	}
+	n, err := s.repo.CountPending(m.SenderID, m.ChatID)
+	if err != nil {
+		return err
+	}
+	if n >= maxScheduledPerChat {
+		return fmt.Errorf("в чате можно отложить не более %d сообщений", maxScheduledPerChat)
+	}
+
+	m.CreatedAt = now
+	return s.repo.Create(m)
+}
+
+func (s *ScheduledMessageService) List(senderID uuid.UUID, chatID *uuid.UUID) ([]model.ScheduledMessage, error) {
+	return s.repo.List(senderID, chatID)
+}
+
+func (s *ScheduledMessageService) Update(id, senderID uuid.UUID, upd *model.ScheduledMessageUpdate) (*model.ScheduledMessage, error) {
+	now := time.Now().UTC()
+	if upd.Content != nil && strings.TrimSpace(*upd.Content) == "" {
+		return nil, errors.New("сообщение не может быть пустым")
+	}
+	if upd.Format != nil && !upd.Format.IsValid() {
+		return nil, errors.New("неизвестный формат текста")
+	}
+	if upd.SendAt != nil {
+		t := upd.SendAt.UTC()
+		if err := validateSendAt(t, now); err != nil {
+			return nil, err
+		}
+		upd.SendAt = &t
+	}
+
+	m, err := s.repo.Update(id, senderID, upd, now)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrScheduledMessageNotFound
+	}
+	return m, nil
+}
+
+func (s *ScheduledMessageService) Cancel(id, senderID uuid.UUID) error {
+	cancelled, err := s.repo.Cancel(id, senderID)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return ErrScheduledMessageNotFound
+	}
+	return nil
+}
+
+// Run раз в секунду отправляет сообщения, время которых пришло. Очередь хранится в базе,
+// поэтому переживает перезапуск, а несколько экземпляров сервера делят ее без повторов.
+func (s *ScheduledMessageService) Run() {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for {
+			now := time.Now().UTC()
+			due, err := s.repo.ClaimDue(now, now.Add(-scheduledClaimLease), schedulerBatchSize)
+			if err != nil {
+				log.Printf("error claiming scheduled messages: %v", err)
+				break
+			}
+			for i := range due {
+				s.send(&due[i])
+			}
+			if len(due) < schedulerBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// send отправляет сообщение обычным путем MessageService.SendMessage со всеми проверками прав.
+// Сообщение удаляется из очереди в одной транзакции с отправкой, поэтому отправляется один раз,
+// даже если аренда истекла и его взяла другая попытка.
+func (s *ScheduledMessageService) send(scheduled *model.ScheduledMessage) {
+	message := model.Message{
+		ChatID:    scheduled.ChatID,
+		SenderID:  scheduled.SenderID,
+		Content:   scheduled.Content,
+		Format:    scheduled.Format,
+		Scheduled: &model.ScheduledSend{ID: scheduled.ID, Attempt: scheduled.Attempts},
+	}
+	if err := s.messages.SendMessage(&message); err != nil {
+		if errors.Is(err, repository.ErrScheduledClaimLost) {
+			return
+		}
+		failed := scheduled.Attempts >= maxScheduledAttempts
+		log.Printf("error sending scheduled message %s (attempt %d): %v", scheduled.ID, scheduled.Attempts, err)
+		if err := s.repo.RecordFailure(scheduled.ID, scheduled.Attempts, err.Error(), failed, time.Now().UTC()); err != nil {
+			log.Printf("error recording failure of scheduled message %s: %v", scheduled.ID, err)
+		}
+		if failed {
+			s.hub.SendToUser(scheduled.SenderID, websocket.Message{
+				Type: "scheduled_message_failed",
+				Content: map[string]interface{}{
+					"id":      scheduled.ID,
+					"chat_id": scheduled.ChatID,
+					"error":   err.Error(),
+				},
+			})
+		}
+		return
+	}
+
+	s.hub.SendToUser(scheduled.SenderID, websocket.Message{
+		Type: "scheduled_message_sent",
+		Content: map[string]interface{}{
+			"id":         scheduled.ID,
+			"chat_id":    scheduled.ChatID,
+			"message_id": message.ID,
+		},
+	})
+}
+
+func validateScheduled(content string, format model.TextFormat, sendAt, now time.Time) error {
+	if strings.TrimSpace(content) == "" {
+		return errors.New("сообщение не может быть пустым")
+	}
+	if !format.IsValid() {
+		return errors.New("неизвестный формат текста")
+	}
+	return validateSendAt(sendAt, now)
+}
+
+func validateSendAt(sendAt, now time.Time) error {
+	if !sendAt.After(now) {
+		return errors.New("время отправки должно быть в будущем")
+	}
+	if sendAt.After(now.Add(maxScheduleAhead)) {
+		return errors.New("отложить сообщение можно не более чем на год")
+	}
+	return nil
+}
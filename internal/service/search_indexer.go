@@ -0,0 +1,106 @@
+package service
+
+import (
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/search"
+
+	"github.com/google/uuid"
+)
+
+const (
+	indexQueueSize = 10000
+	indexBatchSize = 200
+)
+
+type indexOp struct {
+	doc    model.SearchDocument
+	delete bool
+}
+
+// SearchIndexer асинхронно переносит изменения сообщений в поисковый индекс,
+// чтобы индексация не задерживала отправку сообщений
+type SearchIndexer struct {
+	index search.Index
+	ops   chan indexOp
+}
+
+func NewSearchIndexer(index search.Index) *SearchIndexer {
+	return &SearchIndexer{
+		index: index,
+		ops:   make(chan indexOp, indexQueueSize),
+	}
+}
+
+func (i *SearchIndexer) MessageCreated(m *model.Message) {
+	i.enqueue(indexOp{doc: searchDocument(m)})
+}
+
+func (i *SearchIndexer) MessageDeleted(id uuid.UUID) {
+	i.enqueue(indexOp{doc: model.SearchDocument{ID: id}, delete: true})
+}
+
+// Run применяет накопившиеся изменения пачками
+func (i *SearchIndexer) Run() {
+	for op := range i.ops {
+		batch := []indexOp{op}
+	drain:
+		for len(batch) < indexBatchSize {
+			select {
+			case next := <-i.ops:
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		i.apply(batch)
+	}
+}
+
+func (i *SearchIndexer) apply(batch []indexOp) {
+	// Подряд идущие операции одного типа отправляются в индекс одним вызовом
+	for start := 0; start < len(batch); {
+		end := start + 1
+		for end < len(batch) && batch[end].delete == batch[start].delete {
+			end++
+		}
+
+		var err error
+		if batch[start].delete {
+			ids := make([]uuid.UUID, 0, end-start)
+			for _, op := range batch[start:end] {
+				ids = append(ids, op.doc.ID)
+			}
+			err = i.index.Delete(ids...)
+		} else {
+			docs := make([]model.SearchDocument, 0, end-start)
+			for _, op := range batch[start:end] {
+				docs = append(docs, op.doc)
+			}
+			err = i.index.Index(docs...)
+		}
+		if err != nil {
+			log.Printf("error updating search index: %v", err)
+		}
+		start = end
+	}
+}
+
+func (i *SearchIndexer) enqueue(op indexOp) {
+	select {
+	case i.ops <- op:
+	default:
+		// Индекс догонится командой reindex; блокировать отправку сообщений нельзя
+		log.Printf("search index queue is full, dropping update for message %s", op.doc.ID)
+	}
+}
+
+func searchDocument(m *model.Message) model.SearchDocument {
+	return model.SearchDocument{
+		ID:        m.ID,
+		ChatID:    m.ChatID,
+		SenderID:  m.SenderID,
+		Content:   m.Content,
+		CreatedAt: m.CreatedAt,
+	}
+}
@@ -1,20 +1,64 @@
 package service
 
 import (
+	"bytes"
+	"database/sql"
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"messenger/internal/model"
 	"messenger/internal/repository"
+	"messenger/internal/service/websocket"
+	"net/http"
+	"path"
 	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	MaxAvatarSize = 5 << 20
+
+	maxDisplayNameLength = 64
+	maxBioLength         = 500
+	maxStatusTextLength  = 100
+)
+
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// BlobStore — хранилище файлов, в котором лежат аватары и архивы выгрузок
+type BlobStore interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadSeekCloser, error)
+	Delete(key string) error
+}
+
+var (
+	ErrUserNotFound     = errors.New("пользователь не найден")
+	ErrAccountSuspended = errors.New("аккаунт заблокирован")
+	ErrSessionRevoked   = errors.New("сессия отозвана")
+)
+
 type UserService struct {
-	repo *repository.UserRepository
+	repo     *repository.UserRepository
+	chatRepo *repository.ChatRepository
+	blobs    BlobStore
+	presence *PresenceService
+	hub      *websocket.Hub
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo *repository.UserRepository, chatRepo *repository.ChatRepository, blobs BlobStore, presence *PresenceService, hub *websocket.Hub) *UserService {
+	return &UserService{repo: repo, chatRepo: chatRepo, blobs: blobs, presence: presence, hub: hub}
 }
 
 func (s *UserService) CreateUser(u *model.User) error {
@@ -55,23 +99,260 @@ func (s *UserService) LoginUser(email, password string) (*model.User, error) {
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
+	if user == nil || user.IsBot {
 		return nil, errors.New("неверные учетные данные электронной почты или пароль")
 	}
 
 	if !checkPasswordHash(password, user.Password) {
 		return nil, errors.New("неверные учетные данные электронной почты или пароль")
 	}
+	// Блокировка проверяется после пароля, чтобы по ответу нельзя было узнать, чей аккаунт заблокирован
+	if user.SuspendedAt != nil {
+		return nil, ErrAccountSuspended
+	}
 
 	user.Password = ""
 	return user, nil
 }
 
+// Authorize проверяет, что аккаунт может работать с API, и возвращает его глобальную роль.
+// issuedAt — время выдачи сессии; nil для персональных токенов, которые отзываются отдельно.
+func (s *UserService) Authorize(userID uuid.UUID, issuedAt *time.Time) (model.UserRole, error) {
+	access, err := s.repo.GetAccess(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+		return "", err
+	}
+	if access.Deleted {
+		return "", ErrUserNotFound
+	}
+	if access.Suspended {
+		return "", ErrAccountSuspended
+	}
+	// Время выдачи JWT хранится с точностью до секунды
+	if issuedAt != nil && access.SessionsValidAfter != nil && issuedAt.Before(access.SessionsValidAfter.Truncate(time.Second)) {
+		return "", ErrSessionRevoked
+	}
+	return access.Role, nil
+}
+
 func (s *UserService) SearchUsers(username string) ([]model.User, error) {
 	if len(username) < 3 {
 		return nil, errors.New("поисковый запрос должен содержать не менее 3 символов")
 	}
-	return s.repo.SearchByUsername(username)
+	users, err := s.repo.SearchByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		s.prepareProfile(&users[i], users[i].ID)
+	}
+	return users, nil
+}
+
+// GetProfile возвращает профиль пользователя с учетом того, кто его запрашивает
+func (s *UserService) GetProfile(viewerID, userID uuid.UUID) (*model.User, error) {
+	user, err := s.repo.GetById(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	s.prepareProfile(user, viewerID)
+
+	user.Status, user.LastSeenAt, err = s.presence.Visible(viewerID, user.ID, user.LastSeenPrivacy, user.LastSeenAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) GetPrivacy(userID uuid.UUID) (*model.PrivacySettings, error) {
+	return s.repo.GetPrivacy(userID)
+}
+
+// UpdatePrivacy меняет переданные настройки приватности; незаполненные поля остаются прежними
+func (s *UserService) UpdatePrivacy(userID uuid.UUID, settings *model.PrivacySettings) (*model.PrivacySettings, error) {
+	current, err := s.repo.GetPrivacy(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range []struct {
+		name   string
+		value  model.Visibility
+		target *model.Visibility
+	}{
+		{"last_seen", settings.LastSeen, &current.LastSeen},
+		{"private_chats", settings.PrivateChats, &current.PrivateChats},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if !f.value.IsValid() {
+			return nil, fmt.Errorf("недопустимое значение %s: %s", f.name, f.value)
+		}
+		*f.target = f.value
+	}
+
+	if err := s.repo.UpdatePrivacy(userID, current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+func (s *UserService) UpdateProfile(userID uuid.UUID, upd *model.ProfileUpdate) (*model.User, error) {
+	if err := validateProfileUpdate(upd); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.UpdateProfile(userID, upd)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyProfileUpdated(user)
+	s.prepareProfile(user, userID)
+	return user, nil
+}
+
+// SetAvatar сохраняет новый аватар; принимаются PNG, JPEG, GIF и WebP размером до MaxAvatarSize
+func (s *UserService) SetAvatar(userID uuid.UUID, r io.Reader) (*model.User, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxAvatarSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxAvatarSize {
+		return nil, fmt.Errorf("размер аватара не должен превышать %d МБ", MaxAvatarSize>>20)
+	}
+	ext, ok := avatarExtensions[http.DetectContentType(data)]
+	if !ok {
+		return nil, errors.New("аватар должен быть изображением PNG, JPEG, GIF или WebP")
+	}
+
+	// Каждый аватар получает новый ключ, поэтому старые ссылки можно кешировать бессрочно
+	key := path.Join("avatars", userID.String(), uuid.NewString()+ext)
+	if err := s.blobs.Put(key, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return s.replaceAvatar(userID, &key)
+}
+
+func (s *UserService) DeleteAvatar(userID uuid.UUID) (*model.User, error) {
+	return s.replaceAvatar(userID, nil)
+}
+
+// OpenAvatar открывает файл аватара пользователя; ErrUserNotFound, если аватара нет
+func (s *UserService) OpenAvatar(userID uuid.UUID) (io.ReadSeekCloser, error) {
+	user, err := s.repo.GetById(userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	if user.AvatarKey == nil {
+		return nil, ErrUserNotFound
+	}
+	return s.blobs.Open(*user.AvatarKey)
+}
+
+func (s *UserService) replaceAvatar(userID uuid.UUID, key *string) (*model.User, error) {
+	oldKey, err := s.repo.SetAvatar(userID, key)
+	if err != nil {
+		return nil, err
+	}
+	if oldKey != nil {
+		if err := s.blobs.Delete(*oldKey); err != nil {
+			log.Printf("error deleting old avatar %s: %v", *oldKey, err)
+		}
+	}
+
+	user, err := s.repo.GetById(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyProfileUpdated(user)
+	s.prepareProfile(user, userID)
+	return user, nil
+}
+
+// notifyProfileUpdated рассылает публичный профиль всем, у кого есть общий чат с пользователем
+func (s *UserService) notifyProfileUpdated(user *model.User) {
+	peers, err := s.chatRepo.GetChatPeerIDs(user.ID)
+	if err != nil {
+		log.Printf("error loading chat peers of %s: %v", user.ID, err)
+		return
+	}
+
+	public := *user
+	s.prepareProfile(&public, uuid.Nil)
+	for _, peerID := range append(peers, user.ID) {
+		s.hub.SendToUser(peerID, websocket.Message{
+			Type:    "profile_updated",
+			Content: public,
+		})
+	}
+}
+
+// prepareProfile заполняет вычисляемые поля и скрывает то, что видно только владельцу профиля
+func (s *UserService) prepareProfile(user *model.User, viewerID uuid.UUID) {
+	user.Password = ""
+	// Статус и последний визит заполняются отдельно с учетом настроек приватности
+	user.Status, user.LastSeenAt = "", nil
+	if user.ID != viewerID {
+		user.Email = ""
+		user.DeletionScheduledAt = nil
+		user.Role = ""
+		user.SuspendedAt, user.SuspendReason = nil, nil
+	}
+	if user.StatusExpiresAt != nil && !user.StatusExpiresAt.After(time.Now()) {
+		user.StatusText, user.StatusExpiresAt = nil, nil
+	}
+	user.AvatarURL = nil
+	if user.AvatarKey != nil {
+		u := fmt.Sprintf("/api/users/%s/avatar?v=%s", user.ID, strings.TrimSuffix(path.Base(*user.AvatarKey), path.Ext(*user.AvatarKey)))
+		user.AvatarURL = &u
+	}
+}
+
+func validateProfileUpdate(upd *model.ProfileUpdate) error {
+	trim := func(v *string) {
+		if v != nil {
+			*v = strings.TrimSpace(*v)
+		}
+	}
+	trim(upd.DisplayName)
+	trim(upd.Bio)
+	trim(upd.StatusText)
+	trim(upd.TimeZone)
+
+	if upd.DisplayName != nil && utf8.RuneCountInString(*upd.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("отображаемое имя не должно быть длиннее %d символов", maxDisplayNameLength)
+	}
+	if upd.Bio != nil && utf8.RuneCountInString(*upd.Bio) > maxBioLength {
+		return fmt.Errorf("описание профиля не должно быть длиннее %d символов", maxBioLength)
+	}
+	if upd.StatusText != nil {
+		if utf8.RuneCountInString(*upd.StatusText) > maxStatusTextLength {
+			return fmt.Errorf("статус не должен быть длиннее %d символов", maxStatusTextLength)
+		}
+		if upd.StatusExpiresAt != nil {
+			if !upd.StatusExpiresAt.After(time.Now()) {
+				return errors.New("время окончания статуса должно быть в будущем")
+			}
+			// Колонка без часового пояса, поэтому время хранится в UTC
+			t := upd.StatusExpiresAt.UTC()
+			upd.StatusExpiresAt = &t
+		}
+	}
+	if upd.TimeZone != nil && *upd.TimeZone != "" {
+		if _, err := time.LoadLocation(*upd.TimeZone); err != nil || *upd.TimeZone == "Local" {
+			return fmt.Errorf("неизвестный часовой пояс: %s", *upd.TimeZone)
+		}
+	}
+	return nil
 }
 
 func hash(password string) (string, error) {
@@ -0,0 +1,337 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"messenger/internal/model"
+	"messenger/internal/netguard"
+	"messenger/internal/repository"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	webhookBatchSize       = 50
+	webhookPollInterval    = 5 * time.Second
+	webhookLease           = time.Minute // сколько доставка считается занятой отправителем, с запасом над webhookTimeout
+	webhookMaxAttempts     = 8
+	webhookBaseBackoff     = 10 * time.Second
+	webhookMaxBackoff      = 6 * time.Hour
+	webhookDisableAfter    = 20 // неудачных доставок подряд до отключения вебхука
+	webhookDeliveryLogKeep = 7 * 24 * time.Hour
+)
+
+// webhookEnvelope — тело запроса, которое получает вебхук
+type webhookEnvelope struct {
+	Event     model.WebhookEvent `json:"event"`
+	ChatID    uuid.UUID          `json:"chat_id"`
+	Timestamp time.Time          `json:"timestamp"`
+	Data      interface{}        `json:"data"`
+}
+
+type WebhookService struct {
+	repo     *repository.WebhookRepository
+	chatRepo *repository.ChatRepository
+	wake     chan struct{}
+
+	// Вебхуки чатов задают пользователи, поэтому они доставляются только на публичные адреса.
+	// Глобальные вебхуки задает администратор сервера, им разрешена и внутренняя сеть.
+	// Редиректы не выполняются ни для тех, ни для других.
+	client       *http.Client
+	globalClient *http.Client
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, chatRepo *repository.ChatRepository) *WebhookService {
+	return &WebhookService{
+		repo:     repo,
+		chatRepo: chatRepo,
+		wake:     make(chan struct{}, 1),
+		client:   netguard.NewClient(netguard.Config{Timeout: webhookTimeout}),
+		globalClient: &http.Client{
+			Timeout: webhookTimeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// SyncGlobal приводит глобальные вебхуки в базе к списку из конфигурации
+func (s *WebhookService) SyncGlobal(urls []string, secret string) error {
+	if len(urls) > 0 && secret == "" {
+		return errors.New("для глобальных вебхуков нужен секрет подписи")
+	}
+	for _, u := range urls {
+		if err := validateWebhookURL(u); err != nil {
+			return fmt.Errorf("%s: %w", u, err)
+		}
+		if err := s.repo.UpsertGlobal(u, secret); err != nil {
+			return err
+		}
+	}
+	return s.repo.DeleteGlobalExcept(urls)
+}
+
+// CreateWebhook подписывает адрес на события чата; доступно только администраторам чата
+func (s *WebhookService) CreateWebhook(chatID, actorID uuid.UUID, webhookURL string, events []model.WebhookEvent) (*model.Webhook, error) {
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if !isKnownWebhookEvent(e) {
+			return nil, fmt.Errorf("неизвестное событие: %s", e)
+		}
+	}
+
+	secret, err := newSecretToken("whsec_")
+	if err != nil {
+		return nil, err
+	}
+
+	w := &model.Webhook{
+		ChatID:    &chatID,
+		CreatedBy: &actorID,
+		URL:       webhookURL,
+		Secret:    secret,
+		Events:    events,
+	}
+	if w.Events == nil {
+		w.Events = []model.WebhookEvent{}
+	}
+	if err := s.repo.Create(w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *WebhookService) ListWebhooks(chatID, actorID uuid.UUID) ([]model.Webhook, error) {
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return nil, err
+	}
+	webhooks, err := s.repo.ListByChat(chatID)
+	if err != nil {
+		return nil, err
+	}
+	// Секрет показывается только при создании
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) DeleteWebhook(chatID, webhookID, actorID uuid.UUID) error {
+	if _, err := s.getChatWebhook(chatID, webhookID, actorID); err != nil {
+		return err
+	}
+	return s.repo.Delete(webhookID)
+}
+
+func (s *WebhookService) EnableWebhook(chatID, webhookID, actorID uuid.UUID) error {
+	if _, err := s.getChatWebhook(chatID, webhookID, actorID); err != nil {
+		return err
+	}
+	return s.repo.Enable(webhookID)
+}
+
+func (s *WebhookService) ListDeliveries(chatID, webhookID, actorID uuid.UUID) ([]model.WebhookDelivery, error) {
+	if _, err := s.getChatWebhook(chatID, webhookID, actorID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListDeliveries(webhookID, 100)
+}
+
+// Publish ставит событие чата в очередь доставки подписанным вебхукам.
+// Ошибки только логируются: вебхуки не должны ломать основной сценарий.
+func (s *WebhookService) Publish(chatID uuid.UUID, event model.WebhookEvent, data interface{}) {
+	payload, err := json.Marshal(webhookEnvelope{
+		Event:     event,
+		ChatID:    chatID,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("error marshaling webhook event %s: %v", event, err)
+		return
+	}
+
+	n, err := s.repo.EnqueueEvent(chatID, event, payload)
+	if err != nil {
+		log.Printf("error queuing webhook event %s: %v", event, err)
+		return
+	}
+	if n > 0 {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run доставляет накопившиеся события. Очередь хранится в базе, поэтому доставки переживают
+// перезапуск. Пачка отправляется параллельно и успевает до конца аренды; если реплика все же
+// не успела, доставка повторится (получатель различает повторы по X-Messenger-Delivery),
+// а результат устаревшей попытки не запишется.
+func (s *WebhookService) Run() {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-s.wake:
+		case <-ticker.C:
+		case <-cleanup.C:
+			if err := s.repo.DeleteDeliveredBefore(time.Now().Add(-webhookDeliveryLogKeep)); err != nil {
+				log.Printf("error cleaning webhook delivery log: %v", err)
+			}
+			continue
+		}
+
+		for {
+			deliveries, err := s.repo.ClaimDue(webhookBatchSize, webhookLease)
+			if err != nil {
+				log.Printf("error claiming webhook deliveries: %v", err)
+				break
+			}
+			var wg sync.WaitGroup
+			for i := range deliveries {
+				wg.Add(1)
+				go func(d *model.WebhookDelivery) {
+					defer wg.Done()
+					s.deliver(d)
+				}(&deliveries[i])
+			}
+			wg.Wait()
+			if len(deliveries) < webhookBatchSize {
+				break
+			}
+		}
+	}
+}
+
+func (s *WebhookService) deliver(d *model.WebhookDelivery) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		s.fail(d, nil, err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Messenger-Event", string(d.Event))
+	req.Header.Set("X-Messenger-Delivery", strconv.FormatInt(d.ID, 10))
+	req.Header.Set("X-Messenger-Timestamp", timestamp)
+	req.Header.Set("X-Messenger-Signature", "sha256="+SignWebhookPayload(d.Secret, timestamp, d.Payload))
+
+	client := s.client
+	if d.Global {
+		client = s.globalClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		s.fail(d, nil, err.Error())
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.fail(d, &resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+		return
+	}
+	if err := s.repo.MarkDelivered(d, resp.StatusCode); err != nil {
+		log.Printf("error marking webhook delivery %d as delivered: %v", d.ID, err)
+	}
+}
+
+func (s *WebhookService) fail(d *model.WebhookDelivery, responseStatus *int, lastError string) {
+	var retryAt *time.Time
+	if d.Attempts < webhookMaxAttempts {
+		t := time.Now().Add(webhookBackoff(d.Attempts))
+		retryAt = &t
+	}
+
+	disabled, err := s.repo.MarkFailed(d, responseStatus, lastError, retryAt, webhookDisableAfter)
+	if err != nil {
+		log.Printf("error marking webhook delivery %d as failed: %v", d.ID, err)
+		return
+	}
+	if disabled {
+		log.Printf("webhook %s disabled after %d consecutive failures", d.WebhookID, webhookDisableAfter)
+	}
+}
+
+// SignWebhookPayload считает HMAC-SHA256 от "timestamp.body"; получатель проверяет
+// подпись тем же секретом и отбрасывает запросы со старым timestamp
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff — экспоненциальная задержка перед следующей попыткой
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookBaseBackoff << (attempts - 1)
+	if d <= 0 || d > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return d
+}
+
+func (s *WebhookService) getChatWebhook(chatID, webhookID, actorID uuid.UUID) (*model.Webhook, error) {
+	if err := s.requireAdmin(chatID, actorID); err != nil {
+		return nil, err
+	}
+	w, err := s.repo.GetByID(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if w == nil || w.ChatID == nil || *w.ChatID != chatID {
+		return nil, errors.New("вебхук не найден")
+	}
+	return w, nil
+}
+
+func (s *WebhookService) requireAdmin(chatID, userID uuid.UUID) error {
+	isAdmin, err := s.chatRepo.IsChatAdmin(chatID, userID)
+	if err != nil {
+		return err
+	}
+	if !isAdmin {
+		return errors.New("доступ запрещен: требуются права администратора чата")
+	}
+	return nil
+}
+
+func validateWebhookURL(webhookURL string) error {
+	if !isHTTPURL(webhookURL) {
+		return errors.New("некорректный адрес вебхука")
+	}
+	return nil
+}
+
+func isKnownWebhookEvent(event model.WebhookEvent) bool {
+	for _, e := range model.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
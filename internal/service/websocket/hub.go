@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,13 +16,33 @@ const (
 	pongWait       = 60 * time.Second
 	pingPeriod     = (pongWait * 9) / 10
 	maxMessageSize = 4096
+
+	idleTimeout       = 5 * time.Minute // без кадров activity клиент считается отошедшим
+	idleCheckInterval = 30 * time.Second
+	statusQueueSize   = 1024
+	statusBatchWindow = 500 * time.Millisecond // изменения статуса за это время склеиваются в одно
+
+	maxPresenceSubscriptions = 500 // явных подписок на одного пользователя
+	maxConnectionsPerUser    = 16  // сверх этого закрывается самое давно активное подключение
+)
+
+const (
+	StatusOnline  = "online"
+	StatusAway    = "away"
+	StatusOffline = "offline"
 )
 
+// Client — одно подключение. У пользователя их может быть несколько, по одному на устройство
+// или вкладку; ID подключения клиент получает событием connected и передает в REST-запросах
+// заголовком X-Connection-ID, чтобы не получать обратно собственные изменения.
 type Client struct {
 	ID     uuid.UUID
 	Conn   *ws.Conn
 	Send   chan []byte
 	UserID uuid.UUID
+
+	lastActive time.Time // меняется только в Hub.Run
+	away       atomic.Bool
 }
 
 type Message struct {
@@ -29,43 +50,144 @@ type Message struct {
 	Content interface{} `json:"content"`
 }
 
+// UserStatus — содержимое события user_status
+type UserStatus struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	Online     bool       `json:"online"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// PresenceTracker сохраняет время последнего визита и решает, кому виден статус пользователя
+type PresenceTracker interface {
+	// PresenceAudience возвращает проверку, может ли зритель видеть статус пользователя
+	PresenceAudience(userID uuid.UUID) (canSee func(viewerID uuid.UUID) bool, err error)
+	// PresencePeers возвращает пользователей, чьи статусы userID получает автоматически
+	PresencePeers(userID uuid.UUID) ([]uuid.UUID, error)
+	UserDisconnected(userID uuid.UUID, at time.Time)
+}
+
+type statusChange struct {
+	userID uuid.UUID
+	status string
+	at     time.Time
+}
+
+type activityFrame struct {
+	client *Client
+	idle   bool
+}
+
 type Hub struct {
-	Clients    map[uuid.UUID]*Client
+	Clients    map[uuid.UUID]map[*Client]struct{} // подключения каждого пользователя
 	Register   chan *Client
 	Unregister chan *Client
 	Broadcast  chan Message
 	mu         sync.RWMutex
+
+	activity chan activityFrame
+	statuses chan statusChange
+	tracker  PresenceTracker
+
+	// Подписки на статусы: кого видит каждый подключенный пользователь (собеседники
+	// по чатам и явные подписки) и обратный индекс — кто следит за пользователем
+	subMu    sync.Mutex
+	peers    map[uuid.UUID]map[uuid.UUID]bool
+	explicit map[uuid.UUID]map[uuid.UUID]bool
+	watchers map[uuid.UUID]map[uuid.UUID]bool
 }
 
 func NewHub() *Hub {
 	return &Hub{
-		Clients:    make(map[uuid.UUID]*Client),
+		Clients:    make(map[uuid.UUID]map[*Client]struct{}),
 		Register:   make(chan *Client),
 		Unregister: make(chan *Client),
 		Broadcast:  make(chan Message),
+		activity:   make(chan activityFrame),
+		statuses:   make(chan statusChange, statusQueueSize),
+		peers:      make(map[uuid.UUID]map[uuid.UUID]bool),
+		explicit:   make(map[uuid.UUID]map[uuid.UUID]bool),
+		watchers:   make(map[uuid.UUID]map[uuid.UUID]bool),
 	}
 }
 
+// SetPresenceTracker подключает хранилище присутствия; вызывается до Run
+func (h *Hub) SetPresenceTracker(t PresenceTracker) {
+	h.tracker = t
+}
+
 func (h *Hub) Run() {
+	// Изменения статуса рассылаются отдельной горутиной по порядку,
+	// чтобы обращения к базе не задерживали подключения
+	go h.runStatuses()
+
+	idle := time.NewTicker(idleCheckInterval)
+	defer idle.Stop()
+
 	for {
 		select {
 		case client := <-h.Register:
+			client.lastActive = time.Now()
 			h.mu.Lock()
-			h.Clients[client.UserID] = client
+			before := h.statusLocked(client.UserID)
+			conns := h.Clients[client.UserID]
+			if conns == nil {
+				conns = make(map[*Client]struct{})
+				h.Clients[client.UserID] = conns
+			}
+			var evicted *Client
+			if len(conns) >= maxConnectionsPerUser {
+				for c := range conns {
+					if evicted == nil || c.lastActive.Before(evicted.lastActive) {
+						evicted = c
+					}
+				}
+			}
+			conns[client] = struct{}{}
 			h.mu.Unlock()
-			log.Printf("Client registered: %s", client.UserID)
-			h.broadcastStatus(client.UserID, true)
+			if evicted != nil {
+				h.remove(evicted)
+			}
+
+			log.Printf("Client registered: %s (connection %s)", client.UserID, client.ID)
+			h.sendMessage(client, Message{Type: "connected", Content: map[string]uuid.UUID{"connection_id": client.ID}})
+			if before == StatusOffline {
+				go h.loadPeers(client.UserID)
+			}
+			if before != StatusOnline {
+				h.queueStatus(client.UserID, StatusOnline)
+			}
 
 		case client := <-h.Unregister:
-			h.mu.Lock()
-			if _, ok := h.Clients[client.UserID]; ok {
-				delete(h.Clients, client.UserID)
-				close(client.Send)
-				h.mu.Unlock()
+			if h.remove(client) {
 				log.Printf("Client unregistered: %s", client.UserID)
-				h.broadcastStatus(client.UserID, false)
-			} else {
-				h.mu.Unlock()
+			}
+
+		case frame := <-h.activity:
+			c := frame.client
+			if !frame.idle {
+				c.lastActive = time.Now()
+			}
+			if c.away.Load() != frame.idle && h.isCurrent(c) {
+				h.setAway(c.UserID, map[*Client]bool{c: frame.idle})
+			}
+
+		case <-idle.C:
+			h.mu.RLock()
+			idleConns := make(map[uuid.UUID]map[*Client]bool)
+			for userID, conns := range h.Clients {
+				for c := range conns {
+					if !c.away.Load() && time.Since(c.lastActive) > idleTimeout {
+						if idleConns[userID] == nil {
+							idleConns[userID] = make(map[*Client]bool)
+						}
+						idleConns[userID][c] = true
+					}
+				}
+			}
+			h.mu.RUnlock()
+			for userID, changes := range idleConns {
+				h.setAway(userID, changes)
 			}
 
 		case message := <-h.Broadcast:
@@ -75,60 +197,399 @@ func (h *Hub) Run() {
 				continue
 			}
 			h.mu.RLock()
-			for _, client := range h.Clients {
-				select {
-				case client.Send <- data:
-				default:
-					close(client.Send)
-					delete(h.Clients, client.UserID)
+			var clients []*Client
+			for _, conns := range h.Clients {
+				for client := range conns {
+					clients = append(clients, client)
 				}
 			}
 			h.mu.RUnlock()
+			for _, client := range clients {
+				h.send(client, data)
+			}
+		}
+	}
+}
+
+// setAway меняет признак "отошел" у подключений пользователя и рассылает статус,
+// если от этого изменился общий статус пользователя
+func (h *Hub) setAway(userID uuid.UUID, changes map[*Client]bool) {
+	h.mu.Lock()
+	before := h.statusLocked(userID)
+	for c, away := range changes {
+		c.away.Store(away)
+	}
+	after := h.statusLocked(userID)
+	h.mu.Unlock()
+	if after != before {
+		h.queueStatus(userID, after)
+	}
+}
+
+// statusLocked — общий статус пользователя: онлайн, если активно хотя бы одно подключение,
+// отошел, если отошли все. Вызывается под mu.
+func (h *Hub) statusLocked(userID uuid.UUID) string {
+	conns := h.Clients[userID]
+	if len(conns) == 0 {
+		return StatusOffline
+	}
+	for c := range conns {
+		if !c.away.Load() {
+			return StatusOnline
+		}
+	}
+	return StatusAway
+}
+
+// queueStatus ставит изменение статуса в очередь рассылки, не блокируясь
+func (h *Hub) queueStatus(userID uuid.UUID, status string) {
+	select {
+	case h.statuses <- statusChange{userID: userID, status: status, at: time.Now()}:
+	default:
+		log.Printf("status queue is full, dropping %s status of %s", status, userID)
+	}
+}
+
+// runStatuses собирает изменения статусов в пачки: частые переключения одного
+// пользователя за statusBatchWindow склеиваются, и рассылается только последнее
+func (h *Hub) runStatuses() {
+	for change := range h.statuses {
+		batch := map[uuid.UUID]statusChange{}
+		order := []uuid.UUID{}
+		add := func(c statusChange) {
+			if c.status == StatusOffline && h.tracker != nil {
+				h.tracker.UserDisconnected(c.userID, c.at.UTC())
+			}
+			if _, ok := batch[c.userID]; !ok {
+				order = append(order, c.userID)
+			}
+			batch[c.userID] = c
+		}
+		add(change)
+
+		timer := time.NewTimer(statusBatchWindow)
+	collect:
+		for {
+			select {
+			case c := <-h.statuses:
+				add(c)
+			case <-timer.C:
+				break collect
+			}
+		}
+
+		for _, userID := range order {
+			h.broadcastStatus(batch[userID])
+		}
+	}
+}
+
+// broadcastStatus отправляет изменение статуса тем, кто следит за пользователем
+// и кому настройки приватности пользователя разрешают его видеть
+func (h *Hub) broadcastStatus(change statusChange) {
+	h.subMu.Lock()
+	watchers := make([]uuid.UUID, 0, len(h.watchers[change.userID]))
+	for viewerID := range h.watchers[change.userID] {
+		watchers = append(watchers, viewerID)
+	}
+	h.subMu.Unlock()
+	if len(watchers) == 0 {
+		return
+	}
+
+	status := UserStatus{
+		UserID: change.userID,
+		Online: change.status != StatusOffline,
+		Status: change.status,
+	}
+	if change.status == StatusOffline {
+		at := change.at.UTC()
+		status.LastSeenAt = &at
+	}
+	h.sendStatus(status, watchers)
+}
+
+// sendStatus доставляет статус тем из viewers, кому он виден
+func (h *Hub) sendStatus(status UserStatus, viewers []uuid.UUID) {
+	canSee := func(uuid.UUID) bool { return true }
+	if h.tracker != nil {
+		var err error
+		canSee, err = h.tracker.PresenceAudience(status.UserID)
+		if err != nil {
+			log.Printf("error loading presence audience of %s: %v", status.UserID, err)
+			return
+		}
+	}
+
+	data, err := json.Marshal(Message{Type: "user_status", Content: status})
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+
+	h.mu.RLock()
+	var recipients []*Client
+	for _, viewerID := range viewers {
+		if viewerID == status.UserID || len(h.Clients[viewerID]) == 0 || !canSee(viewerID) {
+			continue
+		}
+		for client := range h.Clients[viewerID] {
+			recipients = append(recipients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range recipients {
+		h.send(client, data)
+	}
+}
+
+// loadPeers загружает собеседников только что подключившегося пользователя
+func (h *Hub) loadPeers(userID uuid.UUID) {
+	if h.tracker == nil {
+		return
+	}
+	peers, err := h.tracker.PresencePeers(userID)
+	if err != nil {
+		log.Printf("error loading presence peers of %s: %v", userID, err)
+		return
+	}
+
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	// Пользователь мог отключиться, пока шел запрос
+	if !h.IsUserOnline(userID) {
+		return
+	}
+	for _, peerID := range peers {
+		h.watch(h.peers, userID, peerID)
+	}
+}
+
+// ChatMembersChanged обновляет подписки после изменения состава чата:
+// подключенные участники начинают получать статусы друг друга
+func (h *Hub) ChatMembersChanged(members []uuid.UUID) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	for _, viewerID := range members {
+		if !h.IsUserOnline(viewerID) {
+			continue
+		}
+		for _, peerID := range members {
+			if peerID != viewerID {
+				h.watch(h.peers, viewerID, peerID)
+			}
 		}
 	}
 }
 
-func (h *Hub) broadcastStatus(userID uuid.UUID, online bool) {
-	msg := Message{
-		Type: "user_status",
-		Content: map[string]interface{}{
-			"user_id": userID,
-			"online":  online,
-		},
+// Subscribe подписывает пользователя на статусы тех, с кем нет общих чатов, и сразу отправляет
+// их текущие статусы. Подписки общие для всех подключений пользователя.
+func (h *Hub) Subscribe(client *Client, userIDs []uuid.UUID) {
+	if !h.isCurrent(client) {
+		return
+	}
+
+	h.subMu.Lock()
+	var added []uuid.UUID
+	for _, userID := range userIDs {
+		if userID == client.UserID || h.explicit[client.UserID][userID] {
+			continue
+		}
+		if len(h.explicit[client.UserID]) >= maxPresenceSubscriptions {
+			break
+		}
+		h.watch(h.explicit, client.UserID, userID)
+		added = append(added, userID)
+	}
+	h.subMu.Unlock()
+
+	for _, userID := range added {
+		status := h.UserStatus(userID)
+		h.sendStatus(UserStatus{UserID: userID, Online: status != StatusOffline, Status: status}, []uuid.UUID{client.UserID})
+	}
+}
+
+func (h *Hub) Unsubscribe(client *Client, userIDs []uuid.UUID) {
+	h.subMu.Lock()
+	defer h.subMu.Unlock()
+	for _, userID := range userIDs {
+		h.unwatch(h.explicit, client.UserID, userID)
+	}
+}
+
+// watch и unwatch вызываются под subMu
+func (h *Hub) watch(set map[uuid.UUID]map[uuid.UUID]bool, viewerID, userID uuid.UUID) {
+	if set[viewerID] == nil {
+		set[viewerID] = make(map[uuid.UUID]bool)
+	}
+	set[viewerID][userID] = true
+	if h.watchers[userID] == nil {
+		h.watchers[userID] = make(map[uuid.UUID]bool)
+	}
+	h.watchers[userID][viewerID] = true
+}
+
+func (h *Hub) unwatch(set map[uuid.UUID]map[uuid.UUID]bool, viewerID, userID uuid.UUID) {
+	if !set[viewerID][userID] {
+		return
+	}
+	delete(set[viewerID], userID)
+	if len(set[viewerID]) == 0 {
+		delete(set, viewerID)
+	}
+	// Пользователь может оставаться видимым через другой набор подписок
+	if h.peers[viewerID][userID] || h.explicit[viewerID][userID] {
+		return
+	}
+	delete(h.watchers[userID], viewerID)
+	if len(h.watchers[userID]) == 0 {
+		delete(h.watchers, userID)
+	}
+}
+
+// forget удаляет подписки отключившегося пользователя; вызывается под subMu
+func (h *Hub) forget(viewerID uuid.UUID) {
+	for _, set := range []map[uuid.UUID]map[uuid.UUID]bool{h.peers, h.explicit} {
+		for userID := range set[viewerID] {
+			h.unwatch(set, viewerID, userID)
+		}
 	}
-	h.Broadcast <- msg
 }
 
 // Проверить, онлайн ли пользователь
 func (h *Hub) IsUserOnline(userID uuid.UUID) bool {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	_, ok := h.Clients[userID]
-	return ok
+	return len(h.Clients[userID]) > 0
+}
+
+// UserStatus возвращает online, away или offline
+func (h *Hub) UserStatus(userID uuid.UUID) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.statusLocked(userID)
+}
+
+// OnlineStats считает подключенных пользователей и тех из них, кто отошел
+func (h *Hub) OnlineStats() (online, away int) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for userID := range h.Clients {
+		online++
+		if h.statusLocked(userID) == StatusAway {
+			away++
+		}
+	}
+	return online, away
 }
 
-// Отправить сообщение конкретному пользователю
+// Отправить сообщение всем подключениям пользователя
 func (h *Hub) SendToUser(userID uuid.UUID, message Message) {
+	h.SendToUserExcept(userID, uuid.Nil, message)
+}
+
+// SendToUserExcept отправляет сообщение всем подключениям пользователя, кроме connectionID —
+// того, из которого пришло изменение
+func (h *Hub) SendToUserExcept(userID, connectionID uuid.UUID, message Message) {
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("error marshaling message: %v", err)
 		return
 	}
+	for _, client := range h.connections(userID) {
+		if client.ID != connectionID {
+			h.send(client, data)
+		}
+	}
+}
 
+// DisconnectUser закрывает все подключения пользователя, например, когда его аккаунт удален.
+// Возвращает false, если пользователь не был подключен.
+func (h *Hub) DisconnectUser(userID uuid.UUID) bool {
+	removed := false
+	for _, client := range h.connections(userID) {
+		if h.remove(client) {
+			removed = true
+		}
+	}
+	return removed
+}
+
+func (h *Hub) connections(userID uuid.UUID) []*Client {
 	h.mu.RLock()
-	client, ok := h.Clients[userID]
-	h.mu.RUnlock()
+	defer h.mu.RUnlock()
+	clients := make([]*Client, 0, len(h.Clients[userID]))
+	for client := range h.Clients[userID] {
+		clients = append(clients, client)
+	}
+	return clients
+}
 
-	if ok {
-		select {
-		case client.Send <- data:
-		default:
-			close(client.Send)
-			h.mu.Lock()
-			delete(h.Clients, userID)
-			h.mu.Unlock()
+func (h *Hub) sendMessage(client *Client, message Message) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("error marshaling message: %v", err)
+		return
+	}
+	h.send(client, data)
+}
+
+// send отключает клиента, который не успевает забирать сообщения. Отправка идет под
+// блокировкой чтения: Send закрывается только под записью, поэтому канал не может быть закрыт.
+func (h *Hub) send(client *Client, data []byte) {
+	h.mu.RLock()
+	if _, ok := h.Clients[client.UserID][client]; !ok {
+		h.mu.RUnlock()
+		return
+	}
+	select {
+	case client.Send <- data:
+		h.mu.RUnlock()
+	default:
+		h.mu.RUnlock()
+		h.remove(client)
+	}
+}
+
+// remove удаляет подключение, если оно еще зарегистрировано. Когда у пользователя не остается
+// подключений, он уходит в офлайн; иначе статус пересчитывается по оставшимся.
+// Send закрывается под блокировкой, поэтому повторное удаление ничего не делает.
+func (h *Hub) remove(client *Client) bool {
+	h.mu.Lock()
+	conns := h.Clients[client.UserID]
+	if _, ok := conns[client]; !ok {
+		h.mu.Unlock()
+		return false
+	}
+	before := h.statusLocked(client.UserID)
+	delete(conns, client)
+	if len(conns) == 0 {
+		delete(h.Clients, client.UserID)
+	}
+	close(client.Send)
+	after := h.statusLocked(client.UserID)
+	h.mu.Unlock()
+
+	if after == StatusOffline {
+		h.subMu.Lock()
+		// Пока ждали блокировку, пользователь мог подключиться снова
+		if !h.IsUserOnline(client.UserID) {
+			h.forget(client.UserID)
 		}
+		h.subMu.Unlock()
+	}
+	if after != before {
+		h.queueStatus(client.UserID, after)
 	}
+	return true
+}
+
+func (h *Hub) isCurrent(client *Client) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.Clients[client.UserID][client]
+	return ok
 }
 
 func (c *Client) ReadPump(h *Hub) {
@@ -140,13 +601,35 @@ func (c *Client) ReadPump(h *Hub) {
 	c.Conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.Conn.SetPongHandler(func(string) error { c.Conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 	for {
-		_, _, err := c.Conn.ReadMessage()
+		_, data, err := c.Conn.ReadMessage()
 		if err != nil {
 			if ws.IsUnexpectedCloseError(err, ws.CloseGoingAway, ws.CloseAbnormalClosure) {
 				log.Printf("error: %v", err)
 			}
 			break
 		}
+
+		// Клиент сообщает о действиях пользователя кадрами {"type":"activity"} и {"type":"idle"}
+		// и подписывается на статусы кадрами {"type":"subscribe_presence","content":{"user_ids":[...]}}
+		var frame struct {
+			Type    string `json:"type"`
+			Content struct {
+				UserIDs []uuid.UUID `json:"user_ids"`
+			} `json:"content"`
+		}
+		if json.Unmarshal(data, &frame) != nil {
+			continue
+		}
+		switch frame.Type {
+		case "activity":
+			h.activity <- activityFrame{client: c}
+		case "idle":
+			h.activity <- activityFrame{client: c, idle: true}
+		case "subscribe_presence":
+			h.Subscribe(c, frame.Content.UserIDs)
+		case "unsubscribe_presence":
+			h.Unsubscribe(c, frame.Content.UserIDs)
+		}
 	}
 }
 
@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"messenger/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+
+	rsaKeyBits         = 2048
+	rotationCheckEvery = time.Minute
+)
+
+// SigningKey — один ключ подписи из набора
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+// JWK — публичное представление ключа для /.well-known/jwks.json
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeySet хранит активный ключ подписи и предыдущие ключи, которые еще
+// нужны для проверки выпущенных ими токенов.
+type KeySet struct {
+	cfg  config.JWTConfig
+	mu   sync.RWMutex
+	keys []*SigningKey // отсортированы по CreatedAt, последний — активный
+}
+
+func NewKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	if cfg.Algorithm != AlgRS256 && cfg.Algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("неподдерживаемый алгоритм подписи: %s", cfg.Algorithm)
+	}
+
+	ks := &KeySet{cfg: cfg}
+	if err := ks.load(); err != nil {
+		return nil, err
+	}
+
+	active := ks.Active()
+	if active == nil || active.Algorithm != cfg.Algorithm || time.Since(active.CreatedAt) >= cfg.RotationInterval {
+		if _, err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	ks.prune()
+	return ks, nil
+}
+
+// Active возвращает ключ, которым подписываются новые токены
+func (ks *KeySet) Active() *SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil
+	}
+	return ks.keys[len(ks.keys)-1]
+}
+
+// Rotate выпускает новый активный ключ. Старые ключи продолжают проверять
+// токены, пока не истечет максимальное время жизни подписанных ими токенов.
+func (ks *KeySet) Rotate() (*SigningKey, error) {
+	key, err := generateKey(ks.cfg.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.save(key); err != nil {
+		return nil, err
+	}
+
+	ks.mu.Lock()
+	ks.keys = append(ks.keys, key)
+	ks.mu.Unlock()
+
+	log.Printf("JWT signing key rotated: %s (%s)", key.ID, key.Algorithm)
+	return key, nil
+}
+
+// RunRotation периодически ротирует ключи и удаляет вышедшие из обращения
+func (ks *KeySet) RunRotation() {
+	ticker := time.NewTicker(rotationCheckEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		if active := ks.Active(); active == nil || time.Since(active.CreatedAt) >= ks.cfg.RotationInterval {
+			if _, err := ks.Rotate(); err != nil {
+				log.Printf("error rotating JWT signing key: %v", err)
+				continue
+			}
+		}
+		ks.prune()
+	}
+}
+
+// TokenTTL — время жизни выпускаемых токенов; дольше него ключи после ротации не хранятся
+func (ks *KeySet) TokenTTL() time.Duration {
+	return ks.cfg.TokenTTL
+}
+
+// Sign подписывает claims активным ключом и проставляет iss/aud
+func (ks *KeySet) Sign(claims *Claims) (string, error) {
+	key := ks.Active()
+	if key == nil {
+		return "", errors.New("нет активного ключа подписи")
+	}
+
+	claims.Issuer = ks.cfg.Issuer
+	claims.Audience = ks.cfg.Audience
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc находит публичный ключ по kid и проверяет, что алгоритм токена совпадает с алгоритмом ключа
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("в токене отсутствует kid")
+	}
+
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	for _, key := range ks.keys {
+		if key.ID != kid {
+			continue
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("неожиданный алгоритм подписи: %s", token.Method.Alg())
+		}
+		return key.Private.Public(), nil
+	}
+	return nil, fmt.Errorf("неизвестный ключ подписи: %s", kid)
+}
+
+// ParserOptions возвращает опции проверки алгоритма, издателя и аудитории
+func (ks *KeySet) ParserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(ks.cfg.Issuer),
+	}
+	if len(ks.cfg.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(ks.cfg.Audience...))
+	}
+	return opts
+}
+
+// JWKS возвращает публичные части всех ключей, которые еще принимаются при проверке
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// prune удаляет ключи, все токены которых уже истекли: ключ выходит из
+// обращения через TokenTTL после того, как его сменил следующий.
+func (ks *KeySet) prune() {
+	ks.mu.Lock()
+	var retired []*SigningKey
+	kept := ks.keys[:0]
+	for i, key := range ks.keys {
+		if i < len(ks.keys)-1 && time.Since(ks.keys[i+1].CreatedAt) > ks.cfg.TokenTTL {
+			retired = append(retired, key)
+			continue
+		}
+		kept = append(kept, key)
+	}
+	ks.keys = kept
+	ks.mu.Unlock()
+
+	for _, key := range retired {
+		log.Printf("JWT signing key retired: %s", key.ID)
+		if ks.cfg.KeysDir != "" {
+			if err := os.Remove(ks.keyPath(key.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Printf("error removing retired key %s: %v", key.ID, err)
+			}
+		}
+	}
+}
+
+func (ks *KeySet) keyPath(kid string) string {
+	return filepath.Join(ks.cfg.KeysDir, kid+".pem")
+}
+
+func (ks *KeySet) load() error {
+	if ks.cfg.KeysDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(ks.cfg.KeysDir, 0o700); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(ks.cfg.KeysDir, "*.pem"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		key, err := decodeKey(strings.TrimSuffix(filepath.Base(file), ".pem"), data)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения ключа %s: %w", file, err)
+		}
+		ks.keys = append(ks.keys, key)
+	}
+
+	sort.Slice(ks.keys, func(i, j int) bool {
+		return ks.keys[i].CreatedAt.Before(ks.keys[j].CreatedAt)
+	})
+	return nil
+}
+
+func (ks *KeySet) save(key *SigningKey) error {
+	if ks.cfg.KeysDir == "" {
+		return nil
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return err
+	}
+	block := &pem.Block{
+		Type: "PRIVATE KEY",
+		Headers: map[string]string{
+			"Algorithm": key.Algorithm,
+			"Created":   key.CreatedAt.Format(time.RFC3339Nano),
+		},
+		Bytes: der,
+	}
+	return os.WriteFile(ks.keyPath(key.ID), pem.EncodeToMemory(block), 0o600)
+}
+
+func decodeKey(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("ожидался PEM-блок PRIVATE KEY")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("ключ не поддерживает подпись")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, block.Headers["Created"])
+	if err != nil {
+		return nil, fmt.Errorf("некорректный заголовок Created: %w", err)
+	}
+
+	key := &SigningKey{ID: kid, Algorithm: block.Headers["Algorithm"], Private: signer, CreatedAt: createdAt}
+	switch signer.(type) {
+	case *rsa.PrivateKey:
+		if key.Algorithm != AlgRS256 {
+			return nil, fmt.Errorf("алгоритм %s не подходит для RSA-ключа", key.Algorithm)
+		}
+	case ed25519.PrivateKey:
+		if key.Algorithm != AlgEdDSA {
+			return nil, fmt.Errorf("алгоритм %s не подходит для Ed25519-ключа", key.Algorithm)
+		}
+	default:
+		return nil, errors.New("неподдерживаемый тип ключа")
+	}
+	return key, nil
+}
+
+func generateKey(algorithm string) (*SigningKey, error) {
+	var signer crypto.Signer
+	switch algorithm {
+	case AlgRS256:
+		k, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		signer = k
+	case AlgEdDSA:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		signer = k
+	default:
+		return nil, fmt.Errorf("неподдерживаемый алгоритм подписи: %s", algorithm)
+	}
+
+	return &SigningKey{
+		ID:        uuid.NewString(),
+		Algorithm: algorithm,
+		Private:   signer,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}